@@ -0,0 +1,207 @@
+package ruler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithClock sets where the time rules' activation windows are checked against
+// comes from, time.Now if it isn't given, so tests can pin it and
+// simulations can replay a different day
+func WithClock(now func() time.Time) Option {
+	return func(r *Ruler) {
+		r.clock = now
+	}
+}
+
+// Scheduled reports whether the rule is only active some of the time,
+// with ActiveFrom, ActiveUntil or a Schedule
+func (f *Rule) Scheduled() bool {
+	return f.ActiveFrom != nil || f.ActiveUntil != nil || f.Schedule != ""
+}
+
+// window is when a compiled rule is active
+type window struct {
+	from, until *time.Time
+	schedule    *schedule
+}
+
+func compileWindow(f *Rule) (*window, error) {
+	if !f.Scheduled() {
+		return nil, nil
+	}
+
+	w := &window{from: f.ActiveFrom, until: f.ActiveUntil}
+	if w.from != nil && w.until != nil && !w.from.Before(*w.until) {
+		return nil, fmt.Errorf("rule (%s) on (%s) is active from after it's active until", f.ID, f.Path)
+	}
+	if f.Schedule != "" {
+		var err error
+		if w.schedule, err = parseSchedule(f.Schedule); err != nil {
+			return nil, fmt.Errorf("bad schedule on (%s): %w", f.Path, err)
+		}
+	}
+
+	return w, nil
+}
+
+// contains reports whether the rule is active at t
+// ActiveFrom is inclusive and ActiveUntil isn't
+func (w *window) contains(t time.Time) bool {
+	if w.from != nil && t.Before(*w.from) {
+		return false
+	}
+	if w.until != nil && !t.Before(*w.until) {
+		return false
+	}
+
+	return w.schedule == nil || w.schedule.matches(t)
+}
+
+// active reports whether f is switched on for the evaluation in e,
+// a rule that isn't is left out as though it wasn't in the ruleset
+func (f *compiledRule) active(e *evaluation) bool {
+	return f.window == nil || f.window.contains(e.now())
+}
+
+// now is the time for the whole evaluation, so every rule sees the same one
+func (e *evaluation) now() time.Time {
+	if e.at.IsZero() {
+		if e.clock != nil {
+			e.at = e.clock()
+		} else {
+			e.at = time.Now()
+		}
+	}
+
+	return e.at
+}
+
+// schedule is a cron expression, "minute hour day-of-month month day-of-week",
+// and a rule with one is active during every minute it matches
+type schedule struct {
+	loc                           *time.Location
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// the fields of a cron expression, and the values each can take
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12}, {"day of week", 0, 7},
+}
+
+// parseSchedule parses a cron expression, like "* 9-17 * * 1-5" for business hours
+// fields can be *, numbers, ranges, lists and steps (*/15, 1-30/5), Sunday is 0 or 7,
+// and it can start with CRON_TZ=Europe/Paris to match in a time zone other than the clock's
+func parseSchedule(spec string) (*schedule, error) {
+	s := &schedule{}
+
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "CRON_TZ=") {
+		loc, err := time.LoadLocation(strings.TrimPrefix(fields[0], "CRON_TZ="))
+		if err != nil {
+			return nil, err
+		}
+		s.loc, fields = loc, fields[1:]
+	}
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("schedule (%s) needs %d fields", spec, len(cronFields))
+	}
+
+	sets := []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule (%s) %s: %w", spec, cronFields[i].name, err)
+		}
+		*sets[i] = set
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	if s.dow&(1<<7) != 0 {
+		// 7 is Sunday too
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step (%s)", part)
+			}
+		}
+
+		lo, hi := min, max
+		if expr != "*" {
+			from, to, ranged := strings.Cut(expr, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("bad value (%s)", part)
+			}
+			hi = lo
+			if ranged {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("bad range (%s)", part)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("(%s) is outside %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func (s *schedule) matches(t time.Time) bool {
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	// like cron, when both days are given either one will do
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+
+	return dom || dow
+}
+
+// active reports whether any of the rules on a path are active,
+// the path isn't plucked when none of them are
+func (pr *pathRules) active(e *evaluation) bool {
+	for _, f := range pr.rules {
+		if f.active(e) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// aggregate reduces an array of values to a single float64
+// so that it can be run through the usual comparators
+func aggregate(fn string, val interface{}) (interface{}, error) {
+	items, ok := asSlice(val)
+	if !ok {
+		return nil, fmt.Errorf("cannot %s a value that is not an array", fn)
+	}
+
+	if fn == "count" {
+		return float64(len(items)), nil
+	}
+
+	nums := make([]float64, len(items))
+	for i, item := range items {
+		n, ok := toFloat(item)
+		if !ok {
+			return nil, fmt.Errorf("cannot %s non-numeric value %v", fn, item)
+		}
+		nums[i] = n
+	}
+
+	switch fn {
+	case "sum":
+		return sum(nums), nil
+	case "avg":
+		if len(nums) == 0 {
+			return nil, errors.New("cannot avg an empty array")
+		}
+		return sum(nums) / float64(len(nums)), nil
+	case "min":
+		if len(nums) == 0 {
+			return nil, errors.New("cannot min an empty array")
+		}
+		m := math.Inf(1)
+		for _, n := range nums {
+			m = math.Min(m, n)
+		}
+		return m, nil
+	case "max":
+		if len(nums) == 0 {
+			return nil, errors.New("cannot max an empty array")
+		}
+		m := math.Inf(-1)
+		for _, n := range nums {
+			m = math.Max(m, n)
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown aggregate %s", fn)
+	}
+}
+
+func sum(nums []float64) float64 {
+	var total float64
+	for _, n := range nums {
+		total += n
+	}
+
+	return total
+}
+
+// toFloat converts any of go's numeric types to a float64
+func toFloat(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package ruler
+
+import (
+	"errors"
+	"math"
+)
+
+// DefaultEpsilon is how close approx_eq wants two numbers to be,
+// unless the rule or WithEpsilon says otherwise
+const DefaultEpsilon = 1e-9
+
+// WithEpsilon sets the tolerance approx_eq uses for rules that don't set their own
+func WithEpsilon(epsilon float64) Option {
+	return func(r *Ruler) {
+		r.epsilon = epsilon
+	}
+}
+
+// approxEqual checks that actual is within epsilon of the expected number
+// expected is either the number itself, or an object like
+//
+//	{"value": 20, "epsilon": 0.01}
+//
+// the tolerance is relative for numbers bigger than 1,
+// so large values aren't held to a stricter standard than small ones
+func (r *Ruler) approxEqual(actual, expected interface{}) (bool, error) {
+	want, epsilon, err := r.approxTarget(expected)
+	if err != nil {
+		return false, err
+	}
+
+	got, ok := toFloat(actual)
+	if !ok {
+		return false, errors.New("actual value not actually a number, bailing")
+	}
+
+	if got == want {
+		// covers matching infinities
+		return true, nil
+	}
+
+	scale := math.Max(1, math.Max(math.Abs(got), math.Abs(want)))
+	return math.Abs(got-want) <= epsilon*scale, nil
+}
+
+// approxTarget pulls the number and tolerance out of an approx_eq rule's value
+func (r *Ruler) approxTarget(expected interface{}) (float64, float64, error) {
+	epsilon := r.epsilon
+	if epsilon == 0 {
+		epsilon = DefaultEpsilon
+	}
+
+	if m, ok := asMap(expected); ok {
+		if e, found := m["epsilon"]; found {
+			if epsilon, ok = toFloat(e); !ok {
+				return 0, 0, errors.New("epsilon not actually a number, bailing")
+			}
+		}
+		expected = m["value"]
+	}
+
+	want, ok := toFloat(expected)
+	if !ok {
+		return 0, 0, errors.New("expected value not actually a number, bailing")
+	}
+
+	return want, epsilon, nil
+}
@@ -0,0 +1,168 @@
+package ruler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord is what an AuditSink gets for every document tested,
+// enough to show later why it was or wasn't let through
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Document is a sha256 digest of the document's JSON, so the record
+	// can be matched up with the document without holding on to it
+	Document string `json:"document"`
+	Matched  bool   `json:"matched"`
+	// Failed lists the rules the document failed, by ID or by path if they don't have one
+	Failed []string `json:"failed,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	// Version and Fingerprint identify the rules in effect, see Ruler's Version and Fingerprint
+	Version     string `json:"version,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// An AuditSink writes AuditRecords somewhere they'll be kept
+// if Audit returns an error the document is treated as not matching,
+// so nothing gets through without a record of it
+type AuditSink interface {
+	Audit(rec AuditRecord) error
+}
+
+// WithAudit sends an AuditRecord to sink for every document tested
+// by Test, Evaluate, EvaluateEvery and their variations
+// wrap a slow sink in NewAsyncAuditSink so it doesn't hold up testing
+func WithAudit(sink AuditSink) Option {
+	return func(r *Ruler) {
+		r.audit = sink
+	}
+}
+
+// auditRecord starts the record for the document in e
+func (c *CompiledRuler) auditRecord(e *evaluation) AuditRecord {
+	rec := AuditRecord{
+		Time:        time.Now().UTC(),
+		Version:     c.ruler.version,
+		Fingerprint: c.fingerprint,
+	}
+
+	var doc interface{} = e.doc
+	if e.root != nil {
+		doc = e.root
+	}
+	if b, err := json.Marshal(doc); err == nil {
+		sum := sha256.Sum256(b)
+		rec.Document = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	return rec
+}
+
+// auditName is how a failed rule shows up in an AuditRecord
+func auditName(f *Rule) string {
+	if f.ID != "" {
+		return f.ID
+	}
+
+	return f.Path
+}
+
+// jsonAuditSink writes records as JSON, one per line
+type jsonAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink writes each AuditRecord to w as a line of JSON
+// it's safe to use from more than one goroutine
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonAuditSink) Audit(rec AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enc.Encode(rec)
+}
+
+// AsyncAuditSink buffers records and writes them to another sink in the background
+// when the buffer is full Audit waits for room rather than dropping records
+type AsyncAuditSink struct {
+	sink    AuditSink
+	onError func(error)
+	records chan AuditRecord
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncAuditSink writes records to sink from a goroutine of its own,
+// with room for buffer records waiting to be written
+// the background writes can't fail the documents they're for,
+// so their errors go to onError, which can be nil
+// Close it to write whatever is left before shutting down
+func NewAsyncAuditSink(sink AuditSink, buffer int, onError func(error)) *AsyncAuditSink {
+	a := &AsyncAuditSink{
+		sink:    sink,
+		onError: onError,
+		records: make(chan AuditRecord, buffer),
+		done:    make(chan struct{}),
+	}
+
+	go a.run()
+
+	return a
+}
+
+func (a *AsyncAuditSink) run() {
+	defer close(a.done)
+
+	for rec := range a.records {
+		if err := a.sink.Audit(rec); err != nil && a.onError != nil {
+			a.onError(err)
+		}
+	}
+}
+
+// Audit queues rec to be written
+func (a *AsyncAuditSink) Audit(rec AuditRecord) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return errors.New("audit sink is closed")
+	}
+
+	a.records <- rec
+	return nil
+}
+
+// Close waits for every queued record to be written
+// records audited after Close are errors
+func (a *AsyncAuditSink) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.records)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return nil
+}
+
+// writeAudit hands the finished record to the Ruler's sink
+func (r *Ruler) writeAudit(rec AuditRecord) error {
+	if err := r.audit.Audit(rec); err != nil {
+		return fmt.Errorf("writing audit record: %w", err)
+	}
+
+	return nil
+}
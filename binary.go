@@ -0,0 +1,131 @@
+package ruler
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// binaryVersion is bumped whenever the encoding changes,
+// so rulers cached by an older version aren't misread
+const binaryVersion = 1
+
+// compiledSnapshot is what MarshalBinary writes out:
+// the rules, and every option that isn't Go code
+type compiledSnapshot struct {
+	Version        int
+	RulesVersion   string
+	Rules          []*Rule
+	Limits         Limits
+	DecisionMode   DecisionMode
+	DefaultOutcome interface{}
+	Epsilon        float64
+	NonFinite      NonFinitePolicy
+	NumericStrings bool
+	BoolStrings    bool
+	Normalize      *norm.Form
+	Flattened      bool
+}
+
+func init() {
+	// the values JSON decodes to that gob doesn't know about already
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// MarshalBinary encodes the compiled rules so a big ruleset can be compiled once,
+// cached (in Redis, say) and loaded on other machines with LoadCompiled
+// options that are Go code, like WithTransform, WithCollation, WithDecimalCompare,
+// WithMessageCatalog, WithLookup and WithClock, can't be encoded, pass them to LoadCompiled again
+func (c *CompiledRuler) MarshalBinary() ([]byte, error) {
+	r := c.ruler
+	snap := compiledSnapshot{
+		Version:        binaryVersion,
+		RulesVersion:   r.version,
+		Rules:          make([]*Rule, len(c.rules)),
+		Limits:         r.limits,
+		DecisionMode:   r.decisionMode,
+		DefaultOutcome: r.defaultOutcome,
+		Epsilon:        r.epsilon,
+		NonFinite:      r.nonFinite,
+		NumericStrings: r.numericStrings,
+		BoolStrings:    r.boolStrings,
+		Normalize:      r.normalize,
+		Flattened:      r.flattened,
+	}
+	for i, f := range c.rules {
+		snap.Rules[i] = f.Rule
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&snap); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary loads rules encoded by MarshalBinary into c,
+// see LoadCompiled if you need to give options again
+func (c *CompiledRuler) UnmarshalBinary(data []byte) error {
+	loaded, err := LoadCompiled(data)
+	if err != nil {
+		return err
+	}
+
+	*c = *loaded
+	return nil
+}
+
+// LoadCompiled decodes rules encoded by MarshalBinary, with opts applied
+// on top of the options that were encoded with them
+// the rules aren't parsed again, and their regexes are only compiled
+// the first time they're used, since they were checked when the rules were first compiled
+func LoadCompiled(data []byte, opts ...Option) (*CompiledRuler, error) {
+	var snap compiledSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decoding compiled rules: %w", err)
+	}
+	if snap.Version != binaryVersion {
+		return nil, fmt.Errorf("compiled rules are version %d, expected %d", snap.Version, binaryVersion)
+	}
+
+	r := &Ruler{
+		rules:          snap.Rules,
+		version:        snap.RulesVersion,
+		limits:         snap.Limits,
+		decisionMode:   snap.DecisionMode,
+		defaultOutcome: snap.DefaultOutcome,
+		epsilon:        snap.Epsilon,
+		nonFinite:      snap.NonFinite,
+		numericStrings: snap.NumericStrings,
+		boolStrings:    snap.BoolStrings,
+		normalize:      snap.Normalize,
+		flattened:      snap.Flattened,
+		lazyRegexps:    true,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r.Compile()
+}
+
+// lazyRegexp is a rule's regex, compiled the first time it's needed
+type lazyRegexp struct {
+	pattern string
+	once    sync.Once
+	re      Matcher
+	err     error
+}
+
+func (l *lazyRegexp) get(r *Ruler) (Matcher, error) {
+	l.once.Do(func() {
+		l.re, l.err = r.compileRegexp(l.pattern)
+	})
+
+	return l.re, l.err
+}
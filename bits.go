@@ -0,0 +1,55 @@
+package ruler
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// bitand checks the flag bits in actual against the mask in expected,
+// any passes when at least one of the mask's bits is set, all when every one is
+func bitand(all bool, actual, expected interface{}) (bool, error) {
+	mask, ok := toUint(expected)
+	if !ok {
+		return false, errors.New("expected value not actually an unsigned integer, bailing")
+	}
+	flags, ok := toUint(actual)
+	if !ok {
+		return false, errors.New("actual value not actually an unsigned integer, bailing")
+	}
+
+	if all {
+		return flags&mask == mask, nil
+	}
+
+	return flags&mask != 0, nil
+}
+
+// toUint reads a non-negative integer from any Go integer, a float64
+// with no fractional part (what JSON gives us), or a string like "0x1f" or "0b101"
+func toUint(v interface{}) (uint64, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseUint(s, 0, 64)
+		return n, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, false
+		}
+		return uint64(rv.Int()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f < 0 || f != math.Trunc(f) || f >= math.MaxUint64 {
+			return 0, false
+		}
+		return uint64(f), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package ruler
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// WithBoolStrings makes eq, neq, istrue and isfalse treat strings like "true",
+// "false", "1" and "0" (anything strconv.ParseBool takes) as booleans,
+// for documents where flags come through as strings
+func WithBoolStrings() Option {
+	return func(r *Ruler) {
+		r.boolStrings = true
+	}
+}
+
+// asBool turns v into a bool if it is one (or a type based on bool),
+// or a boolean string with WithBoolStrings
+func (r *Ruler) asBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		if !r.boolStrings {
+			return false, false
+		}
+		parsed, err := strconv.ParseBool(b)
+		return parsed, err == nil
+	case nil:
+		return false, false
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Bool {
+		return rv.Bool(), true
+	}
+
+	return false, false
+}
+
+// compareBools handles eq and neq when the rule's value is a boolean,
+// decided is false when it isn't
+func (r *Ruler) compareBools(f *compiledRule, actual, expected interface{}) (result bool, decided bool) {
+	if f.Comparator != "eq" && f.Comparator != "neq" {
+		return false, false
+	}
+
+	want, ok := r.asBool(expected)
+	if !ok {
+		return false, false
+	}
+	got, ok := r.asBool(actual)
+	if !ok {
+		// not a boolean at all, so it can't equal the value,
+		// and neq passes like it does for any other values of different types
+		return f.Comparator == "neq", true
+	}
+
+	if f.Comparator == "eq" {
+		return got == want, true
+	}
+
+	return got != want, true
+}
+
+// isBool checks istrue and isfalse, anything that isn't a boolean fails both
+func (r *Ruler) isBool(actual interface{}, want bool) bool {
+	got, ok := r.asBool(actual)
+	return ok && got == want
+}
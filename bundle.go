@@ -0,0 +1,94 @@
+package ruler
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrBadSignature is returned when a bundle's signature doesn't check out
+var ErrBadSignature = errors.New("ruler: bundle signature is not valid")
+
+// bundle is rule JSON along with a signature over it,
+// the payload is kept as base64 so the signed bytes come back out exactly
+//
+//	{"alg": "ed25519", "payload": "<base64 rule JSON>", "signature": "<base64>"}
+type bundle struct {
+	Alg       string `json:"alg"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// A Verifier checks a bundle's signature over its payload
+// return an error (ideally wrapping ErrBadSignature) if it doesn't match
+type Verifier interface {
+	Alg() string
+	Verify(payload, signature []byte) error
+}
+
+// Ed25519Verifier verifies bundles signed with the private half of pub
+func Ed25519Verifier(pub ed25519.PublicKey) Verifier {
+	return ed25519Verifier{pub}
+}
+
+type ed25519Verifier struct {
+	pub ed25519.PublicKey
+}
+
+func (v ed25519Verifier) Alg() string {
+	return "ed25519"
+}
+
+func (v ed25519Verifier) Verify(payload, signature []byte) error {
+	if len(v.pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("ruler: ed25519 public key is %d bytes, expected %d", len(v.pub), ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(v.pub, payload, signature) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+// NewRulerFromSignedBundle checks a bundle made by SignBundle against pub
+// and loads its rules like NewRulerWithJSON, so rules pulled from shared
+// storage can't be tampered with on the way
+func NewRulerFromSignedBundle(data []byte, pub ed25519.PublicKey, opts ...Option) (*Ruler, error) {
+	return NewRulerFromVerifiedBundle(data, Ed25519Verifier(pub), opts...)
+}
+
+// NewRulerFromVerifiedBundle is NewRulerFromSignedBundle with
+// any kind of signature, checked by v
+func NewRulerFromVerifiedBundle(data []byte, v Verifier, opts ...Option) (*Ruler, error) {
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+	if b.Alg != v.Alg() {
+		return nil, fmt.Errorf("ruler: bundle is signed with %q, expected %q", b.Alg, v.Alg())
+	}
+	if len(b.Signature) == 0 {
+		return nil, ErrBadSignature
+	}
+
+	if err := v.Verify(b.Payload, b.Signature); err != nil {
+		return nil, err
+	}
+
+	return NewRulerWithJSON(b.Payload, opts...)
+}
+
+// SignBundle wraps rule JSON up in a bundle signed with priv,
+// for NewRulerFromSignedBundle to load
+func SignBundle(rules []byte, priv ed25519.PrivateKey) ([]byte, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("ruler: ed25519 private key is %d bytes, expected %d", len(priv), ed25519.PrivateKeySize)
+	}
+
+	return json.Marshal(bundle{
+		Alg:       "ed25519",
+		Payload:   rules,
+		Signature: ed25519.Sign(priv, rules),
+	})
+}
@@ -0,0 +1,607 @@
+package ruler
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Rules can also be converted to and from a restricted subset of CEL
+(github.com/google/cel-spec):
+	user.age >= 21 && (country == "US" || country in ["CA", "MX"]) && has(user.id)
+
+The subset is comparisons (==, !=, <, <=, >, >=) between a path and a literal
+or two paths, has(path), size(path), path.matches(re), path.contains(s),
+path.startsWith(s), path.endsWith(s), value in path, path in [list],
+&&, ||, !, true, false and parentheses.
+Literals are strings, numbers, true, false, null and lists.
+"value in path" is treated as list membership, not a map key check.
+*/
+
+// NewRulerWithCEL returns a new ruler with rules converted from a CEL expression
+func NewRulerWithCEL(expr string, opts ...Option) (*Ruler, error) {
+	rules, err := ParseCEL(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRuler(rules, opts...)
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ParseCEL converts a CEL expression into rules
+// a top-level && becomes separate rules, like it does in ParseDSL
+func ParseCEL(expr string) ([]*Rule, error) {
+	p := &celParser{&dslParser{src: expr, lang: "cel"}}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	if len(f.All) > 0 {
+		return f.All, nil
+	}
+
+	return []*Rule{f}, nil
+}
+
+// celParser reuses the DSL's tokenizer, the grammar is its own
+type celParser struct {
+	*dslParser
+}
+
+func (p *celParser) parseOr() (*Rule, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{f}
+	for p.accept("||") {
+		g, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, g)
+	}
+
+	if len(rules) == 1 {
+		return f, nil
+	}
+
+	return &Rule{Any: rules}, nil
+}
+
+func (p *celParser) parseAnd() (*Rule, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{f}
+	for p.accept("&&") {
+		g, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, g)
+	}
+
+	if len(rules) == 1 {
+		return f, nil
+	}
+
+	return &Rule{All: rules}, nil
+}
+
+func (p *celParser) parseUnary() (*Rule, error) {
+	p.skipSpace()
+	start := p.pos
+
+	if p.accept("!") && !p.accept("=") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if f.Comparator == "exists" && !f.IsGroup() {
+			f.Comparator = "nexists"
+			return f, nil
+		}
+		return &Rule{Not: f}, nil
+	}
+	p.pos = start
+
+	if p.accept("(") {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected )")
+		}
+		return f, nil
+	}
+
+	return p.parseCondition()
+}
+
+// celOperand is one side of a comparison, either a path or a literal
+type celOperand struct {
+	path      string
+	aggregate string
+	value     interface{}
+}
+
+func (p *celParser) parseCondition() (*Rule, error) {
+	left, f, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		// a function or method that's a whole condition by itself
+		return f, nil
+	}
+
+	p.skipSpace()
+	if p.acceptIdent("in") {
+		return p.parseIn(left)
+	}
+
+	var comparator string
+	for _, op := range dslOperators {
+		if p.accept(op.tok) {
+			comparator = op.comparator
+			break
+		}
+	}
+	if comparator == "" {
+		if left.path == "" {
+			if b, ok := left.value.(bool); ok {
+				if b {
+					return &Rule{All: []*Rule{}}, nil
+				}
+				return &Rule{Any: []*Rule{}}, nil
+			}
+		}
+		return nil, p.errorf("expected an operator")
+	}
+
+	right, g, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if g != nil {
+		return nil, p.errorf("can't compare against a condition")
+	}
+
+	switch {
+	case left.path != "" && right.path != "":
+		if right.aggregate != "" {
+			return nil, p.errorf("size() is only supported on the left")
+		}
+		return &Rule{Comparator: comparator, Path: left.path, Aggregate: left.aggregate, ValuePath: right.path}, nil
+	case left.path != "":
+		return &Rule{Comparator: comparator, Path: left.path, Aggregate: left.aggregate, Value: right.value}, nil
+	case right.path != "":
+		return &Rule{Comparator: flippedComparators[comparator], Path: right.path, Aggregate: right.aggregate, Value: left.value}, nil
+	}
+
+	return nil, p.errorf("a comparison needs a path on one side")
+}
+
+func (p *celParser) parseIn(left celOperand) (*Rule, error) {
+	right, f, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if f != nil || right.aggregate != "" || left.aggregate != "" {
+		return nil, p.errorf("in needs a path and a literal")
+	}
+
+	switch {
+	case left.path != "" && right.path == "":
+		list, ok := right.value.([]interface{})
+		if !ok {
+			return nil, p.errorf("in needs a list")
+		}
+		rules := make([]*Rule, len(list))
+		for i, v := range list {
+			rules[i] = &Rule{Comparator: "eq", Path: left.path, Value: v}
+		}
+		return &Rule{Any: rules}, nil
+	case left.path == "" && right.path != "":
+		return &Rule{Comparator: "superset", Path: right.path, Value: []interface{}{left.value}}, nil
+	}
+
+	return nil, p.errorf("in needs a path and a literal")
+}
+
+// parseOperand reads a path, literal or function call
+// conditions like has(x) and x.matches(re) are returned as a rule
+func (p *celParser) parseOperand() (celOperand, *Rule, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return celOperand{}, nil, p.errorf("expected a value")
+	}
+
+	if c := rune(p.src[p.pos]); !unicode.IsLetter(c) && c != '_' {
+		v, err := p.parseLiteral()
+		return celOperand{value: v}, nil, err
+	}
+
+	ident := p.readIdent()
+	switch ident {
+	case "true":
+		return celOperand{value: true}, nil, nil
+	case "false":
+		return celOperand{value: false}, nil, nil
+	case "null":
+		return celOperand{value: nil}, nil, nil
+	}
+
+	if !p.accept("(") {
+		return celOperand{path: ident}, nil, nil
+	}
+
+	// has(path) and size(path)
+	switch ident {
+	case "has", "size":
+		path := p.readIdent()
+		if path == "" || !p.accept(")") {
+			return celOperand{}, nil, p.errorf("expected %s(path)", ident)
+		}
+		if ident == "size" {
+			return celOperand{path: path, aggregate: "count"}, nil, nil
+		}
+		return celOperand{}, &Rule{Comparator: "exists", Path: path}, nil
+	}
+
+	// path.method(arg)
+	dot := strings.LastIndex(ident, ".")
+	if dot < 0 {
+		return celOperand{}, nil, p.errorf("unknown function %s", ident)
+	}
+	path, method := ident[:dot], ident[dot+1:]
+
+	arg, err := p.parseLiteral()
+	if err != nil {
+		return celOperand{}, nil, err
+	}
+	s, ok := arg.(string)
+	if !ok || !p.accept(")") {
+		return celOperand{}, nil, p.errorf("expected %s(string)", method)
+	}
+
+	f := &Rule{Comparator: "regex", Path: path}
+	switch method {
+	case "matches":
+		f.Value = s
+	case "contains":
+		f.Value = regexp.QuoteMeta(s)
+	case "startsWith":
+		f.Value = "^" + regexp.QuoteMeta(s)
+	case "endsWith":
+		f.Value = regexp.QuoteMeta(s) + "$"
+	default:
+		return celOperand{}, nil, p.errorf("unknown method %s", method)
+	}
+
+	return celOperand{}, f, nil
+}
+
+// readIdent reads a dotted identifier
+func (p *celParser) readIdent() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := rune(p.src[p.pos])
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	return p.src[start:p.pos]
+}
+
+// acceptIdent consumes ident if it's next and isn't the start of a longer one
+func (p *celParser) acceptIdent(ident string) bool {
+	start := p.pos
+	if p.readIdent() == ident {
+		return true
+	}
+
+	p.pos = start
+	return false
+}
+
+// parseLiteral reads a string, number, true, false, null or list
+func (p *celParser) parseLiteral() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.readString()
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		if p.accept("]") {
+			return list, nil
+		}
+		for {
+			v, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if p.accept("]") {
+				return list, nil
+			}
+			if !p.accept(",") {
+				return nil, p.errorf("expected , or ]")
+			}
+		}
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.ContainsRune("0123456789.eE+-", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		// CEL's unsigned suffix
+		num := strings.TrimSuffix(p.src[start:p.pos], "u")
+		if p.pos < len(p.src) && p.src[p.pos] == 'u' {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %s", num)
+		}
+		return n, nil
+	}
+
+	switch ident := p.readIdent(); ident {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	return nil, p.errorf("expected a literal")
+}
+
+// CEL converts the Ruler's rules to a CEL expression
+// comparators, aggregates and parameters without a CEL version are an error
+func (r *Ruler) CEL() (string, error) {
+	return celGroup(r.rules, " && ", "true", false)
+}
+
+func celGroup(rules []*Rule, join, empty string, nested bool) (string, error) {
+	if len(rules) == 0 {
+		return empty, nil
+	}
+
+	parts := make([]string, len(rules))
+	for i, f := range rules {
+		part, err := celRule(f)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+
+	s := strings.Join(parts, join)
+	if nested && len(rules) > 1 {
+		return "(" + s + ")", nil
+	}
+
+	return s, nil
+}
+
+func celRule(f *Rule) (string, error) {
+	if f.Scheduled() {
+		return "", fmt.Errorf("cel: can't convert the activation window on (%s)", f.Path)
+	}
+	if f.Ruleset != "" {
+		return "", fmt.Errorf("cel: can't convert the reference to ruleset %s", f.Ruleset)
+	}
+
+	switch {
+	case f.All != nil:
+		return celGroup(f.All, " && ", "true", true)
+	case f.Any != nil:
+		return celGroup(f.Any, " || ", "false", true)
+	case f.Not != nil:
+		s, err := celRule(f.Not)
+		if err != nil {
+			return "", err
+		}
+		return "!(" + s + ")", nil
+	}
+
+	if _, ok := paramName(f.Value); ok {
+		return "", fmt.Errorf("cel: can't convert the parameter on (%s)", f.Path)
+	}
+	if _, ok := secretName(f.Value); ok {
+		return "", fmt.Errorf("cel: can't convert the secret on (%s)", f.Path)
+	}
+	if len(f.Transforms) > 0 {
+		return "", fmt.Errorf("cel: can't convert the transforms on (%s)", f.Path)
+	}
+	if f.Type != "" {
+		return "", fmt.Errorf("cel: can't convert the %s type on (%s)", f.Type, f.Path)
+	}
+	if hasPathFunctions(f) {
+		return "", fmt.Errorf("cel: can't convert the functions in the path (%s)", f.Path)
+	}
+	if readsContext(f) {
+		return "", fmt.Errorf("cel: can't convert the context variable on (%s)", f.Path)
+	}
+	if strings.Contains(f.Path, "*") || strings.Contains(f.ValuePath, "*") {
+		return "", fmt.Errorf("cel: can't convert the wildcard in (%s)", f.Path)
+	}
+
+	subject := f.Path
+	switch f.Aggregate {
+	case "":
+	case "count":
+		subject = "size(" + f.Path + ")"
+	default:
+		return "", fmt.Errorf("cel: can't convert the %s aggregate on (%s)", f.Aggregate, f.Path)
+	}
+
+	value := func() (string, error) {
+		if f.ValuePath != "" {
+			return f.ValuePath, nil
+		}
+		return celValue(f.Value)
+	}
+
+	switch f.Comparator {
+	case "exists", "nexists":
+		s := "has(" + f.Path + ")"
+		if f.Comparator == "nexists" {
+			s = "!" + s
+		}
+		return s, nil
+
+	case "regex", "matches", "contains", "ncontains":
+		if f.ValuePath != "" {
+			return "", fmt.Errorf("cel: can't convert a regex from a path on (%s)", f.Path)
+		}
+		re, err := celValue(f.Value)
+		if err != nil {
+			return "", err
+		}
+		s := subject + ".matches(" + re + ")"
+		if f.Comparator == "ncontains" {
+			s = "!" + s
+		}
+		return s, nil
+
+	case "superset", "intersects":
+		list, ok := f.Value.([]interface{})
+		if !ok || len(list) == 0 {
+			return "", fmt.Errorf("cel: can't convert %s on (%s) without a literal list", f.Comparator, f.Path)
+		}
+		parts := make([]string, len(list))
+		for i, v := range list {
+			s, err := celValue(v)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s + " in " + subject
+		}
+		join := " && "
+		if f.Comparator == "intersects" {
+			join = " || "
+		}
+		if len(parts) == 1 {
+			return parts[0], nil
+		}
+		return "(" + strings.Join(parts, join) + ")", nil
+
+	case "haskey":
+		key, err := value()
+		if err != nil {
+			return "", err
+		}
+		return key + " in " + subject, nil
+
+	case "deep_eq", "deep_neq":
+		// CEL's == already compares lists and maps by their contents
+		op := "=="
+		if f.Comparator == "deep_neq" {
+			op = "!="
+		}
+		v, err := value()
+		if err != nil {
+			return "", err
+		}
+		return subject + " " + op + " " + v, nil
+	}
+
+	for _, o := range dslOperators {
+		if o.comparator == f.Comparator {
+			v, err := value()
+			if err != nil {
+				return "", err
+			}
+			return subject + " " + o.tok + " " + v, nil
+		}
+	}
+
+	return "", fmt.Errorf("cel: can't convert comparator %s on (%s)", f.Comparator, f.Path)
+}
+
+// celValue renders a literal, numbers are always doubles
+// since that's what JSON numbers are in CEL
+func celValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return strconv.Quote(v), nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := celValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := celValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = strconv.Quote(k) + ": " + s
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	}
+
+	n, ok := toFloat(v)
+	if !ok {
+		return "", fmt.Errorf("cel: can't convert value %v", v)
+	}
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		return "", fmt.Errorf("cel: can't convert value %v", v)
+	}
+	s := strconv.FormatFloat(n, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+
+	return s, nil
+}
@@ -0,0 +1,79 @@
+package ruler
+
+// Clone returns a deep copy of the Ruler, with the same options,
+// so it can be changed without touching the original
+func (r *Ruler) Clone() *Ruler {
+	c := *r
+	c.rules = cloneRules(r.rules)
+
+	return &c
+}
+
+// Merge adds copies of all of other's rules to the end of r's rules
+// other's options aren't merged, r keeps its own
+func (r *Ruler) Merge(other *Ruler) *Ruler {
+	r.rules = append(r.rules, cloneRules(other.rules)...)
+	return r
+}
+
+// Clone returns a deep copy of the rule, including any rules grouped under it
+func (f *Rule) Clone() *Rule {
+	if f == nil {
+		return nil
+	}
+
+	c := *f
+	c.Value = cloneValue(f.Value)
+	c.Transforms = append([]string(nil), f.Transforms...)
+	c.Outcome = cloneValue(f.Outcome)
+	if f.Set != nil {
+		c.Set = cloneValue(f.Set).(map[string]interface{})
+	}
+	c.All = cloneRules(f.All)
+	c.Any = cloneRules(f.Any)
+	c.Not = f.Not.Clone()
+	if f.ActiveFrom != nil {
+		from := *f.ActiveFrom
+		c.ActiveFrom = &from
+	}
+	if f.ActiveUntil != nil {
+		until := *f.ActiveUntil
+		c.ActiveUntil = &until
+	}
+
+	return &c
+}
+
+func cloneRules(rules []*Rule) []*Rule {
+	if rules == nil {
+		return nil
+	}
+
+	c := make([]*Rule, len(rules))
+	for i, f := range rules {
+		c[i] = f.Clone()
+	}
+
+	return c
+}
+
+// cloneValue copies the maps and slices that JSON decoding produces,
+// anything else is shared
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			c[k] = cloneValue(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, v := range t {
+			c[i] = cloneValue(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
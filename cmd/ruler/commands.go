@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+// loadRules reads a rules file, strictly unless lax is set
+func loadRules(path string, lax bool) (*ruler.Ruler, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []ruler.Option
+	if !lax {
+		opts = append(opts, ruler.WithStrict())
+	}
+
+	r, err := ruler.NewRulerWithJSON(data, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// documents reads the documents named in paths, or one from stdin if there aren't any
+func documents(paths []string, stdin io.Reader) ([]string, []map[string]interface{}, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	docs := make([]map[string]interface{}, len(paths))
+	for i, path := range paths {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := json.Unmarshal(data, &docs[i]); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return paths, docs, nil
+}
+
+func validate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	lax := fs.Bool("lax", false, "don't load the rules strictly")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("validate needs at least one rules file")
+	}
+
+	problems := false
+	for _, path := range fs.Args() {
+		r, err := loadRules(path, *lax)
+		if err == nil {
+			_, err = r.Compile()
+		}
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			problems = true
+			continue
+		}
+
+		findings := ruler.Lint(r)
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%s: %s\n", path, f)
+		}
+		if len(findings) > 0 {
+			problems = true
+			continue
+		}
+
+		fmt.Fprintf(stdout, "%s: ok\n", path)
+	}
+
+	if problems {
+		return errNoMatch
+	}
+
+	return nil
+}
+
+func test(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rules := fs.String("rules", "", "rules file to test against")
+	quiet := fs.Bool("q", false, "only set the exit code")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rules == "" {
+		return fmt.Errorf("test needs -rules")
+	}
+
+	r, err := loadRules(*rules, false)
+	if err != nil {
+		return err
+	}
+	c, err := r.Compile()
+	if err != nil {
+		return err
+	}
+
+	names, docs, err := documents(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	all := true
+	for i, doc := range docs {
+		res := c.Evaluate(doc)
+		if !res.Matched {
+			all = false
+		}
+		if *quiet {
+			continue
+		}
+
+		switch {
+		case res.Err != nil:
+			// the document doesn't match, but the rest are still worth testing
+			fmt.Fprintf(stdout, "%s: error, %s\n", names[i], res.Err)
+		case res.Matched:
+			fmt.Fprintf(stdout, "%s: match\n", names[i])
+		case res.Message != "":
+			fmt.Fprintf(stdout, "%s: no match, %s\n", names[i], res.Message)
+		default:
+			fmt.Fprintf(stdout, "%s: no match, %s\n", names[i], res.Failed)
+		}
+	}
+
+	if !all {
+		return errNoMatch
+	}
+
+	return nil
+}
+
+// outcomes records how each top-level rule did, in the order they were tested
+type outcomes struct {
+	rules  []*ruler.Rule
+	passed map[*ruler.Rule]bool
+}
+
+func (o *outcomes) Evaluated(matched bool, err error, took time.Duration) {}
+
+func (o *outcomes) RuleTested(f *ruler.Rule, passed bool) {
+	if _, seen := o.passed[f]; !seen {
+		o.rules = append(o.rules, f)
+	}
+	o.passed[f] = passed
+}
+
+func explain(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	rules := fs.String("rules", "", "rules file to test against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rules == "" {
+		return fmt.Errorf("explain needs -rules")
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("explain takes one document")
+	}
+
+	data, err := os.ReadFile(*rules)
+	if err != nil {
+		return err
+	}
+	o := &outcomes{passed: make(map[*ruler.Rule]bool)}
+	r, err := ruler.NewRulerWithJSON(data, ruler.WithStrict(), ruler.WithInstrumentation(o))
+	if err != nil {
+		return fmt.Errorf("%s: %w", *rules, err)
+	}
+
+	_, docs, err := documents(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	m := r.EvaluateEvery(docs[0])
+	errs := make(map[*ruler.Rule]error)
+	for _, res := range m.Failures {
+		if res.Failed != nil && res.Err != nil {
+			errs[res.Failed] = res.Err
+		}
+	}
+
+	for _, f := range o.rules {
+		switch {
+		case errs[f] != nil:
+			fmt.Fprintf(stdout, "ERROR %s: %s\n", f, errs[f])
+		case o.passed[f]:
+			fmt.Fprintf(stdout, "PASS  %s\n", f)
+		default:
+			fmt.Fprintf(stdout, "FAIL  %s\n", f)
+		}
+	}
+
+	if !m.Matched {
+		return errNoMatch
+	}
+
+	return nil
+}
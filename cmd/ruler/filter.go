@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+// annotation is what -annotate adds to each line
+type annotation struct {
+	Matched bool   `json:"matched"`
+	Failed  string `json:"failed,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// filter works like grep for NDJSON: lines are written out untouched when
+// they match (or don't, with -v), and bad lines are reported on stderr and skipped
+// with -annotate every line is written, with how it did added under -key
+func filter(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	rules := fs.String("rules", "", "rules file to filter with")
+	invert := fs.Bool("v", false, "write out the lines that don't match instead")
+	annotate := fs.Bool("annotate", false, "write out every line, saying how it did")
+	key := fs.String("key", "_ruler", "property -annotate puts the outcome under")
+	maxLine := fs.Int("max-line", 1<<20, "longest line to read, in bytes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rules == "" {
+		return fmt.Errorf("filter needs -rules")
+	}
+
+	r, err := loadRules(*rules, false)
+	if err != nil {
+		return err
+	}
+	c, err := r.Compile()
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewScanner(stdin)
+	in.Buffer(make([]byte, 0, 64*1024), *maxLine)
+	// lines are flushed as they're written, so tail -f | ruler filter keeps up
+	out := bufio.NewWriter(stdout)
+
+	n, written, bad := 0, 0, 0
+	for in.Scan() {
+		n++
+		line := bytes.TrimSpace(in.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err != nil {
+			fmt.Fprintf(stderr, "ruler: line %d: %s\n", n, err)
+			bad++
+			continue
+		}
+		if doc == nil {
+			// null unmarshals without an error, but it's not a document
+			fmt.Fprintf(stderr, "ruler: line %d: not a JSON object\n", n)
+			bad++
+			continue
+		}
+
+		res := c.Evaluate(doc)
+		if *annotate {
+			doc[*key] = annotationOf(res)
+			res.Release()
+
+			enc, err := json.Marshal(doc)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			if err := writeLine(out, enc); err != nil {
+				return err
+			}
+			written++
+			continue
+		}
+
+		if res.Err != nil {
+			fmt.Fprintf(stderr, "ruler: line %d: %s\n", n, res.Err)
+			res.Release()
+			bad++
+			continue
+		}
+		keep := res.Matched != *invert
+		res.Release()
+
+		if keep {
+			if err := writeLine(out, line); err != nil {
+				return err
+			}
+			written++
+		}
+	}
+	if err := in.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", n+1, err)
+	}
+
+	if bad > 0 {
+		return fmt.Errorf("skipped %d bad lines", bad)
+	}
+	if written == 0 {
+		return errNoMatch
+	}
+
+	return nil
+}
+
+func writeLine(out *bufio.Writer, line []byte) error {
+	out.Write(line)
+	out.WriteByte('\n')
+	return out.Flush()
+}
+
+func annotationOf(res *ruler.Result) annotation {
+	a := annotation{Matched: res.Matched, Message: res.Message}
+	if res.Failed != nil {
+		a.Failed = res.Failed.ID
+		if a.Failed == "" {
+			a.Failed = res.Failed.String()
+		}
+	}
+	if res.Err != nil {
+		a.Error = res.Err.Error()
+	}
+
+	return a
+}
@@ -0,0 +1,75 @@
+/*
+ruler checks rule files and tests documents against them, from the shell or CI
+
+	ruler validate rules.json...
+		loads each rules file strictly and lints it
+	ruler test -rules rules.json doc.json...
+		tests each document (stdin if there are none) against the rules
+	ruler explain -rules rules.json doc.json
+		prints how every rule did against one document
+	ruler filter -rules rules.json < events.ndjson
+		reads newline-delimited JSON and writes out the lines that match
+
+the exit code is 0 when everything's valid or matched,
+1 when something isn't or didn't, and 2 when ruler couldn't do its job
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errNoMatch is the exit code 1 kind of failure, not the 2 kind
+var errNoMatch = errors.New("no match")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "validate":
+		err = validate(args[1:], stdout)
+	case "test":
+		err = test(args[1:], stdin, stdout)
+	case "explain":
+		err = explain(args[1:], stdin, stdout)
+	case "filter":
+		err = filter(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "ruler: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errNoMatch):
+		return 1
+	default:
+		fmt.Fprintln(stderr, "ruler:", err)
+		return 2
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `usage:
+	ruler validate [-lax] rules.json...
+	ruler test -rules rules.json [-q] [doc.json...]
+	ruler explain -rules rules.json [doc.json]
+	ruler filter -rules rules.json [-v] [-annotate [-key _ruler]]
+`)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// BenchmarkRuler runs the same benchmarks as the program, for go test -bench,
+// -benchmem and benchstat
+func BenchmarkRuler(b *testing.B) {
+	for _, bench := range benchmarks() {
+		c, err := bench.r.Compile()
+		if err != nil {
+			b.Fatalf("%s: %s", bench.name, err)
+		}
+		if ok, err := c.Test(bench.doc); !ok || err != nil {
+			b.Fatalf("%s: document doesn't pass (%v)", bench.name, err)
+		}
+
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.Test(bench.doc)
+			}
+		})
+	}
+}
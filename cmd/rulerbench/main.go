@@ -0,0 +1,138 @@
+/*
+rulerbench benchmarks the paths a Ruler spends most of its time on:
+deep paths, large rulesets, regex-heavy rules and URL blocklists
+
+	go run github.com/hopkinsth/go-ruler/cmd/rulerbench
+
+testing a compiled ruleset against a document that passes shouldn't allocate,
+so rulerbench exits non-zero if any benchmark allocates more than -max-allocs
+per document, which makes it easy to catch regressions in CI
+
+the same benchmarks run under go test too, for comparing runs with benchstat:
+
+	go test -bench . -benchmem -count 10 github.com/hopkinsth/go-ruler/cmd/rulerbench
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+type benchmark struct {
+	name string
+	r    *ruler.Ruler
+	doc  map[string]interface{}
+}
+
+func main() {
+	maxAllocs := flag.Int64("max-allocs", 0, "most allocations a single Test may make")
+	flag.Parse()
+
+	failed := false
+	for _, b := range benchmarks() {
+		c, err := b.r.Compile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", b.name, err)
+			os.Exit(1)
+		}
+		if ok, err := c.Test(b.doc); !ok || err != nil {
+			fmt.Fprintf(os.Stderr, "%s: document doesn't pass (%v)\n", b.name, err)
+			os.Exit(1)
+		}
+
+		res := testing.Benchmark(func(tb *testing.B) {
+			tb.ReportAllocs()
+			for i := 0; i < tb.N; i++ {
+				c.Test(b.doc)
+			}
+		})
+
+		fmt.Printf("%-16s %s %s\n", b.name, res.String(), res.MemString())
+		if res.AllocsPerOp() > *maxAllocs {
+			fmt.Fprintf(os.Stderr, "%s: %d allocs/op, expected at most %d\n", b.name, res.AllocsPerOp(), *maxAllocs)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func benchmarks() []benchmark {
+	return []benchmark{
+		deepPath(),
+		largeRuleset(),
+		regexHeavy(),
+		urlBlocklist(),
+	}
+}
+
+// deepPath is one rule on a property twenty levels down
+func deepPath() benchmark {
+	doc := map[string]interface{}{"value": 42.0}
+	parts := make([]string, 20)
+	for i := len(parts) - 1; i >= 0; i-- {
+		parts[i] = fmt.Sprintf("level%d", i)
+		doc = map[string]interface{}{parts[i]: doc}
+	}
+
+	r := ruler.NewRuler(nil)
+	r.Rule(strings.Join(parts, ".") + ".value").Eq(42.0)
+
+	return benchmark{"deep path", r, doc}
+}
+
+// largeRuleset is a thousand rules over a hundred properties
+func largeRuleset() benchmark {
+	doc := make(map[string]interface{})
+	r := ruler.NewRuler(nil)
+	for i := 0; i < 100; i++ {
+		user := map[string]interface{}{"score": float64(i), "name": fmt.Sprintf("user%d", i)}
+		doc[fmt.Sprintf("user%d", i)] = user
+
+		path := fmt.Sprintf("user%d.", i)
+		for j := 0; j < 5; j++ {
+			r.Rule(path + "score").Gte(float64(i - j))
+			r.Rule(path + "name").Neq(fmt.Sprintf("nobody%d", j))
+		}
+	}
+
+	return benchmark{"large ruleset", r, doc}
+}
+
+// regexHeavy is a handful of regexes over the same few strings
+func regexHeavy() benchmark {
+	doc := map[string]interface{}{
+		"email":   "jane.doe@example.com",
+		"agent":   "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		"address": "221B Baker Street, London NW1 6XE",
+	}
+
+	r := ruler.NewRuler(nil)
+	r.Rule("email").Matches(`^[a-z.]+@[a-z]+\.(com|org|net)$`)
+	r.Rule("email").NotContains(`@(spam|junk)\.`)
+	r.Rule("agent").Contains(`Chrome/\d+`)
+	r.Rule("agent").NotContains(`(?i)bot|crawler|spider`)
+	r.Rule("address").Matches(`[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)
+
+	return benchmark{"regex heavy", r, doc}
+}
+
+// urlBlocklist is two thousand URL patterns on the same path, none of which match
+func urlBlocklist() benchmark {
+	doc := map[string]interface{}{"url": "/api/v2/accounts/8812/orders?page=3&sort=created"}
+
+	r := ruler.NewRuler(nil)
+	for i := 0; i < 2000; i++ {
+		r.Rule("url").NotContains(fmt.Sprintf(`^/(api|v\d)/blocked%d/[a-z]+(\?|$)`, i))
+	}
+
+	return benchmark{"url blocklist", r, doc}
+}
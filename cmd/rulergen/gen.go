@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+// generator writes out one function per rule, and the regexes they use
+type generator struct {
+	fn      string
+	body    bytes.Buffer
+	regexes []string
+	count   int
+}
+
+func generate(rules []*ruler.Rule, pkg, fn, source string) ([]byte, error) {
+	g := &generator{fn: fn}
+
+	var names []string
+	for _, f := range rules {
+		name, err := g.rule(f)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by rulergen from %s; DO NOT EDIT.\n\n", filepath.Base(source))
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	src.WriteString("import (\n\t\"fmt\"\n")
+	if len(g.regexes) > 0 {
+		src.WriteString("\t\"regexp\"\n")
+	}
+	src.WriteString("\t\"strings\"\n)\n\n")
+
+	for i, re := range g.regexes {
+		fmt.Fprintf(&src, "var %sRe%d = regexp.MustCompile(%s)\n", g.prefix(), i, strconv.Quote(re))
+	}
+
+	fmt.Fprintf(&src, "\n// %s tests a document against the rules in %s\n", fn, filepath.Base(source))
+	fmt.Fprintf(&src, "func %s(doc map[string]interface{}) (bool, error) {\n", fn)
+	for _, name := range names {
+		fmt.Fprintf(&src, "\tif ok, err := %s(doc); err != nil || !ok {\n\t\treturn false, err\n\t}\n", name)
+	}
+	src.WriteString("\treturn true, nil\n}\n\n")
+
+	src.Write(g.body.Bytes())
+	src.WriteString(strings.ReplaceAll(helpers, "PREFIX", g.prefix()))
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated bad code: %w", err)
+	}
+
+	return out, nil
+}
+
+// prefix is what the generated helpers are named after, so two rulesets
+// generated into the same package don't clash
+func (g *generator) prefix() string {
+	return strings.ToLower(g.fn[:1]) + g.fn[1:]
+}
+
+// rule writes the function for one rule and returns its name
+func (g *generator) rule(f *ruler.Rule) (string, error) {
+	name := fmt.Sprintf("%sRule%d", g.prefix(), g.count)
+	g.count++
+
+	if f.Scheduled() {
+		return "", fmt.Errorf("rule on (%s): activation windows aren't supported", f.Path)
+	}
+	if f.Ruleset != "" {
+		return "", fmt.Errorf("ruleset %s: references to other rulesets aren't supported", f.Ruleset)
+	}
+	if f.IsGroup() {
+		return name, g.group(name, f)
+	}
+
+	if len(f.Transforms) > 0 || f.Aggregate != "" || f.Type != "" {
+		return "", fmt.Errorf("rule on (%s): transforms, aggregates and types aren't supported", f.Path)
+	}
+	if m, ok := f.Value.(map[string]interface{}); ok && (m["$param"] != nil || m["$secret"] != nil) {
+		return "", fmt.Errorf("rule on (%s): parameters and secrets aren't supported", f.Path)
+	}
+
+	pluck, err := g.pluckExpr(f.Path)
+	if err != nil {
+		return "", err
+	}
+
+	w := &g.body
+	fmt.Fprintf(w, "func %s(doc map[string]interface{}) (bool, error) {\n", name)
+	fmt.Fprintf(w, "\tv := %s\n", pluck)
+
+	switch f.Comparator {
+	case "exists":
+		w.WriteString("\treturn v != nil, nil\n}\n\n")
+		return name, nil
+	case "nexists":
+		w.WriteString("\treturn v == nil, nil\n}\n\n")
+		return name, nil
+	}
+
+	fmt.Fprintf(w, "\tif v == nil {\n\t\treturn false, fmt.Errorf(\"did not find property (%%s) on map\", %s)\n\t}\n", strconv.Quote(f.Path))
+
+	expected := "expected"
+	if f.ValuePath != "" {
+		other, err := g.pluckExpr(f.ValuePath)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\texpected := %s\n", other)
+		fmt.Fprintf(w, "\tif expected == nil {\n\t\treturn false, fmt.Errorf(\"did not find property (%%s) on map\", %s)\n\t}\n", strconv.Quote(f.ValuePath))
+	} else {
+		lit, err := literal(f.Value)
+		if err != nil && f.Comparator != "istrue" && f.Comparator != "isfalse" {
+			return "", fmt.Errorf("rule on (%s): %w", f.Path, err)
+		}
+		expected = lit
+	}
+
+	p := g.prefix()
+	switch f.Comparator {
+	case "eq":
+		fmt.Fprintf(w, "\treturn %sComparable(v) && v == interface{}(%s), nil\n", p, expected)
+	case "neq":
+		fmt.Fprintf(w, "\treturn %sNeq(v, %s), nil\n", p, expected)
+	case "gt", "gte", "lt", "lte":
+		fmt.Fprintf(w, "\treturn %sInequality(%q, v, %s)\n", p, f.Comparator, expected)
+	case "istrue", "isfalse":
+		fmt.Fprintf(w, "\tb, ok := v.(bool)\n\treturn ok && b == %v, nil\n", f.Comparator == "istrue")
+	case "regex", "matches", "contains", "ncontains":
+		re, ok := f.Value.(string)
+		if !ok || f.ValuePath != "" {
+			return "", fmt.Errorf("rule on (%s): regexes have to be literal strings", f.Path)
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			return "", fmt.Errorf("rule on (%s): %w", f.Path, err)
+		}
+		negate := ""
+		if f.Comparator == "ncontains" {
+			negate = "!"
+		}
+		fmt.Fprintf(w, "\ts, ok := v.(string)\n\tif !ok {\n\t\treturn false, fmt.Errorf(\"actual value not actually a string, bailing\")\n\t}\n")
+		fmt.Fprintf(w, "\treturn %s%sRe%d.MatchString(s), nil\n", negate, p, len(g.regexes))
+		g.regexes = append(g.regexes, re)
+	default:
+		return "", fmt.Errorf("rule on (%s): comparator %s isn't supported", f.Path, f.Comparator)
+	}
+	w.WriteString("}\n\n")
+
+	return name, nil
+}
+
+func (g *generator) group(name string, f *ruler.Rule) error {
+	var children []*ruler.Rule
+	switch {
+	case f.All != nil:
+		children = f.All
+	case f.Any != nil:
+		children = f.Any
+	default:
+		children = []*ruler.Rule{f.Not}
+	}
+
+	names := make([]string, len(children))
+	for i, child := range children {
+		var err error
+		if names[i], err = g.rule(child); err != nil {
+			return err
+		}
+	}
+
+	w := &g.body
+	fmt.Fprintf(w, "func %s(doc map[string]interface{}) (bool, error) {\n", name)
+	switch {
+	case f.All != nil:
+		for _, n := range names {
+			fmt.Fprintf(w, "\tif ok, err := %s(doc); err != nil || !ok {\n\t\treturn false, err\n\t}\n", n)
+		}
+		w.WriteString("\treturn true, nil\n")
+	case f.Any != nil:
+		for _, n := range names {
+			fmt.Fprintf(w, "\tif ok, err := %s(doc); err != nil || ok {\n\t\treturn ok, err\n\t}\n", n)
+		}
+		w.WriteString("\treturn false, nil\n")
+	default:
+		fmt.Fprintf(w, "\tok, err := %s(doc)\n\treturn !ok && err == nil, err\n", names[0])
+	}
+	w.WriteString("}\n\n")
+
+	return nil
+}
+
+// pluckExpr is the call that pulls path out of doc
+func (g *generator) pluckExpr(path string) (string, error) {
+	if strings.Contains(path, "*") || strings.Contains(path, "(") {
+		return "", fmt.Errorf("path (%s): wildcards and functions aren't supported", path)
+	}
+	if path == "$ctx" || strings.HasPrefix(path, "$ctx.") {
+		return "", fmt.Errorf("path (%s): context variables aren't supported", path)
+	}
+
+	parts := strings.Split(path, ".")
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = strconv.Quote(part)
+	}
+
+	return fmt.Sprintf("%sPluck(doc, %s)", g.prefix(), strings.Join(quoted, ", ")), nil
+}
+
+// literal writes a rule value as Go, only the scalar values JSON has are supported
+func literal(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "nil", nil
+	case string:
+		return strconv.Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", fmt.Errorf("value %v isn't supported", v)
+		}
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(v, 'g', -1, 64)), nil
+	}
+
+	return "", fmt.Errorf("value %v isn't supported, only strings, numbers, booleans and null are", v)
+}
+
+// helpers are the same for every ruleset, PREFIX is swapped for the function's name
+const helpers = `
+func PREFIXPluck(doc map[string]interface{}, parts ...string) interface{} {
+	var v interface{} = doc
+	for _, part := range parts {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if v = m[part]; v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+func PREFIXComparable(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// PREFIXNeq is neq the way a Ruler does it: anything that isn't a boolean
+// isn't equal to one, and other values have to be comparable to differ
+func PREFIXNeq(actual, expected interface{}) bool {
+	if _, ok := expected.(bool); ok {
+		_, isBool := actual.(bool)
+		return !isBool || actual != expected
+	}
+	return PREFIXComparable(actual) && (expected == nil || PREFIXComparable(expected)) && actual != expected
+}
+
+func PREFIXInequality(op string, actual, expected interface{}) (bool, error) {
+	var cmp int
+	switch a := actual.(type) {
+	case float64:
+		e, ok := expected.(float64)
+		if !ok {
+			return false, fmt.Errorf("Value types are mismatched, cannot compare values")
+		}
+		switch {
+		case a < e:
+			cmp = -1
+		case a > e:
+			cmp = 1
+		case a != e:
+			// NaN
+			return false, nil
+		}
+	case string:
+		e, ok := expected.(string)
+		if !ok {
+			return false, fmt.Errorf("Value types are mismatched, cannot compare values")
+		}
+		cmp = strings.Compare(a, e)
+	default:
+		return false, fmt.Errorf("Invalid type for inequality comparison")
+	}
+
+	switch op {
+	case "gt":
+		return cmp > 0, nil
+	case "gte":
+		return cmp >= 0, nil
+	case "lt":
+		return cmp < 0, nil
+	}
+	return cmp <= 0, nil
+}
+`
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+const genRules = `[
+	{"comparator": "eq", "path": "user.plan", "value": "pro"},
+	{"any": [
+		{"comparator": "neq", "path": "flags.beta", "value": true},
+		{"comparator": "gte", "path": "user.age", "value": 21}
+	]},
+	{"not": {"comparator": "matches", "path": "user.email", "value": "@example\\.com$"}},
+	{"comparator": "lt", "path": "order.total", "value_path": "order.limit"},
+	{"comparator": "neq", "path": "order.note", "value": null},
+	{"comparator": "nexists", "path": "banned"}
+]`
+
+var genDocs = []string{
+	`{"user": {"plan": "pro", "age": 30, "email": "a@corp.com"}, "flags": {"beta": true}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 18, "email": "a@corp.com"}, "flags": {"beta": {"on": true}}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 18, "email": "a@corp.com"}, "flags": {"beta": [true]}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 18, "email": "a@corp.com"}, "flags": {"beta": true}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 30, "email": "a@example.com"}, "flags": {"beta": false}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 30, "email": "a@corp.com"}, "flags": {"beta": "yes"}, "order": {"total": 50, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 30, "email": "a@corp.com"}, "flags": {"beta": false}, "order": {"total": 5, "limit": "10", "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 30, "email": "a@corp.com"}, "flags": {"beta": false}, "order": {"total": 5, "limit": 10, "note": {"a": 1}}}`,
+	`{"user": {"plan": "free", "age": 30, "email": "a@corp.com"}, "flags": {"beta": false}, "order": {"total": 5, "limit": 10, "note": "x"}}`,
+	`{"user": {"plan": "pro", "age": 30, "email": "a@corp.com"}, "flags": {"beta": false}, "order": {"total": 5, "limit": 10, "note": "x"}, "banned": true}`,
+	`{"user": {"age": 30}}`,
+}
+
+// the generated code is built and run on the same documents as a Ruler,
+// and has to give the same answers
+func TestGeneratedMatchesRuler(t *testing.T) {
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command to build the generated code with")
+	}
+
+	var rules []*ruler.Rule
+	if err := json.Unmarshal([]byte(genRules), &rules); err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(rules, "main", "Check", "rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module gentest\n\ngo 1.18\n",
+		"rules_gen.go": string(src),
+		"main.go": `package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(in.Bytes(), &doc); err != nil {
+			panic(err)
+		}
+		ok, err := Check(doc)
+		fmt.Println(ok, err != nil)
+	}
+}
+`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	cmd.Stdin = strings.NewReader(strings.Join(genDocs, "\n") + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the generated code: %s\n%s", err, out)
+	}
+	got := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(got) != len(genDocs) {
+		t.Fatalf("got %d answers for %d documents:\n%s", len(got), len(genDocs), out)
+	}
+
+	r := ruler.NewRuler(rules)
+	for i, d := range genDocs {
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(d), &doc); err != nil {
+			t.Fatal(err)
+		}
+		ok, err := r.Test(doc)
+		if want := fmt.Sprint(ok, err != nil); got[i] != want {
+			t.Errorf("document %d: generated code says %s, Ruler says %s (%v)", i, got[i], want, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+rulergen turns a JSON ruleset that's fixed at build time into plain Go code,
+with no reflection and regexes compiled once as package-level variables.
+
+Use it from go:generate:
+
+	//go:generate go run github.com/hopkinsth/go-ruler/cmd/rulergen -in rules.json -func CheckOrder -out rules_gen.go
+
+which writes a function
+
+	func CheckOrder(doc map[string]interface{}) (bool, error)
+
+that gives the same answers as testing the rules with a Ruler.
+It supports eq, neq, gt, gte, lt, lte, exists, nexists, regex, matches, contains,
+ncontains, istrue and isfalse, value_path and all/any/not groups.
+Anything else (wildcards, aggregates, transforms, parameters, other comparators)
+is an error, use a Ruler for those. Limits aren't enforced by the generated code.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+func main() {
+	in := flag.String("in", "", "rule JSON to read")
+	out := flag.String("out", "", "Go file to write, stdout if empty")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name for the generated code")
+	fn := flag.String("func", "Test", "name of the generated function")
+	flag.Parse()
+
+	if *in == "" || *pkg == "" {
+		fmt.Fprintln(os.Stderr, "usage: rulergen -in rules.json [-out file.go] [-pkg name] [-func Name]")
+		os.Exit(2)
+	}
+
+	if err := run(*in, *out, *pkg, *fn); err != nil {
+		fmt.Fprintln(os.Stderr, "rulergen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg, fn string) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	// either a plain array of rules or a versioned ruleset
+	var set struct {
+		Rules []*ruler.Rule `json:"rules"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, &set)
+	} else {
+		err = json.Unmarshal(data, &set.Rules)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	rules := set.Rules
+
+	// load it the usual way too, so bad rules are caught before generating anything
+	if _, err := ruler.NewRuler(rules).Compile(); err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	src, err := generate(rules, pkg, fn, in)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(out, src, 0644)
+}
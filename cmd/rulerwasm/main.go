@@ -0,0 +1,172 @@
+//go:build js && wasm
+
+/*
+rulerwasm is the evaluator built for browsers, so a rule-authoring UI can
+preview rules with exactly what the server would do
+
+	GOOS=js GOARCH=wasm go build -o ruler.wasm ./cmd/rulerwasm
+	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+
+once it's running it sets globalThis.goRuler:
+
+	const r = goRuler.compile(rulesJSON)   // {error} if they don't compile
+	r.test(docJSON)                        // true or false, or {error}
+	r.evaluate(docJSON)                    // {matched, failed, message, error}
+	r.evaluateEvery(docJSON)               // {matched, failures: [...]}
+	r.release()                            // when the UI's done with it
+	goRuler.lint(rulesJSON)                // [finding, ...]
+
+rules and documents go in as JSON strings, not JS objects, so they're parsed
+by encoding/json like they are on the server, numbers and all
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+var errDocument = errors.New("takes the document as a JSON string")
+
+func main() {
+	js.Global().Set("goRuler", js.ValueOf(map[string]interface{}{
+		"compile": js.FuncOf(compile),
+		"lint":    js.FuncOf(lint),
+	}))
+
+	// the callbacks stop working if main returns
+	select {}
+}
+
+func failure(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
+
+func compile(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return map[string]interface{}{"error": "compile takes the rules as a JSON string"}
+	}
+
+	r, err := ruler.NewRulerWithJSON([]byte(args[0].String()))
+	if err != nil {
+		return failure(err)
+	}
+	c, err := r.Compile()
+	if err != nil {
+		return failure(err)
+	}
+
+	return handle(c)
+}
+
+// handle wraps a compiled ruler up as a JS object
+func handle(c *ruler.CompiledRuler) interface{} {
+	var funcs []js.Func
+	fn := func(f func(doc map[string]interface{}) interface{}) js.Func {
+		jf := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			doc, err := document(args)
+			if err != nil {
+				return failure(err)
+			}
+			return f(doc)
+		})
+		funcs = append(funcs, jf)
+		return jf
+	}
+
+	h := map[string]interface{}{
+		"version": c.Version(),
+		"test": fn(func(doc map[string]interface{}) interface{} {
+			ok, err := c.Test(doc)
+			if err != nil {
+				return failure(err)
+			}
+			return ok
+		}),
+		"evaluate": fn(func(doc map[string]interface{}) interface{} {
+			res := c.Evaluate(doc)
+			defer res.Release()
+			return result(res)
+		}),
+		"evaluateEvery": fn(func(doc map[string]interface{}) interface{} {
+			m := c.EvaluateEvery(doc)
+			defer m.Release()
+
+			failures := make([]interface{}, len(m.Failures))
+			for i, res := range m.Failures {
+				failures[i] = result(res)
+			}
+			return map[string]interface{}{
+				"matched":  m.Matched,
+				"failures": failures,
+			}
+		}),
+	}
+
+	release := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		for _, f := range funcs {
+			f.Release()
+		}
+		return nil
+	})
+	funcs = append(funcs, release)
+	h["release"] = release
+
+	return js.ValueOf(h)
+}
+
+func document(args []js.Value) (map[string]interface{}, error) {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return nil, errDocument
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(args[0].String()), &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// result is a Result as a plain JS object, with the failed rule in its JSON form
+func result(res *ruler.Result) interface{} {
+	out := map[string]interface{}{
+		"matched": res.Matched,
+	}
+	if res.Failed != nil {
+		var failed interface{}
+		if data, err := json.Marshal(res.Failed); err == nil && json.Unmarshal(data, &failed) == nil {
+			out["failed"] = failed
+		}
+	}
+	if res.Message != "" {
+		out["message"] = res.Message
+	}
+	if res.Err != nil {
+		out["error"] = res.Err.Error()
+	}
+
+	return out
+}
+
+func lint(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return map[string]interface{}{"error": "lint takes the rules as a JSON string"}
+	}
+
+	r, err := ruler.NewRulerWithJSON([]byte(args[0].String()))
+	if err != nil {
+		return failure(err)
+	}
+
+	findings := ruler.Lint(r)
+	out := make([]interface{}, len(findings))
+	for i, f := range findings {
+		out[i] = f.String()
+	}
+
+	return out
+}
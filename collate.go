@@ -0,0 +1,91 @@
+package ruler
+
+import (
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WithCollation makes eq, neq, gt, gte, lt and lte compare strings the way
+// the given language sorts them, e.g.
+//
+//	ruler.WithCollation(language.German, collate.IgnoreCase)
+//
+// strings the collation considers the same are equal, even if their bytes differ
+func WithCollation(tag language.Tag, opts ...collate.Option) Option {
+	return func(r *Ruler) {
+		r.collation = &collation{pool: sync.Pool{
+			New: func() interface{} {
+				return collate.New(tag, opts...)
+			},
+		}}
+	}
+}
+
+// WithNormalization puts strings into the given Unicode normalization form
+// (usually norm.NFC, or norm.NFKC to also fold compatibility characters)
+// before they're compared or matched against a regex
+func WithNormalization(form norm.Form) Option {
+	return func(r *Ruler) {
+		r.normalize = &form
+	}
+}
+
+// collation hands out Collators, which can't be shared between goroutines
+type collation struct {
+	pool sync.Pool
+}
+
+func (c *collation) compare(a, b string) int {
+	col := c.pool.Get().(*collate.Collator)
+	defer c.pool.Put(col)
+
+	return col.CompareString(a, b)
+}
+
+// the comparators that normalization and collation apply to
+var stringComparators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"regex": true, "matches": true, "contains": true, "ncontains": true,
+}
+
+// normalizeStrings applies WithNormalization to the strings in a comparison
+// regexes are left as they were written
+func (r *Ruler) normalizeStrings(f *compiledRule, actual, expected interface{}) (interface{}, interface{}) {
+	if r.normalize == nil || !stringComparators[f.Comparator] {
+		return actual, expected
+	}
+
+	if s, ok := actual.(string); ok {
+		actual = r.normalize.String(s)
+	}
+
+	switch f.Comparator {
+	case "regex", "matches", "contains", "ncontains":
+		return actual, expected
+	}
+
+	if s, ok := expected.(string); ok {
+		expected = r.normalize.String(s)
+	}
+
+	return actual, expected
+}
+
+// collatedEqual compares two strings with WithCollation,
+// ok is false when that doesn't apply
+func (r *Ruler) collatedEqual(actual, expected interface{}) (equal bool, ok bool) {
+	if r.collation == nil {
+		return false, false
+	}
+
+	a, aok := actual.(string)
+	b, bok := expected.(string)
+	if !aok || !bok {
+		return false, false
+	}
+
+	return r.collation.compare(a, b) == 0, true
+}
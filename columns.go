@@ -0,0 +1,181 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Columns is a batch of documents stored a column at a time, keyed by path,
+// e.g. {"user.age": {30, 17, 45}, "user.country": {"US", "CA", "US"}}
+// every column has one value per row, nil where the row doesn't have one
+// a column can also hold objects, rules on user.age will find it in a "user" column
+type Columns map[string][]interface{}
+
+// TestColumns tests every row of a column oriented batch against the rules,
+// a rule at a time down its whole column instead of a document at a time,
+// which suits analytics pipelines filtering a lot of rows
+// the bools line up with the rows; the first error stops the whole batch
+func (r *Ruler) TestColumns(cols Columns) ([]bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.TestColumns(cols)
+}
+
+// TestColumns is the compiled version of Ruler's TestColumns
+func (c *CompiledRuler) TestColumns(cols Columns) ([]bool, error) {
+	rows, err := cols.rows()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]bool, rows)
+	for i := range matched {
+		matched[i] = true
+	}
+
+	e := c.ruler.newEvaluation(context.Background(), nil, nil)
+	defer e.release()
+
+	for _, pr := range c.index {
+		var column []interface{}
+		var rest []string
+		if pr.path != nil {
+			column, rest = cols.column(pr.path)
+		}
+
+		for _, f := range pr.rules {
+			if !f.active(e) {
+				continue
+			}
+
+			for i := range matched {
+				// rows that already failed don't need testing again
+				if !matched[i] {
+					continue
+				}
+
+				e.forget()
+
+				var ok bool
+				var err error
+				if f.IsGroup() || f.valuePath != nil {
+					// these look at more than one column, so they get the whole row
+					e.doc = cols.row(i)
+					ok, err = c.ruler.testRule(e, f)
+				} else {
+					var val interface{}
+					if val, err = cols.value(e, pr.path, column, rest, i); err == nil {
+						ok, err = c.ruler.testValue(e, f, val)
+					}
+				}
+				if err != nil {
+					return nil, fmt.Errorf("row %d: %w", i, err)
+				}
+
+				matched[i] = ok
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// rows is how many rows the columns have, which has to be the same for all of them
+func (cols Columns) rows() (int, error) {
+	rows := -1
+	for name, column := range cols {
+		if rows >= 0 && len(column) != rows {
+			return 0, fmt.Errorf("column (%s) has %d rows, expected %d", name, len(column), rows)
+		}
+		rows = len(column)
+	}
+
+	if rows < 0 {
+		return 0, nil
+	}
+
+	return rows, nil
+}
+
+// column finds the column holding a path, either one named after the whole path
+// or the longest one named after a prefix of it, with the rest of the path to walk
+func (cols Columns) column(p *fieldPath) ([]interface{}, []string) {
+	for i := len(p.keys) - 1; i >= 0; i-- {
+		if column, ok := cols[p.keys[i]]; ok {
+			return column, p.parts[i+1:]
+		}
+	}
+
+	return nil, nil
+}
+
+// value is what a row has at a path, given the column found for it
+func (cols Columns) value(e *evaluation, p *fieldPath, column []interface{}, rest []string, row int) (interface{}, error) {
+	if column == nil {
+		return nil, nil
+	}
+
+	v := resolveLazy(column[row], &e.nodes)
+	if len(rest) > 0 && v != nil {
+		v = pluckParts(v, rest, &e.nodes)
+		if e.nodes.exceeded() {
+			return nil, &LimitError{"MaxDocumentNodes", e.nodes.max}
+		}
+	}
+	if err := e.nodes.lazyErr(); err != nil {
+		return nil, fmt.Errorf("resolving (%s): %w", strings.Join(p.parts, "."), err)
+	}
+
+	return p.apply(v)
+}
+
+// row puts one row back together as a document, for the rules that need all of it
+func (cols Columns) row(i int) map[string]interface{} {
+	doc := make(map[string]interface{})
+	for name, column := range cols {
+		if column[i] == nil {
+			continue
+		}
+
+		parts := splitPath(name)
+		m := doc
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := m[part].(map[string]interface{})
+			if !ok {
+				if m[part] != nil {
+					// something's already there, leave it alone
+					m = nil
+					break
+				}
+				next = make(map[string]interface{})
+				m[part] = next
+			}
+			m = next
+		}
+		if m != nil {
+			m[parts[len(parts)-1]] = column[i]
+		}
+	}
+
+	return doc
+}
+
+// ColumnsFromRows turns documents into Columns with the given paths,
+// handy for trying out TestColumns on data you already have as documents
+func ColumnsFromRows(docs []map[string]interface{}, paths ...string) Columns {
+	cols := make(Columns, len(paths))
+	for _, path := range paths {
+		column := make([]interface{}, len(docs))
+		parts := splitPath(path)
+		for i, o := range docs {
+			column[i] = pluckParts(o, parts, nil)
+		}
+		cols[path] = column
+	}
+
+	return cols
+}
@@ -0,0 +1,520 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CompiledRuler is a snapshot of a Ruler's rules with their paths split up
+// and their regexes compiled ahead of time, so testing lots of documents
+// doesn't redo that work for every one of them
+// rules added to the Ruler after it was compiled aren't included
+type CompiledRuler struct {
+	ruler *Ruler
+	rules []*compiledRule
+	// index groups runs of rules on the same path, so each run only plucks it once
+	index []*pathRules
+	// fingerprint is worked out ahead of time when it's needed for every document
+	fingerprint string
+	// needed is every path the rules read, for TestJSON
+	needed *jsonPaths
+}
+
+// pathRules are a run of rules next to each other on one path,
+// in the order they were added
+// a group gets a pathRules all to itself, with no path
+type pathRules struct {
+	path  *fieldPath
+	rules []*compiledRule
+}
+
+// compiledRule is a Rule with everything we can work out
+// before seeing a document
+type compiledRule struct {
+	*Rule
+	path      *fieldPath
+	valuePath *fieldPath
+	re        Matcher
+	// lazy stands in for re when it's only compiled on first use
+	lazy *lazyRegexp
+	// set prefilters re along with the other regexes on the path, see regexSet
+	set    *regexSet
+	member int
+	// extractTo is the key a regex_extract rule's captures are remembered under
+	extractTo string
+	// transforms are the rule's Transforms, looked up by name
+	transforms []Transform
+	all        []*compiledRule
+	any        []*compiledRule
+	not        *compiledRule
+	// ref is the ruleset a reference is to
+	ref *CompiledRuler
+	// cov counts how the rule does, when the Ruler has a Coverage
+	cov *ruleCoverage
+	// window is when the rule's active, nil for always
+	window *window
+	// hours are a business_hours rule's, when they're known before seeing a document
+	hours *BusinessHours
+	// thresh is a threshold rule's counter, count and window
+	thresh *threshold
+}
+
+// Compile prepares the Ruler's rules for testing
+// it's what Test does on every call, so if you test
+// a lot of documents do it once and use the CompiledRuler
+func (r *Ruler) Compile() (*CompiledRuler, error) {
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+	if err := r.checkRefs(); err != nil {
+		return nil, err
+	}
+
+	c := &CompiledRuler{
+		ruler: r,
+		rules: make([]*compiledRule, len(r.rules)),
+	}
+
+	var last *pathRules
+	for i, f := range r.rules {
+		cf, err := r.compileRule(f)
+		if err != nil {
+			return nil, err
+		}
+		c.rules[i] = cf
+
+		if f.IsGroup() {
+			c.index = append(c.index, &pathRules{rules: []*compiledRule{cf}})
+			last = nil
+			continue
+		}
+
+		// only rules next to each other share a run, so rules are still
+		// tested in the order they were added, and which one fails first
+		// and what a threshold gets to count don't change
+		if last == nil || last.rules[0].Path != f.Path {
+			last = &pathRules{path: cf.path}
+			c.index = append(c.index, last)
+		}
+		last.rules = append(last.rules, cf)
+	}
+
+	c.compileRegexSets()
+
+	if r.audit != nil {
+		c.fingerprint = c.Fingerprint()
+	}
+	c.needed = c.neededPaths()
+
+	return c, nil
+}
+
+func (r *Ruler) compileRule(f *Rule) (*compiledRule, error) {
+	if f.Ref != "" {
+		return nil, fmt.Errorf("$ref (%s) wasn't expanded, they only work in rule JSON", f.Ref)
+	}
+	if f.IsGroup() {
+		return r.compileGroup(f)
+	}
+
+	cf := &compiledRule{Rule: f, cov: r.coverage.counter(f)}
+
+	var err error
+	if cf.window, err = compileWindow(f); err != nil {
+		return nil, err
+	}
+
+	if f.Type != "" && !knownTypes[f.Type] {
+		return nil, fmt.Errorf("unknown type %s on (%s)", f.Type, f.Path)
+	}
+
+	if cf.path, err = r.compilePath(f.Path); err != nil {
+		return nil, err
+	}
+	if cf.transforms, err = r.compileTransforms(f); err != nil {
+		return nil, err
+	}
+	if cf.extractTo, err = compileExtractTo(f); err != nil {
+		return nil, err
+	}
+
+	if f.ValuePath != "" {
+		if cf.valuePath, err = r.compilePath(f.ValuePath); err != nil {
+			return nil, err
+		}
+		return cf, nil
+	}
+
+	switch f.Comparator {
+	case "lookup":
+		if err := r.compileLookup(f); err != nil {
+			return nil, err
+		}
+	case "threshold":
+		if cf.thresh, err = r.compileThreshold(f); err != nil {
+			return nil, err
+		}
+	case "business_hours":
+		// hours from parameters are read when they're used
+		if _, ok := paramName(f.Value); !ok {
+			if cf.hours, err = r.businessHours(f.Value); err != nil {
+				return nil, fmt.Errorf("business hours on (%s): %w", f.Path, err)
+			}
+		}
+	case "regex", "contains", "matches", "ncontains", "regex_extract":
+		// regexes from parameters are compiled when they're used
+		if streg, ok := f.Value.(string); ok {
+			if r.lazyRegexps {
+				cf.lazy = &lazyRegexp{pattern: streg}
+				break
+			}
+			re, err := r.compileRegexp(streg)
+			if err != nil {
+				return nil, err
+			}
+			cf.re = re
+		}
+	}
+
+	return cf, nil
+}
+
+func (r *Ruler) compileGroup(f *Rule) (*compiledRule, error) {
+	if err := checkGroup(f); err != nil {
+		return nil, err
+	}
+
+	cf := &compiledRule{Rule: f, cov: r.coverage.counter(f)}
+
+	var err error
+	if cf.window, err = compileWindow(f); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case f.All != nil:
+		cf.all, err = r.compileRules(f.All)
+	case f.Any != nil:
+		cf.any, err = r.compileRules(f.Any)
+	case f.Ruleset != "":
+		cf.ref, err = r.compileRef(f)
+	default:
+		cf.not, err = r.compileRule(f.Not)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
+
+// checkGroup makes sure a group is only one kind of group
+func checkGroup(f *Rule) error {
+	if f.Path != "" || f.Comparator != "" {
+		return fmt.Errorf("rule group (%s) can't also have a path or comparator", f.ID)
+	}
+	kinds := 0
+	for _, set := range []bool{f.All != nil, f.Any != nil, f.Not != nil, f.Ruleset != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("rule group (%s) can only have one of all, any, not or ruleset", f.ID)
+	}
+
+	return nil
+}
+
+func (r *Ruler) compileRules(rules []*Rule) ([]*compiledRule, error) {
+	compiled := make([]*compiledRule, len(rules))
+	for i, f := range rules {
+		cf, err := r.compileRule(f)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = cf
+	}
+
+	return compiled, nil
+}
+
+// Test tests all the compiled rules against a document
+func (c *CompiledRuler) Test(o map[string]interface{}) (bool, error) {
+	return c.TestWithParams(o, nil)
+}
+
+// TestWithParams is the compiled version of Ruler's TestWithParams
+func (c *CompiledRuler) TestWithParams(o map[string]interface{}, params map[string]interface{}) (bool, error) {
+	e := c.ruler.newEvaluation(context.Background(), o, params)
+	defer e.release()
+
+	return c.test(e)
+}
+
+// TestContext is the compiled version of Ruler's TestContext
+func (c *CompiledRuler) TestContext(ctx context.Context, o map[string]interface{}) (bool, error) {
+	e := c.ruler.newEvaluation(ctx, o, nil)
+	defer e.release()
+
+	return c.test(e)
+}
+
+// Evaluate is the compiled version of Ruler's Evaluate
+func (c *CompiledRuler) Evaluate(o map[string]interface{}) *Result {
+	e := c.ruler.newEvaluation(context.Background(), o, nil)
+	defer e.release()
+
+	return c.evaluate(e)
+}
+
+// EvaluateContext is the compiled version of Ruler's EvaluateContext
+func (c *CompiledRuler) EvaluateContext(ctx context.Context, o map[string]interface{}) *Result {
+	e := c.ruler.newEvaluation(ctx, o, nil)
+	defer e.release()
+
+	return c.evaluate(e)
+}
+
+// TestAll is the compiled version of Ruler's TestAll
+func (c *CompiledRuler) TestAll(docs []map[string]interface{}) ([]bool, error) {
+	matched := make([]bool, len(docs))
+	for i, o := range docs {
+		ok, err := c.Test(o)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		matched[i] = ok
+	}
+
+	return matched, nil
+}
+
+// TestAllParallel is the compiled version of Ruler's TestAllParallel
+func (c *CompiledRuler) TestAllParallel(ctx context.Context, docs []map[string]interface{}, workers int) ([]bool, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// cancelled as soon as any document fails, so the other workers stop early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	matched := make([]bool, len(docs))
+	indexes := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ok, err := c.TestContext(ctx, docs[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("document %d: %w", i, err)
+						cancel()
+					})
+					continue
+				}
+				// every worker writes to its own indexes, so this is safe
+				matched[i] = ok
+			}
+		}()
+	}
+
+feed:
+	for i := range docs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return matched, nil
+}
+
+// Filter is the compiled version of Ruler's Filter
+func (c *CompiledRuler) Filter(ctx context.Context, in <-chan map[string]interface{}) (<-chan map[string]interface{}, <-chan error) {
+	out := make(chan map[string]interface{})
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		for {
+			var o map[string]interface{}
+			var ok bool
+
+			select {
+			case o, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+
+			matched, err := c.TestContext(ctx, o)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !matched {
+				continue
+			}
+
+			select {
+			case out <- o:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return out, errs
+}
+
+// EvaluateAll is the compiled version of Ruler's EvaluateAll
+func (c *CompiledRuler) EvaluateAll(docs []map[string]interface{}) []*Result {
+	results := make([]*Result, len(docs))
+	for i, o := range docs {
+		results[i] = c.Evaluate(o)
+	}
+
+	return results
+}
+
+// evaluate runs every rule against the document in e,
+// stopping at the first one that doesn't pass
+func (c *CompiledRuler) evaluate(e *evaluation) *Result {
+	var first *Result
+	c.walk(e, func(res *Result) bool {
+		first = res
+		return false
+	})
+
+	if first == nil {
+		first = newResult(Result{Matched: true})
+	}
+	first.Version = c.ruler.version
+	first.Captures = e.captures
+
+	return first
+}
+
+// test is evaluate for callers that only want the answer,
+// so a document that passes doesn't cost a Result
+func (c *CompiledRuler) test(e *evaluation) (bool, error) {
+	matched := true
+	var err error
+	c.walk(e, func(res *Result) bool {
+		matched, err = false, res.Err
+		res.Release()
+		return false
+	})
+
+	return matched, err
+}
+
+// walk runs the rules against the document in e, calling fail with a Result
+// for each rule that doesn't pass until it returns false
+// rules are tested in order, a run on the same path at a time,
+// so each run only plucks its path once
+func (c *CompiledRuler) walk(e *evaluation, fail func(*Result) bool) {
+	var o observer
+	observing := c.ruler.instrumentation != nil || c.ruler.audit != nil
+	if observing {
+		c.observe(e, &o)
+		defer o.done(fail)
+	}
+	report := func(res *Result) bool {
+		if !observing {
+			return fail(res)
+		}
+		// fail can release res, so it's noted first
+		o.failed(res)
+		o.stopped = !fail(res)
+		return !o.stopped
+	}
+
+	for _, pr := range c.index {
+		// check between paths so a slow evaluation can be abandoned
+		if err := e.ctx.Err(); err != nil {
+			report(newResult(Result{Err: err}))
+			return
+		}
+
+		if !pr.active(e) {
+			continue
+		}
+
+		var val interface{}
+		if pr.path != nil {
+			var err error
+			if val, err = e.pluck(pr.path); err != nil {
+				// every rule on the path fails, not just the first
+				for _, f := range pr.rules {
+					if !f.active(e) {
+						continue
+					}
+					f.cov.record(false, err)
+					if !report(newResult(Result{Failed: f.Rule, Err: err})) {
+						return
+					}
+				}
+				continue
+			}
+		}
+
+		for _, f := range pr.rules {
+			if !f.active(e) {
+				continue
+			}
+
+			var ok bool
+			var err error
+			if f.IsGroup() {
+				ok, err = c.ruler.testRule(e, f)
+			} else {
+				ok, err = c.ruler.testValue(e, f, val)
+			}
+			if ok && err == nil {
+				if i := c.ruler.instrumentation; i != nil {
+					i.RuleTested(f.Rule, true)
+				}
+				continue
+			}
+
+			res := newResult(Result{Failed: f.Rule, Message: c.ruler.failureMessage(e, f, val)})
+			if err != nil {
+				if res.Message != "" {
+					err = fmt.Errorf("%s: %w", res.Message, err)
+				}
+				res.Err = err
+			}
+			if !report(res) {
+				return
+			}
+		}
+	}
+}
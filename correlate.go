@@ -0,0 +1,117 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Correlation ties two sets of rules together across documents: a document
+// that matches the arming rules arms its key (the value of a shared property,
+// like a session id), and one that matches the firing rules fires if its key
+// was armed within the TTL, like "a password reset, then a login from a new
+// country in the same session within 10 minutes"
+// firing disarms the key, so it takes another arming document to fire again
+type Correlation struct {
+	name  string
+	arm   *CompiledRuler
+	fire  *CompiledRuler
+	key   *fieldPath
+	ttl   time.Duration
+	store StateStore
+	clock func() time.Time
+}
+
+var _ Tester = (*Correlation)(nil)
+
+// Correlate makes a Correlation called name, which arms on documents matching r
+// and fires on documents matching then, keyed by the property at key
+// the state is kept in store, under name, so every machine sharing a store
+// (see rulerredis) sees the same arming documents
+// the time comes from r's clock, see WithClock
+func (r *Ruler) Correlate(name string, then *Ruler, key string, ttl time.Duration, store StateStore) (*Correlation, error) {
+	if store == nil {
+		return nil, fmt.Errorf("correlation %s needs a StateStore", name)
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("correlation %s needs a TTL longer than 0", name)
+	}
+
+	arm, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+	fire, err := then.Compile()
+	if err != nil {
+		return nil, err
+	}
+	path, err := r.compilePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Correlation{
+		name:  name,
+		arm:   arm,
+		fire:  fire,
+		key:   path,
+		ttl:   ttl,
+		store: store,
+		clock: r.clock,
+	}, nil
+}
+
+// Test arms or fires on a document, and reports whether it fired
+func (c *Correlation) Test(o map[string]interface{}) (bool, error) {
+	return c.TestContext(context.Background(), o)
+}
+
+// TestContext is Test with a context, which the rules and the store are called with
+// a document is checked against the firing rules before the arming ones,
+// so a document that matches both fires on an earlier one's arming, then arms again
+func (c *Correlation) TestContext(ctx context.Context, o map[string]interface{}) (bool, error) {
+	e := c.arm.ruler.newEvaluation(ctx, o, nil)
+	v, err := e.pluck(c.key)
+	e.release()
+	if err != nil {
+		return false, fmt.Errorf("correlation %s: %w", c.name, err)
+	}
+	if v == nil {
+		// a document without a key can't be tied to any other
+		return false, nil
+	}
+	key := stateKey(c.name, v)
+
+	now := time.Now()
+	if c.clock != nil {
+		now = c.clock()
+	}
+
+	fired := false
+	ok, err := c.fire.TestContext(ctx, o)
+	if err != nil {
+		return false, fmt.Errorf("correlation %s: %w", c.name, err)
+	}
+	if ok {
+		if fired, err = c.store.Disarm(ctx, key, now); err != nil {
+			return false, fmt.Errorf("correlation %s: %w", c.name, err)
+		}
+	}
+
+	ok, err = c.arm.TestContext(ctx, o)
+	if err != nil {
+		return fired, fmt.Errorf("correlation %s: %w", c.name, err)
+	}
+	if ok {
+		if err := c.store.Arm(ctx, key, now, c.ttl); err != nil {
+			return fired, fmt.Errorf("correlation %s: %w", c.name, err)
+		}
+	}
+
+	return fired, nil
+}
+
+// Name is what the Correlation was called
+func (c *Correlation) Name() string {
+	return c.name
+}
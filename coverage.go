@@ -0,0 +1,160 @@
+package ruler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Coverage keeps track of how every rule, nested ones included, has done across
+// all the documents tested, so rules that never pass (or never fail) can be found
+// and pruned; unlike Instrumentation it counts rules inside groups too
+// one Coverage can be shared by any number of Rulers and goroutines
+type Coverage struct {
+	mu    sync.Mutex
+	rules map[*Rule]*ruleCoverage
+	// order is the order rules were first compiled in, for the report
+	order []*Rule
+}
+
+type ruleCoverage struct {
+	passed atomic.Int64
+	failed atomic.Int64
+	errors atomic.Int64
+}
+
+// NewCoverage makes an empty Coverage
+func NewCoverage() *Coverage {
+	return &Coverage{rules: make(map[*Rule]*ruleCoverage)}
+}
+
+// WithCoverage records how each rule does in cov
+// every rule is in cov's Report once it's compiled, even if it's never tested
+func WithCoverage(cov *Coverage) Option {
+	return func(r *Ruler) {
+		r.coverage = cov
+	}
+}
+
+// counter is f's counts, made the first time f's compiled
+// a nil Coverage hands back a nil counter, which doesn't count
+func (c *Coverage) counter(f *Rule) *ruleCoverage {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rc, ok := c.rules[f]
+	if !ok {
+		rc = &ruleCoverage{}
+		c.rules[f] = rc
+		c.order = append(c.order, f)
+	}
+
+	return rc
+}
+
+func (rc *ruleCoverage) record(passed bool, err error) {
+	switch {
+	case rc == nil:
+	case err != nil:
+		rc.errors.Add(1)
+	case passed:
+		rc.passed.Add(1)
+	default:
+		rc.failed.Add(1)
+	}
+}
+
+// RuleCoverage is how one rule did
+type RuleCoverage struct {
+	Rule   *Rule
+	Passed int64
+	Failed int64
+	// Errors counts the times the rule couldn't be tested
+	Errors int64
+}
+
+// Tested is how many times the rule was tested
+func (rc RuleCoverage) Tested() int64 {
+	return rc.Passed + rc.Failed + rc.Errors
+}
+
+// PassRate is the fraction of the times the rule was tested that it passed
+func (rc RuleCoverage) PassRate() float64 {
+	if rc.Tested() == 0 {
+		return 0
+	}
+
+	return float64(rc.Passed) / float64(rc.Tested())
+}
+
+// CoverageReport is every rule's coverage, in the order they were compiled
+type CoverageReport []RuleCoverage
+
+// Report returns the counts so far
+func (c *Coverage) Report() CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := make(CoverageReport, len(c.order))
+	for i, f := range c.order {
+		rc := c.rules[f]
+		report[i] = RuleCoverage{
+			Rule:   f,
+			Passed: rc.passed.Load(),
+			Failed: rc.failed.Load(),
+			Errors: rc.errors.Load(),
+		}
+	}
+
+	return report
+}
+
+// NeverPassed lists the rules that were never tested, or never passed when they were
+func (cr CoverageReport) NeverPassed() []*Rule {
+	return cr.rules(func(rc RuleCoverage) bool { return rc.Passed == 0 })
+}
+
+// NeverFailed lists the rules that were tested and always passed,
+// which might not be checking anything
+func (cr CoverageReport) NeverFailed() []*Rule {
+	return cr.rules(func(rc RuleCoverage) bool { return rc.Tested() > 0 && rc.Failed == 0 && rc.Errors == 0 })
+}
+
+// NeverTested lists the rules that were never tested at all,
+// usually because a rule before them in a group always decided it first
+func (cr CoverageReport) NeverTested() []*Rule {
+	return cr.rules(func(rc RuleCoverage) bool { return rc.Tested() == 0 })
+}
+
+func (cr CoverageReport) rules(keep func(RuleCoverage) bool) []*Rule {
+	var rules []*Rule
+	for _, rc := range cr {
+		if keep(rc) {
+			rules = append(rules, rc.Rule)
+		}
+	}
+
+	return rules
+}
+
+// String lays the report out a line per rule, e.g.
+//
+//	passed   312/400  78.0%  errors 0   age must be ≥ 18
+func (cr CoverageReport) String() string {
+	var b strings.Builder
+	for _, rc := range cr {
+		name := rc.Rule.ID
+		if name == "" {
+			name = rc.Rule.String()
+		}
+		fmt.Fprintf(&b, "passed %8d/%-8d %5.1f%%  errors %-4d %s\n",
+			rc.Passed, rc.Tested(), rc.PassRate()*100, rc.Errors, name)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package ruler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVType says how the cells in a CSV column are turned into values
+type CSVType string
+
+const (
+	// CSVAuto decodes cells holding JSON (numbers, true, "quoted strings") as JSON,
+	// and uses anything else as a plain string, it's what columns without a type get
+	CSVAuto   CSVType = ""
+	CSVString CSVType = "string"
+	CSVNumber CSVType = "number"
+	CSVBool   CSVType = "bool"
+	// CSVTime cells are RFC 3339 timestamps
+	CSVTime CSVType = "time"
+)
+
+// CSVReader reads documents out of CSV, one per row
+// the first row is the header, and each column's name is the path
+// its cells go in, so a "user.age" column makes {"user": {"age": 30}}
+// empty cells are left out of the document, as missing
+type CSVReader struct {
+	r     *csv.Reader
+	paths [][]string
+	names []string
+	types []CSVType
+}
+
+// CSVError is a cell that couldn't be turned into its column's type
+type CSVError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	return fmt.Sprintf("line %d, column %s: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVError) Unwrap() error {
+	return e.Err
+}
+
+// NewCSVReader reads the header row from in
+// types gives some columns, by name, a type, the rest are CSVAuto
+func NewCSVReader(in io.Reader, types map[string]CSVType) (*CSVReader, error) {
+	cr := &CSVReader{r: csv.NewReader(in)}
+	cr.r.ReuseRecord = true
+
+	header, err := cr.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range header {
+		name = strings.TrimSpace(name)
+		t := types[name]
+		switch t {
+		case CSVAuto, CSVString, CSVNumber, CSVBool, CSVTime:
+		default:
+			return nil, fmt.Errorf("unknown CSV type %s for column %s", t, name)
+		}
+
+		cr.names = append(cr.names, name)
+		cr.paths = append(cr.paths, splitPath(name))
+		cr.types = append(cr.types, t)
+	}
+	for name := range types {
+		if !cr.has(name) {
+			return nil, fmt.Errorf("CSV has no column %s", name)
+		}
+	}
+
+	return cr, nil
+}
+
+func (cr *CSVReader) has(name string) bool {
+	for _, n := range cr.names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read returns the next row's document, and io.EOF after the last one
+// a cell that isn't its column's type is a *CSVError, and
+// the next Read carries on with the row after it
+func (cr *CSVReader) Read() (map[string]interface{}, error) {
+	record, err := cr.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]interface{}, len(record))
+	for i, cell := range record {
+		if i >= len(cr.paths) {
+			break
+		}
+
+		v, err := cr.types[i].value(cell)
+		if err != nil {
+			line, _ := cr.r.FieldPos(i)
+			return nil, &CSVError{Line: line, Column: cr.names[i], Err: err}
+		}
+		if v != nil {
+			setPath(doc, cr.paths[i], v)
+		}
+	}
+
+	return doc, nil
+}
+
+// Line is the line the last row read started on
+func (cr *CSVReader) Line() int {
+	line, _ := cr.r.FieldPos(0)
+	return line
+}
+
+func (t CSVType) value(cell string) (interface{}, error) {
+	if t != CSVString {
+		cell = strings.TrimSpace(cell)
+	}
+	if cell == "" {
+		return nil, nil
+	}
+
+	switch t {
+	case CSVString:
+		return cell, nil
+	case CSVNumber:
+		return strconv.ParseFloat(cell, 64)
+	case CSVBool:
+		return strconv.ParseBool(cell)
+	case CSVTime:
+		return time.Parse(time.RFC3339Nano, cell)
+	}
+
+	return csvValue(cell), nil
+}
+
+// setPath puts v at path in doc, making the objects along the way
+func setPath(doc map[string]interface{}, path []string, v interface{}) {
+	for _, part := range path[:len(path)-1] {
+		next, ok := doc[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			doc[part] = next
+		}
+		doc = next
+	}
+
+	doc[path[len(path)-1]] = v
+}
+
+// EvaluateCSV tests every row of some CSV against the rules, see NewCSVReader,
+// calling fn with the line each row starts on and every problem with it
+// a row with a cell that isn't its column's type fails with the *CSVError as its Err
+// m is released when fn returns, and a non-nil error from fn stops the reading
+func (r *Ruler) EvaluateCSV(in io.Reader, types map[string]CSVType, fn func(line int, m *MultiResult) error) error {
+	c, err := r.Compile()
+	if err != nil {
+		return err
+	}
+
+	return c.EvaluateCSV(in, types, fn)
+}
+
+// EvaluateCSV is the compiled version of Ruler's EvaluateCSV
+func (c *CompiledRuler) EvaluateCSV(in io.Reader, types map[string]CSVType, fn func(line int, m *MultiResult) error) error {
+	cr, err := NewCSVReader(in, types)
+	if err != nil {
+		return err
+	}
+
+	for {
+		doc, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var m *MultiResult
+		var cellErr *CSVError
+		switch {
+		case errors.As(err, &cellErr):
+			m = multiResults.Get().(*MultiResult)
+			m.Failures = append(m.Failures, newResult(Result{Err: cellErr}))
+			m.Version = c.ruler.version
+		case err != nil:
+			return err
+		default:
+			m = c.EvaluateEvery(doc)
+		}
+
+		err = fn(cr.Line(), m)
+		m.Release()
+		if err != nil {
+			return err
+		}
+	}
+}
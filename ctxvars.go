@@ -0,0 +1,73 @@
+package ruler
+
+import "context"
+
+// the first segment of a path that reads a context variable instead of the document
+const ctxPrefix = "$ctx"
+
+type ctxVarsKey struct{}
+
+// ContextWithVars attaches vars to ctx, for rules to read as $ctx.name
+// when they're tested with TestContext or EvaluateContext, e.g.
+//
+//	{"comparator": "eq", "path": "$ctx.region", "value": "eu-west-1"}
+//
+// so ambient facts like the region or the request id don't have to be
+// added to the document; vars from an outer context are kept unless vars replaces them
+// $ctx.now is the time of the evaluation, see WithClock, unless vars has its own
+func ContextWithVars(ctx context.Context, vars map[string]interface{}) context.Context {
+	merged := make(map[string]interface{}, len(vars))
+	for k, v := range ContextVars(ctx) {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, ctxVarsKey{}, merged)
+}
+
+// ContextVars are the vars attached to ctx with ContextWithVars
+func ContextVars(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	vars, _ := ctx.Value(ctxVarsKey{}).(map[string]interface{})
+	return vars
+}
+
+// readsContext reports whether either of f's paths is a $ctx path,
+// which exporters can't turn into anything that reads the same thing
+func readsContext(f *Rule) bool {
+	isCtx := func(path string) bool {
+		inner, _ := pathFunctions(path)
+		parts := splitPath(inner)
+		return len(parts) > 0 && parts[0] == ctxPrefix
+	}
+
+	return isCtx(f.Path) || isCtx(f.ValuePath)
+}
+
+// contextVar plucks a $ctx path from the context's vars
+// a var that isn't there is nil, like a missing property
+func (e *evaluation) contextVar(p *fieldPath) (interface{}, error) {
+	if len(p.parts) < 2 {
+		return p.apply(ContextVars(e.ctx))
+	}
+
+	v, ok := ContextVars(e.ctx)[p.parts[1]]
+	if !ok && p.parts[1] == "now" {
+		v = e.now()
+	}
+	if v != nil && len(p.parts) > 2 {
+		v = pluckParts(v, p.parts[2:], &e.nodes)
+		if e.nodes.exceeded() {
+			return nil, &LimitError{"MaxDocumentNodes", e.nodes.max}
+		}
+		if err := e.nodes.lazyErr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.apply(v)
+}
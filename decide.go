@@ -0,0 +1,76 @@
+package ruler
+
+import "context"
+
+// DecisionMode is how Decide picks outcomes when more than one rule matches
+type DecisionMode int
+
+const (
+	// FirstMatch returns the outcome of the first matching rule
+	FirstMatch DecisionMode = iota
+	// AllMatches returns a []interface{} of every matching rule's outcome, in order
+	AllMatches
+)
+
+// WithDecisionMode sets how Decide picks outcomes, FirstMatch by default
+func WithDecisionMode(mode DecisionMode) Option {
+	return func(r *Ruler) {
+		r.decisionMode = mode
+	}
+}
+
+// WithDefaultOutcome sets the outcome Decide returns when no rules match
+func WithDefaultOutcome(outcome interface{}) Option {
+	return func(r *Ruler) {
+		r.defaultOutcome = outcome
+	}
+}
+
+// Decide tests the document against each top-level rule that has an Outcome,
+// in order, and returns the outcome(s) of the rules that match
+// top-level rules without an Outcome are ignored
+// when nothing matches, matched is false and the default outcome is returned
+func (r *Ruler) Decide(o map[string]interface{}) (outcome interface{}, matched bool, err error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return c.Decide(o)
+}
+
+// Decide is the compiled version of Ruler's Decide
+func (c *CompiledRuler) Decide(o map[string]interface{}) (outcome interface{}, matched bool, err error) {
+	e := c.ruler.newEvaluation(context.Background(), o, nil)
+	defer e.release()
+
+	var outcomes []interface{}
+	for _, f := range c.rules {
+		if f.Outcome == nil || !f.active(e) {
+			continue
+		}
+
+		if err := e.ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		ok, err := c.ruler.testRule(e, f)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			continue
+		}
+
+		if c.ruler.decisionMode == FirstMatch {
+			return f.Outcome, true, nil
+		}
+		outcomes = append(outcomes, f.Outcome)
+	}
+
+	if len(outcomes) > 0 {
+		return outcomes, true, nil
+	}
+
+	return c.ruler.defaultOutcome, false, nil
+}
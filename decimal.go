@@ -0,0 +1,101 @@
+package ruler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// DecimalCompare compares two decimal values, returning a negative number, zero or
+// a positive number like strings.Compare, for rules with "type": "decimal"
+// the values are whatever the document and rule hold, usually strings or float64s
+type DecimalCompare func(a, b interface{}) (int, error)
+
+// WithDecimalCompare replaces the math/big comparison used for decimal rules,
+// to use the same decimal library as the rest of your code
+func WithDecimalCompare(fn DecimalCompare) Option {
+	return func(r *Ruler) {
+		r.decimalCompare = fn
+	}
+}
+
+// the types a rule can say its values are
+var knownTypes = map[string]bool{"decimal": true}
+
+// compareDecimal handles the comparisons on a "type": "decimal" rule,
+// decided is false for anything else
+func (r *Ruler) compareDecimal(f *compiledRule, actual, expected interface{}) (result bool, decided bool, err error) {
+	if f.Type != "decimal" {
+		return false, false, nil
+	}
+	op, ok := nativeComparators[f.Comparator]
+	if !ok {
+		return false, false, nil
+	}
+
+	cmp := r.decimalCompare
+	if cmp == nil {
+		cmp = compareRats
+	}
+
+	c, err := cmp(actual, expected)
+	if err != nil {
+		return false, true, fmt.Errorf("decimal on (%s): %w", f.Path, err)
+	}
+
+	switch op {
+	case eq:
+		return c == 0, true, nil
+	case neq:
+		return c != 0, true, nil
+	}
+
+	return ordered(op, c), true, nil
+}
+
+// compareRats compares two values exactly with big.Rat
+func compareRats(a, b interface{}) (int, error) {
+	x, err := toRat(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toRat(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return x.Cmp(y), nil
+}
+
+// toRat reads a decimal from a string like "19.99", a json.Number, or a number
+// floats are read back from their shortest decimal form (what JSON had),
+// not their exact binary value, so 0.1 is 1/10
+func toRat(v interface{}) (*big.Rat, error) {
+	var s string
+	switch n := v.(type) {
+	case string:
+		s = n
+	case json.Number:
+		s = n.String()
+	case *big.Rat:
+		return n, nil
+	case *big.Int:
+		return new(big.Rat).SetInt(n), nil
+	case *big.Float:
+		s = n.Text('g', -1)
+	default:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't a decimal", v)
+		}
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%q isn't a decimal", s)
+	}
+
+	return rat, nil
+}
@@ -0,0 +1,119 @@
+package ruler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// the start of a $ref to one of a ruleset's definitions
+const definitionRef = "#/definitions/"
+
+// expandRefs replaces every {"$ref": "#/definitions/name"} in rules with a copy
+// of the definition it names, so a fragment like a valid address can be declared
+// once in a ruleset's definitions and used from as many groups as need it
+//
+//	{"definitions": {"valid_us_address": {"all": [...]}},
+//	 "rules": [{"any": [{"$ref": "#/definitions/valid_us_address"}, ...]}]}
+//
+// definitions can refer to each other, but not in a circle
+func expandRefs(rules []*Rule, defs map[string]*Rule) ([]*Rule, error) {
+	x := &refExpander{defs: defs, expanded: make(map[string]*Rule), expanding: make(map[string]bool)}
+
+	// every definition is checked, even the ones nothing uses yet
+	for name := range defs {
+		if _, err := x.definition(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return x.rules(rules, nil)
+}
+
+type refExpander struct {
+	defs map[string]*Rule
+	// expanded are the definitions with their own refs expanded
+	expanded map[string]*Rule
+	// expanding are the definitions on the way to the current one
+	expanding map[string]bool
+}
+
+func (x *refExpander) rules(rules []*Rule, trail []string) ([]*Rule, error) {
+	for i, f := range rules {
+		expanded, err := x.rule(f, trail)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = expanded
+	}
+
+	return rules, nil
+}
+
+func (x *refExpander) rule(f *Rule, trail []string) (*Rule, error) {
+	if f.Ref != "" {
+		if !reflect.DeepEqual(*f, Rule{Ref: f.Ref}) {
+			return nil, fmt.Errorf("$ref (%s) can't have other fields", f.Ref)
+		}
+		if !strings.HasPrefix(f.Ref, definitionRef) {
+			return nil, fmt.Errorf("$ref (%s) has to start with %s", f.Ref, definitionRef)
+		}
+		def, err := x.definition(strings.TrimPrefix(f.Ref, definitionRef), trail)
+		if err != nil {
+			return nil, err
+		}
+		return def.Clone(), nil
+	}
+
+	var err error
+	if f.All, err = x.rules(f.All, trail); err != nil {
+		return nil, err
+	}
+	if f.Any, err = x.rules(f.Any, trail); err != nil {
+		return nil, err
+	}
+	if f.Not != nil {
+		if f.Not, err = x.rule(f.Not, trail); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// hasRef reports whether any of the rules grouped under f is a $ref
+func hasRef(f *Rule) bool {
+	for _, g := range append(append([]*Rule(nil), f.All...), f.Any...) {
+		if g.Ref != "" || hasRef(g) {
+			return true
+		}
+	}
+
+	return f.Not != nil && (f.Not.Ref != "" || hasRef(f.Not))
+}
+
+// definition is the named definition with its refs expanded
+func (x *refExpander) definition(name string, trail []string) (*Rule, error) {
+	if def, ok := x.expanded[name]; ok {
+		return def, nil
+	}
+
+	trail = append(trail[:len(trail):len(trail)], name)
+	if x.expanding[name] {
+		return nil, fmt.Errorf("definitions refer to each other in a circle: %s", strings.Join(trail, " -> "))
+	}
+	def := x.defs[name]
+	if def == nil {
+		return nil, fmt.Errorf("unknown definition %s", name)
+	}
+
+	x.expanding[name] = true
+	def, err := x.rule(def, trail)
+	delete(x.expanding, name)
+	if err != nil {
+		return nil, err
+	}
+	x.expanded[name] = def
+
+	return def, nil
+}
@@ -0,0 +1,181 @@
+package ruler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// String renders the rule as readable text, e.g.
+//
+//	user.age must be ≥ 21
+//
+// groups are rendered in parentheses, joined with AND or OR
+func (f *Rule) String() string {
+	switch {
+	case f.All != nil:
+		return describeGroup(f.All, " AND ", "(always true)")
+	case f.Any != nil:
+		return describeGroup(f.Any, " OR ", "(never true)")
+	case f.Not != nil:
+		if f.Not.IsGroup() {
+			return "NOT " + f.Not.String()
+		}
+		return "NOT (" + f.Not.String() + ")"
+	case f.Ruleset != "":
+		return "(matches ruleset " + f.Ruleset + ")"
+	}
+
+	subject := f.Path
+	for _, t := range f.Transforms {
+		subject = fmt.Sprintf("%s(%s)", t, subject)
+	}
+	if f.Aggregate != "" {
+		subject = fmt.Sprintf("%s of %s", f.Aggregate, subject)
+	}
+
+	return subject + " " + describeCondition(f)
+}
+
+// Describe renders all the Ruler's rules as readable text, joined with AND
+func (r *Ruler) Describe() string {
+	parts := make([]string, len(r.rules))
+	for i, f := range r.rules {
+		parts[i] = f.String()
+	}
+
+	return strings.Join(parts, " AND ")
+}
+
+func describeGroup(rules []*Rule, join, empty string) string {
+	if len(rules) == 0 {
+		return empty
+	}
+
+	parts := make([]string, len(rules))
+	for i, f := range rules {
+		parts[i] = f.String()
+	}
+
+	return "(" + strings.Join(parts, join) + ")"
+}
+
+func describeCondition(f *Rule) string {
+	v := describeValue(f)
+
+	switch f.Comparator {
+	case "eq":
+		return "must equal " + v
+	case "neq":
+		return "must not equal " + v
+	case "gt":
+		return "must be > " + v
+	case "gte":
+		return "must be ≥ " + v
+	case "lt":
+		return "must be < " + v
+	case "lte":
+		return "must be ≤ " + v
+	case "is_email":
+		return "must be an email address"
+	case "is_url":
+		if f.Value != nil {
+			return "must be a URL with scheme " + v
+		}
+		return "must be a URL"
+	case "mod":
+		if m, ok := asMap(f.Value); ok {
+			rem := m["remainder"]
+			if rem == nil {
+				rem = 0
+			}
+			return fmt.Sprintf("must be %v mod %v", rem, m["divisor"])
+		}
+		return "must be divisible by " + v
+	case "lookup":
+		return "must pass lookup " + v
+	case "business_hours":
+		if name, ok := f.Value.(string); ok {
+			return "must be during business hours " + name
+		}
+		return "must be during business hours"
+	case "threshold":
+		if m, ok := asMap(f.Value); ok {
+			return fmt.Sprintf("must be seen more than %v times within %v", m["count"], m["within"])
+		}
+		return "must be over a threshold"
+	case "bitand_any":
+		return "must have any of the bits in " + v
+	case "bitand_all":
+		return "must have all of the bits in " + v
+	case "mime_type":
+		return "must be a content type matching " + v
+	case "extension":
+		return "must have extension " + v
+	case "is_uuid":
+		return "must be a UUID"
+	case "soundex", "metaphone":
+		return "must sound like " + v
+	case "istrue":
+		return "must be true"
+	case "isfalse":
+		return "must be false"
+	case "exists":
+		return "must exist"
+	case "nexists":
+		return "must not exist"
+	case "regex", "matches", "contains", "regex_extract":
+		return "must match " + v
+	case "ncontains":
+		return "must not match " + v
+	case "approx_eq":
+		return "must be about " + v
+	case "deep_eq":
+		return "must be exactly " + v
+	case "deep_neq":
+		return "must not be exactly " + v
+	case "supermap":
+		return "must contain " + v
+	case "subset":
+		return "must only contain values from " + v
+	case "superset":
+		return "must contain all of " + v
+	case "intersects":
+		return "must contain any of " + v
+	case "haskey":
+		return "must have key " + v
+	case "percent":
+		if pct, ok := rolloutPercent(f.Value); ok {
+			return fmt.Sprintf("must be in the %v%% rollout", pct)
+		}
+		return "must be in the rollout " + v
+	default:
+		return fmt.Sprintf("must pass %s %s", f.Comparator, v)
+	}
+}
+
+// describeValue renders what a rule compares against
+func describeValue(f *Rule) string {
+	if f.ValuePath != "" {
+		return f.ValuePath
+	}
+
+	if name, ok := paramName(f.Value); ok {
+		return "the " + name + " parameter"
+	}
+
+	if name, ok := secretName(f.Value); ok {
+		return "the " + name + " secret"
+	}
+
+	if s, ok := f.Value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+
+	b, err := json.Marshal(f.Value)
+	if err != nil {
+		return fmt.Sprint(f.Value)
+	}
+
+	return string(b)
+}
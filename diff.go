@@ -0,0 +1,159 @@
+package ruler
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind says what happened to a rule between two rulesets
+type ChangeKind string
+
+// the kinds of Change that Diff reports
+const (
+	RuleAdded    ChangeKind = "added"
+	RuleRemoved  ChangeKind = "removed"
+	RuleModified ChangeKind = "modified"
+)
+
+// A Change is one difference Diff found between two rulesets
+type Change struct {
+	Kind ChangeKind
+	// Before is the rule in the old ruleset and After the one in the new,
+	// Before is nil for an added rule and After for a removed one
+	Before *Rule
+	After  *Rule
+	// Fields are what changed on a modified rule
+	Fields []FieldChange
+}
+
+// FieldChange is one field of a rule that changed, by its name in JSON
+// Before and After are its values as JSON would have them, nil if it wasn't set
+type FieldChange struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case RuleAdded:
+		return "added " + ruleName(c.After)
+	case RuleRemoved:
+		return "removed " + ruleName(c.Before)
+	}
+
+	fields := make([]string, len(c.Fields))
+	for i, fc := range c.Fields {
+		fields[i] = fc.String()
+	}
+	return fmt.Sprintf("modified %s: %s", ruleName(c.Before), strings.Join(fields, ", "))
+}
+
+func (fc FieldChange) String() string {
+	return fmt.Sprintf("%s %s → %s", fc.Field, diffValue(fc.Before), diffValue(fc.After))
+}
+
+// ruleName is how a rule's named in a Change, its ID if it has one
+func ruleName(f *Rule) string {
+	if f.ID != "" {
+		return fmt.Sprintf("(%s) %s", f.ID, f)
+	}
+
+	return f.String()
+}
+
+func diffValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+// Diff lists how the rules in b differ from those in a, for reviewing
+// edits to a ruleset before they go out, or recording them afterwards
+// rules are paired up by ID, then unchanged rules with each other, then rules
+// on the same path, so a rule that's edited without an ID still shows up as modified
+// removed and modified rules come first, in a's order, then the added ones in b's
+func Diff(a, b *Ruler) []Change {
+	before, after := a.rules, b.rules
+	pairs := make(map[*Rule]*Rule) // a's rule to b's
+	paired := make(map[*Rule]bool) // b's rules that have been paired
+
+	pair := func(match func(x, y *Rule) bool) {
+		for _, x := range before {
+			if pairs[x] != nil {
+				continue
+			}
+			for _, y := range after {
+				if !paired[y] && match(x, y) {
+					pairs[x], paired[y] = y, true
+					break
+				}
+			}
+		}
+	}
+	pair(func(x, y *Rule) bool { return x.ID != "" && x.ID == y.ID })
+	pair(func(x, y *Rule) bool { return x.ID == "" && y.ID == "" && len(ruleFields(x, y)) == 0 })
+	pair(func(x, y *Rule) bool {
+		return x.ID == "" && y.ID == "" && !x.IsGroup() && !y.IsGroup() && x.Path == y.Path
+	})
+
+	var changes []Change
+	for _, x := range before {
+		y := pairs[x]
+		if y == nil {
+			changes = append(changes, Change{Kind: RuleRemoved, Before: x})
+			continue
+		}
+		if fields := ruleFields(x, y); len(fields) > 0 {
+			changes = append(changes, Change{Kind: RuleModified, Before: x, After: y, Fields: fields})
+		}
+	}
+	for _, y := range after {
+		if !paired[y] {
+			changes = append(changes, Change{Kind: RuleAdded, After: y})
+		}
+	}
+
+	return changes
+}
+
+// the fields ruleFields compares, in the order they're declared on Rule
+var diffFields = []string{
+	"id", "comparator", "path", "value", "aggregate", "value_path", "transforms",
+	"type", "all", "any", "not", "message", "outcome", "weight",
+	"active_from", "active_until", "schedule", "set", "ruleset",
+	"extract_to",
+}
+
+// ruleFields lists the fields that are different on x and y
+func ruleFields(x, y *Rule) []FieldChange {
+	xm, ym := jsonFields(x), jsonFields(y)
+
+	var fields []FieldChange
+	for _, name := range diffFields {
+		if !reflect.DeepEqual(xm[name], ym[name]) {
+			fields = append(fields, FieldChange{Field: name, Before: xm[name], After: ym[name]})
+		}
+	}
+
+	return fields
+}
+
+// jsonFields is a rule's fields the way they'd come back from its JSON
+func jsonFields(f *Rule) map[string]interface{} {
+	var m map[string]interface{}
+	if data, err := json.Marshal(f); err == nil {
+		json.Unmarshal(data, &m)
+	}
+
+	return m
+}
@@ -0,0 +1,527 @@
+package ruler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+go-ruler also understands a compact expression syntax for rules,
+which is easier to write by hand than JSON:
+	age >= 21 && (country == "US" || country == "CA") && email matches ".*@corp\.com"
+
+Conditions are a path, an operator and a value. The operators are
+==, !=, >, >=, <, <= and any comparator name (matches, contains, superset, ...).
+Values are JSON literals (strings, numbers, true, false, null, arrays and objects),
+a $name parameter placeholder, or another path to compare against.
+exists(path) and !exists(path) check whether a property is there,
+ruleset(name) refers to another ruleset, see WithRulerSet,
+sum(path), avg(path), min(path), max(path) and count(path) aggregate arrays,
+other functions like len(path) and lower(path) become path functions,
+and conditions are combined with &&, || and ! (with parentheses as needed).
+Backslashes in strings that aren't JSON escapes are kept as they are,
+so regexes don't need doubled backslashes; \b is kept too, as a word boundary.
+
+IDs, outcomes, weights, transforms and types can't be written in this syntax.
+*/
+
+// NewRulerWithDSL returns a new ruler with rules parsed from the expression syntax
+func NewRulerWithDSL(expr string, opts ...Option) (*Ruler, error) {
+	rules, err := ParseDSL(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRuler(rules, opts...)
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ParseDSL parses rules from the expression syntax
+// a top-level && becomes separate rules, just like a JSON array of rules
+func ParseDSL(expr string) ([]*Rule, error) {
+	p := &dslParser{src: expr, lang: "dsl"}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	if f.All != nil && len(f.All) > 0 {
+		return f.All, nil
+	}
+
+	return []*Rule{f}, nil
+}
+
+// dslParser is also the tokenizer for the other text formats,
+// lang is what its errors are prefixed with
+type dslParser struct {
+	src  string
+	pos  int
+	lang string
+}
+
+func (p *dslParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s at offset %d", p.lang, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *dslParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes tok if it's next
+func (p *dslParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+
+	return false
+}
+
+func (p *dslParser) parseOr() (*Rule, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{f}
+	for p.accept("||") {
+		g, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, g)
+	}
+
+	if len(rules) == 1 {
+		return f, nil
+	}
+
+	return &Rule{Any: rules}, nil
+}
+
+func (p *dslParser) parseAnd() (*Rule, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{f}
+	for p.accept("&&") {
+		g, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, g)
+	}
+
+	if len(rules) == 1 {
+		return f, nil
+	}
+
+	return &Rule{All: rules}, nil
+}
+
+func (p *dslParser) parseUnary() (*Rule, error) {
+	p.skipSpace()
+	start := p.pos
+
+	if p.accept("!") && !p.accept("=") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if f.Comparator == "exists" && !f.IsGroup() {
+			f.Comparator = "nexists"
+			return f, nil
+		}
+		return &Rule{Not: f}, nil
+	}
+	p.pos = start
+
+	if p.accept("(") {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected )")
+		}
+		return f, nil
+	}
+
+	if p.acceptWord("true") {
+		return &Rule{All: []*Rule{}}, nil
+	}
+	if p.acceptWord("false") {
+		return &Rule{Any: []*Rule{}}, nil
+	}
+
+	return p.parseCondition()
+}
+
+// acceptWord consumes word if it's next and isn't the start of a longer word
+func (p *dslParser) acceptWord(word string) bool {
+	p.skipSpace()
+	start := p.pos
+	if p.readWord() == word {
+		return true
+	}
+
+	p.pos = start
+	return false
+}
+
+// readWord reads an identifier or path
+func (p *dslParser) readWord() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := rune(p.src[p.pos])
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.*[]-", c) {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	return p.src[start:p.pos]
+}
+
+var dslOperators = []struct {
+	tok, comparator string
+}{
+	// longest first, so >= wins over >
+	{">=", "gte"}, {"<=", "lte"}, {"==", "eq"}, {"!=", "neq"}, {">", "gt"}, {"<", "lt"},
+}
+
+func (p *dslParser) parseCondition() (*Rule, error) {
+	f := &Rule{}
+
+	word := p.readWord()
+	if word == "" {
+		return nil, p.errorf("expected a condition")
+	}
+
+	if p.accept("(") {
+		// exists(path), ruleset(name) or an aggregate
+		path := p.readWord()
+		if path == "" || !p.accept(")") {
+			return nil, p.errorf("expected %s(path)", word)
+		}
+		f.Path = path
+
+		switch word {
+		case "ruleset":
+			return &Rule{Ruleset: path}, nil
+		case "exists":
+			f.Comparator = "exists"
+			return f, nil
+		case "sum", "avg", "min", "max", "count":
+			f.Aggregate = word
+		default:
+			// a function in the path, like len(tags), checked when it's compiled
+			f.Path = word + "(" + path + ")"
+		}
+	} else {
+		f.Path = word
+	}
+
+	p.skipSpace()
+	for _, op := range dslOperators {
+		if p.accept(op.tok) {
+			f.Comparator = op.comparator
+			break
+		}
+	}
+	if f.Comparator == "" {
+		start := p.pos
+		name := p.readWord()
+		if !knownComparators[name] {
+			p.pos = start
+			return nil, p.errorf("expected an operator after %s", f.Path)
+		}
+		f.Comparator = name
+	}
+
+	if err := p.parseValue(f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// parseValue reads the right hand side of a condition into f
+func (p *dslParser) parseValue(f *Rule) error {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return p.errorf("expected a value")
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '"':
+		s, err := p.readString()
+		if err != nil {
+			return err
+		}
+		f.Value = s
+	case c == '$':
+		p.pos++
+		f.Value = Param(p.readWord())
+	case c == '[' || c == '{':
+		raw, err := p.readBalanced()
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(raw), &f.Value); err != nil {
+			return p.errorf("bad value %s: %v", raw, err)
+		}
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.ContainsRune("0123456789.eE+-", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return p.errorf("bad number %s", p.src[start:p.pos])
+		}
+		f.Value = n
+	default:
+		switch word := p.readWord(); word {
+		case "":
+			return p.errorf("expected a value")
+		case "true":
+			f.Value = true
+		case "false":
+			f.Value = false
+		case "null":
+			f.Value = nil
+		default:
+			// anything else is another path to compare against
+			f.ValuePath = word
+		}
+	}
+
+	return nil
+}
+
+// readString reads a quoted string, ending at the same kind of quote it started with
+// JSON escapes work, other backslashes are left alone
+func (p *dslParser) readString() (string, error) {
+	var b strings.Builder
+	quote := p.src[p.pos]
+	p.pos++
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			next := p.src[p.pos+1]
+			if next == 'u' && p.pos+6 <= len(p.src) {
+				var s string
+				if err := json.Unmarshal([]byte(`"`+p.src[p.pos:p.pos+6]+`"`), &s); err == nil {
+					b.WriteString(s)
+					p.pos += 6
+					continue
+				}
+			}
+			if esc, ok := dslEscapes[next]; ok {
+				b.WriteByte(esc)
+			} else {
+				b.WriteByte(c)
+				b.WriteByte(next)
+			}
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+// dslEscapes are the escapes strings understand, \b isn't one of them
+// since it's a word boundary far more often than a backspace
+// (write \u0008 for that)
+var dslEscapes = map[byte]byte{
+	'"': '"', '\'': '\'', '\\': '\\', '/': '/', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t',
+}
+
+// readBalanced reads a JSON array or object, up to its matching bracket
+func (p *dslParser) readBalanced() (string, error) {
+	start := p.pos
+	depth := 0
+	inString := false
+
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		if inString {
+			if c == '\\' {
+				p.pos++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start:p.pos], nil
+			}
+		}
+	}
+
+	return "", p.errorf("unterminated value")
+}
+
+// DSL renders the Ruler's rules in the expression syntax
+// IDs, outcomes, weights and transforms are left out, since the syntax can't hold them
+// a typed rule, like a decimal comparison, is an error, since leaving its type out
+// would change what it means
+func (r *Ruler) DSL() (string, error) {
+	parts := make([]string, len(r.rules))
+	for i, f := range r.rules {
+		part, err := dslRule(f, len(r.rules) > 1)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+
+	return strings.Join(parts, " && "), nil
+}
+
+// dslRule renders one rule, wrapping groups in parentheses when nested is set
+func dslRule(f *Rule, nested bool) (string, error) {
+	switch {
+	case f.All != nil:
+		return dslGroup(f.All, " && ", "true", nested)
+	case f.Any != nil:
+		return dslGroup(f.Any, " || ", "false", nested)
+	case f.Not != nil:
+		s, err := dslRule(f.Not, true)
+		if err != nil {
+			return "", err
+		}
+		return "!" + s, nil
+	case f.Ruleset != "":
+		return "ruleset(" + f.Ruleset + ")", nil
+	}
+
+	if f.Type != "" {
+		return "", fmt.Errorf("dsl: can't convert the %s type on (%s)", f.Type, f.Path)
+	}
+
+	subject := f.Path
+	if f.Aggregate != "" {
+		subject = fmt.Sprintf("%s(%s)", f.Aggregate, f.Path)
+	}
+
+	switch f.Comparator {
+	case "exists":
+		return "exists(" + f.Path + ")", nil
+	case "nexists":
+		return "!exists(" + f.Path + ")", nil
+	}
+
+	op := f.Comparator
+	for _, o := range dslOperators {
+		if o.comparator == f.Comparator {
+			op = o.tok
+			break
+		}
+	}
+
+	return subject + " " + op + " " + dslValue(f), nil
+}
+
+func dslGroup(rules []*Rule, join, empty string, nested bool) (string, error) {
+	if len(rules) == 0 {
+		return empty, nil
+	}
+
+	parts := make([]string, len(rules))
+	for i, f := range rules {
+		part, err := dslRule(f, true)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+
+	s := strings.Join(parts, join)
+	if nested || len(rules) == 1 {
+		return "(" + s + ")", nil
+	}
+
+	return s, nil
+}
+
+func dslValue(f *Rule) string {
+	if f.ValuePath != "" {
+		return f.ValuePath
+	}
+
+	if name, ok := paramName(f.Value); ok {
+		return "$" + name
+	}
+
+	return dslJSON(f.Value)
+}
+
+// dslJSON renders a value as JSON without HTML escaping,
+// with object keys sorted so the output is stable
+func dslJSON(v interface{}) string {
+	if m, ok := v.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = dslJSON(k) + ":" + dslJSON(m[k])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,98 @@
+package ruler
+
+// RemoveRule removes the rule with the given ID, wherever it's nested
+// returns false if there's no rule with that ID
+func (r *Ruler) RemoveRule(id string) bool {
+	var found bool
+	r.rules = editRules(r.rules, func(f *Rule) []*Rule {
+		if found || f.ID != id {
+			return []*Rule{f}
+		}
+		found = true
+		return nil
+	})
+
+	return found
+}
+
+// ReplaceRule swaps the rule with the given ID, wherever it's nested, for f
+// returns false if there's no rule with that ID
+func (r *Ruler) ReplaceRule(id string, f *Rule) bool {
+	var found bool
+	r.rules = editRules(r.rules, func(old *Rule) []*Rule {
+		if found || old.ID != id {
+			return []*Rule{old}
+		}
+		found = true
+		return []*Rule{f}
+	})
+
+	return found
+}
+
+// RemovePath removes every rule on the given path, wherever it's nested,
+// and returns how many were removed
+func (r *Ruler) RemovePath(path string) int {
+	removed := 0
+	r.rules = editRules(r.rules, func(f *Rule) []*Rule {
+		if f.IsGroup() || f.Path != path {
+			return []*Rule{f}
+		}
+		removed++
+		return nil
+	})
+
+	return removed
+}
+
+// editRules rebuilds a list of rules, and the groups inside it,
+// with each rule replaced by whatever edit returns for it
+// groups are edited from the outside in
+func editRules(rules []*Rule, edit func(f *Rule) []*Rule) []*Rule {
+	if rules == nil {
+		return nil
+	}
+
+	edited := make([]*Rule, 0, len(rules))
+	for _, f := range rules {
+		for _, g := range edit(f) {
+			if editGroup(g, edit) {
+				edited = append(edited, g)
+			}
+		}
+	}
+
+	return edited
+}
+
+// editGroup edits the rules inside f, reporting false when every rule in
+// f's group was removed, so the group goes along with it
+// (an emptied all or any marshals as {}, which fails every document and won't load,
+// and anything else under a not would negate an empty group)
+func editGroup(f *Rule, edit func(f *Rule) []*Rule) bool {
+	if len(f.All) > 0 {
+		if f.All = editRules(f.All, edit); len(f.All) == 0 {
+			return false
+		}
+	}
+	if len(f.Any) > 0 {
+		if f.Any = editRules(f.Any, edit); len(f.Any) == 0 {
+			return false
+		}
+	}
+
+	if f.Not != nil {
+		// a not group holds exactly one rule, so more than one
+		// coming back from edit are put in an all group
+		switch n := editRules([]*Rule{f.Not}, edit); len(n) {
+		case 0:
+			return false
+		case 1:
+			f.Not = n[0]
+		default:
+			f.Not = &Rule{All: n}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+package ruler
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// An EnvOption changes how EnvDocument reads the environment
+type EnvOption func(*envConfig)
+
+type envConfig struct {
+	prefix string
+	lower  bool
+}
+
+// EnvPrefix only reads the variables starting with prefix, and takes it off their names
+func EnvPrefix(prefix string) EnvOption {
+	return func(c *envConfig) {
+		c.prefix = prefix
+	}
+}
+
+// EnvLowercase lowercases names, so APP_DB__HOST is at db.host rather than DB.HOST
+func EnvLowercase() EnvOption {
+	return func(c *envConfig) {
+		c.lower = true
+	}
+}
+
+// EnvDocument builds a document from environment variables, given the way
+// os.Environ gives them ("NAME=value"), for rules that gate deployments or features
+// on where they're running:
+//
+//	doc := ruler.EnvDocument(os.Environ(), ruler.EnvPrefix("APP_"))
+//
+// a double underscore nests, so APP_DB__HOST=x is {"DB": {"HOST": "x"}}, and
+// when a name is both a value and has names nested under it the nested ones win
+// every value is a string, use WithNumericStrings or WithBoolStrings to compare them as more
+func EnvDocument(environ []string, opts ...EnvOption) map[string]interface{} {
+	c := &envConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// sorted, so A comes before A__B and what wins doesn't depend on the order given
+	vars := append([]string(nil), environ...)
+	sort.Strings(vars)
+
+	doc := make(map[string]interface{})
+	for _, kv := range vars {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, c.prefix) {
+			continue
+		}
+		name = strings.TrimPrefix(name, c.prefix)
+		if c.lower {
+			name = strings.ToLower(name)
+		}
+
+		path := strings.Split(name, "__")
+		if name == "" || hasEmpty(path) {
+			continue
+		}
+		setPath(doc, path, value)
+	}
+
+	return doc
+}
+
+// FlagDocument builds a document from the flags in fs, set or not,
+// with their values typed the way JSON would have them (numbers are float64s)
+// dots in flag names nest, so -db.port is {"db": {"port": 5432}}
+func FlagDocument(fs *flag.FlagSet) map[string]interface{} {
+	doc := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		path := splitPath(f.Name)
+		if hasEmpty(path) {
+			return
+		}
+
+		var v interface{} = f.Value.String()
+		if g, ok := f.Value.(flag.Getter); ok {
+			if got := g.Get(); got != nil {
+				v = jsonish(reflect.ValueOf(got))
+			}
+		}
+		setPath(doc, path, v)
+	})
+
+	return doc
+}
+
+func hasEmpty(parts []string) bool {
+	for _, p := range parts {
+		if p == "" {
+			return true
+		}
+	}
+
+	return false
+}
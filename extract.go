@@ -0,0 +1,100 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A SubmatchMatcher is a Matcher that can find capture groups too,
+// which regex_extract rules need from whatever RegexEngine compiled them
+// *regexp.Regexp is one
+type SubmatchMatcher interface {
+	Matcher
+	FindStringSubmatchIndex(s string) []int
+	SubexpNames() []string
+}
+
+// matcherFunc lets a plain function be a Matcher
+type matcherFunc func(s string) bool
+
+func (fn matcherFunc) MatchString(s string) bool {
+	return fn(s)
+}
+
+// compileExtractTo works out the key an extract_to path is remembered under
+// it has to be a plain path, there's nothing to put a wildcard's captures in
+func compileExtractTo(f *Rule) (string, error) {
+	if f.ExtractTo == "" {
+		return "", nil
+	}
+	if f.Comparator != "regex_extract" {
+		return "", fmt.Errorf("extract_to on (%s) only works with regex_extract", f.Path)
+	}
+
+	p := compilePath(f.ExtractTo)
+	if _, fns := pathFunctions(f.ExtractTo); len(fns) > 0 || p.vars || p.wild < len(p.parts) || len(p.parts) == 0 {
+		return "", fmt.Errorf("extract_to (%s) on (%s) has to be a plain path", f.ExtractTo, f.Path)
+	}
+
+	return p.keys[len(p.keys)-1], nil
+}
+
+// extract matches s against reg like regexp does, keeping its named groups
+// when it matches, see Result's Captures
+func (r *Ruler) extract(e *evaluation, f *compiledRule, reg Matcher, s string) (bool, error) {
+	sub, ok := reg.(SubmatchMatcher)
+	if !ok {
+		return false, errors.New("regex engine can't find capture groups, bailing")
+	}
+
+	var loc []int
+	matched, err := r.match(matcherFunc(func(s string) bool {
+		loc = sub.FindStringSubmatchIndex(s)
+		return loc != nil
+	}), s)
+	if !matched || err != nil {
+		return false, err
+	}
+
+	for i, name := range sub.SubexpNames() {
+		// groups that weren't part of the match are left out
+		if name == "" || 2*i+1 >= len(loc) || loc[2*i] < 0 {
+			continue
+		}
+		e.capture(f, name, s[loc[2*i]:loc[2*i+1]])
+	}
+
+	return true, nil
+}
+
+// capture keeps a named group for the Result, and under the rule's extract_to
+// path for the rules tested after it
+func (e *evaluation) capture(f *compiledRule, name, value string) {
+	if e.captures == nil {
+		e.captures = make(map[string]string)
+	}
+	e.captures[name] = value
+
+	if f.extractTo == "" {
+		return
+	}
+
+	if e.extracted == nil {
+		e.extracted = make(map[string]map[string]interface{})
+	}
+	m := e.extracted[f.extractTo]
+	if m == nil {
+		m = make(map[string]interface{})
+		e.extracted[f.extractTo] = m
+	}
+	m[name] = value
+
+	// whatever was plucked from under the path before now is out of date
+	for k := range e.resolved {
+		if strings.HasPrefix(k, f.extractTo+".") {
+			delete(e.resolved, k)
+		}
+	}
+	e.remember(f.extractTo, m)
+}
@@ -0,0 +1,89 @@
+package ruler
+
+import (
+	"errors"
+	"mime"
+	"strings"
+)
+
+// patterns reads a rule value that's either one string or an array of them
+func patterns(expected interface{}) ([]string, error) {
+	if s, ok := expected.(string); ok {
+		return []string{s}, nil
+	}
+
+	items, ok := asSlice(expected)
+	if !ok {
+		return nil, errors.New("expected value not actually a string or array of strings, bailing")
+	}
+
+	out := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.New("expected value not actually a string or array of strings, bailing")
+		}
+		out[i] = s
+	}
+
+	return out, nil
+}
+
+// mimeType checks a content type against patterns like "image/png",
+// "image/*" or "*/*", ignoring case and any parameters (; charset=utf-8)
+func mimeType(actual, expected interface{}) (bool, error) {
+	want, err := patterns(expected)
+	if err != nil {
+		return false, err
+	}
+
+	s, ok := actual.(string)
+	if !ok {
+		return false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return false, nil
+	}
+
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	for _, p := range want {
+		ptyp, psub, _ := strings.Cut(strings.ToLower(strings.TrimSpace(p)), "/")
+		if (ptyp == "*" || ptyp == typ) && (psub == "*" || psub == sub) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// hasExtension checks a file name, path or URL path against extensions
+// like "jpg" or ".tar.gz", ignoring case
+func hasExtension(actual, expected interface{}) (bool, error) {
+	want, err := patterns(expected)
+	if err != nil {
+		return false, err
+	}
+
+	s, ok := actual.(string)
+	if !ok {
+		return false, nil
+	}
+	name := strings.ToLower(s)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		// query strings and fragments aren't part of the name
+		name = name[:i]
+	}
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	for _, ext := range want {
+		ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+		if len(name) > len(ext) && strings.HasSuffix(name, ext) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,80 @@
+package ruler
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// validEmail checks for a bare address like jo@example.com,
+// without a display name or angle brackets
+func validEmail(actual interface{}) bool {
+	s, ok := actual.(string)
+	if !ok {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return false
+	}
+
+	// mail allows a domain without a dot, like jo@localhost, which is almost
+	// never what a rule means
+	at := strings.LastIndex(s, "@")
+	return strings.Contains(s[at+1:], ".")
+}
+
+// validURL checks for an absolute URL with a scheme and host
+// expected can be an array of schemes to allow, e.g. ["https"]
+func validURL(actual, expected interface{}) (bool, error) {
+	s, ok := actual.(string)
+	if !ok {
+		return false, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false, nil
+	}
+
+	if expected == nil {
+		return true, nil
+	}
+
+	schemes, ok := asSlice(expected)
+	if !ok {
+		return false, errors.New("expected value not actually an array of schemes, bailing")
+	}
+	for _, scheme := range schemes {
+		if name, ok := scheme.(string); ok && strings.EqualFold(name, u.Scheme) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validUUID checks for a UUID in its usual 8-4-4-4-12 hex form, in either case
+func validUUID(actual interface{}) bool {
+	s, ok := actual.(string)
+	if !ok || len(s) != 36 {
+		return false
+	}
+
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package ruler
+
+// A Group collects the rules for one of the All, Any or Not groups
+// when building rules programmatically, e.g.
+//
+//	engine.Any(func(g *ruler.Group) {
+//		g.Rule("country").Eq("US")
+//		g.Rule("country").Eq("CA")
+//	})
+//
+// It's not meant to be created directly.
+type Group struct {
+	ruler *Ruler
+}
+
+// Rule adds a new rule to the group for the property at `path`
+// End() on the returned RulerRule gets you back to the group's rules,
+// not the top-level Ruler
+func (g *Group) Rule(path string) *RulerRule {
+	return g.ruler.Rule(path)
+}
+
+// All adds a nested group where every rule must pass
+func (g *Group) All(build func(g *Group)) *Rule {
+	return g.ruler.All(build)
+}
+
+// Any adds a nested group where at least one rule must pass
+func (g *Group) Any(build func(g *Group)) *Rule {
+	return g.ruler.Any(build)
+}
+
+// Not adds a nested group that passes when its rules don't
+func (g *Group) Not(build func(g *Group)) *Rule {
+	return g.ruler.Not(build)
+}
+
+// All adds a group of rules that must all pass
+// returns the group's Rule so you can set its ID, Outcome or Weight
+func (r *Ruler) All(build func(g *Group)) *Rule {
+	group := &Rule{All: buildGroup(r, build)}
+	r.rules = append(r.rules, group)
+
+	return group
+}
+
+// Any adds a group of rules where at least one must pass
+// returns the group's Rule so you can set its ID, Outcome or Weight
+func (r *Ruler) Any(build func(g *Group)) *Rule {
+	group := &Rule{Any: buildGroup(r, build)}
+	r.rules = append(r.rules, group)
+
+	return group
+}
+
+// Not adds a group of rules that passes when they don't all pass
+// returns the group's Rule so you can set its ID, Outcome or Weight
+func (r *Ruler) Not(build func(g *Group)) *Rule {
+	var not *Rule
+	if rules := buildGroup(r, build); len(rules) == 1 {
+		not = rules[0]
+	} else {
+		not = &Rule{All: rules}
+	}
+
+	group := &Rule{Not: not}
+	r.rules = append(r.rules, group)
+
+	return group
+}
+
+// buildGroup runs build against a scratch Ruler and returns the rules it added
+// the scratch Ruler shares the parent's options
+func buildGroup(parent *Ruler, build func(g *Group)) []*Rule {
+	scratch := *parent
+	scratch.rules = []*Rule{}
+
+	build(&Group{&scratch})
+
+	return scratch.rules
+}
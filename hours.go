@@ -0,0 +1,257 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HolidayCalendar says which days are holidays, for business_hours rules
+// day is the time being tested in the business hours' time zone
+type HolidayCalendar interface {
+	IsHoliday(day time.Time) bool
+}
+
+// HolidayFunc lets a plain function be a HolidayCalendar
+type HolidayFunc func(day time.Time) bool
+
+// IsHoliday calls fn
+func (fn HolidayFunc) IsHoliday(day time.Time) bool {
+	return fn(day)
+}
+
+// holidaySet is the calendar Holidays makes, dates like "2024-12-25"
+type holidaySet map[string]bool
+
+func (s holidaySet) IsHoliday(day time.Time) bool {
+	return s[day.Format("2006-01-02")]
+}
+
+// Holidays is a HolidayCalendar of fixed dates, written like "2024-12-25"
+func Holidays(dates ...string) HolidayCalendar {
+	s := make(holidaySet, len(dates))
+	for _, d := range dates {
+		s[d] = true
+	}
+
+	return s
+}
+
+// BusinessHours are the times a business_hours rule passes during
+type BusinessHours struct {
+	// Days are the days of the week that are open, every day if there are none
+	Days []time.Weekday
+	// Open and Close are how long after midnight the day's hours start and end,
+	// Close before Open runs overnight, and both zero is open all day
+	Open, Close time.Duration
+	// Location is the time zone the hours are in, the timestamp's own if it's nil
+	Location *time.Location
+	// Calendar is the holidays that are closed all day, if there is one
+	Calendar HolidayCalendar
+}
+
+// Contains reports whether t falls within the hours
+// overnight hours belong to the day they open on, so a holiday
+// or a closed day shuts the early hours of the next morning too
+func (h *BusinessHours) Contains(t time.Time) bool {
+	if h.Location != nil {
+		t = t.In(h.Location)
+	}
+
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	day := t
+	switch {
+	case h.Open == h.Close:
+		// open all day
+	case h.Open < h.Close:
+		if since < h.Open || since >= h.Close {
+			return false
+		}
+	case since >= h.Open:
+		// the evening of an overnight shift
+	case since < h.Close:
+		// the morning after
+		day = t.AddDate(0, 0, -1)
+	default:
+		return false
+	}
+
+	if len(h.Days) > 0 && !hasWeekday(h.Days, day.Weekday()) {
+		return false
+	}
+
+	return h.Calendar == nil || !h.Calendar.IsHoliday(day)
+}
+
+func hasWeekday(days []time.Weekday, d time.Weekday) bool {
+	for _, day := range days {
+		if day == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithBusinessHours registers h as name, so a rule like
+//
+//	{"comparator": "business_hours", "path": "ticket.opened_at", "value": "support"}
+//
+// passes when the property's timestamp falls within them
+func WithBusinessHours(name string, h BusinessHours) Option {
+	return func(r *Ruler) {
+		if r.hours == nil {
+			r.hours = make(map[string]*BusinessHours)
+		}
+		r.hours[name] = &h
+	}
+}
+
+// WithCalendar registers cal as name, for business_hours rules
+// that give their hours in the rule, like
+//
+//	{"days": ["mon", "tue", "wed", "thu", "fri"], "open": "09:00", "close": "17:00",
+//	 "timezone": "America/New_York", "calendar": "us"}
+func WithCalendar(name string, cal HolidayCalendar) Option {
+	return func(r *Ruler) {
+		if r.calendars == nil {
+			r.calendars = make(map[string]HolidayCalendar)
+		}
+		r.calendars[name] = cal
+	}
+}
+
+// the names the days of the week can be written with in a rule
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// businessHours reads a business_hours rule's value: the name of hours
+// from WithBusinessHours, or an object with their days, open, close,
+// timezone and calendar (from WithCalendar)
+func (r *Ruler) businessHours(v interface{}) (*BusinessHours, error) {
+	if name, ok := v.(string); ok {
+		h := r.hours[name]
+		if h == nil {
+			return nil, fmt.Errorf("unknown business hours %s", name)
+		}
+		return h, nil
+	}
+
+	m, ok := asMap(v)
+	if !ok {
+		return nil, errors.New("business hours not actually a name or an object, bailing")
+	}
+
+	h := &BusinessHours{}
+	if days, found := m["days"]; found {
+		list, ok := days.([]interface{})
+		if !ok {
+			return nil, errors.New("business days not actually an array, bailing")
+		}
+		for _, d := range list {
+			day, err := weekday(d)
+			if err != nil {
+				return nil, err
+			}
+			h.Days = append(h.Days, day)
+		}
+	}
+
+	var err error
+	if h.Open, err = clockTime(m["open"]); err != nil {
+		return nil, fmt.Errorf("opening time: %w", err)
+	}
+	if h.Close, err = clockTime(m["close"]); err != nil {
+		return nil, fmt.Errorf("closing time: %w", err)
+	}
+
+	if tz, found := m["timezone"]; found {
+		name, ok := tz.(string)
+		if !ok {
+			return nil, errors.New("timezone not actually a string, bailing")
+		}
+		if h.Location, err = time.LoadLocation(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if cal, found := m["calendar"]; found {
+		name, ok := cal.(string)
+		if !ok {
+			return nil, errors.New("calendar not actually a string, bailing")
+		}
+		if h.Calendar = r.calendars[name]; h.Calendar == nil {
+			return nil, fmt.Errorf("unknown calendar %s", name)
+		}
+	}
+
+	return h, nil
+}
+
+// weekday reads a day of the week, as a name like "mon" or "Monday" or a number from 0 for Sunday
+func weekday(v interface{}) (time.Weekday, error) {
+	if s, ok := v.(string); ok {
+		name := strings.ToLower(s)
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		if d, ok := weekdayNames[name]; ok && (len(s) == 3 || strings.EqualFold(s, d.String())) {
+			return d, nil
+		}
+		return 0, fmt.Errorf("%q isn't a day of the week", s)
+	}
+
+	n, ok := toInt(v)
+	if !ok || n < 0 || n > 6 {
+		return 0, fmt.Errorf("%v isn't a day of the week", v)
+	}
+
+	return time.Weekday(n), nil
+}
+
+// clockTime reads a time of day like "09:00" or "17:30:00", nil is midnight
+func clockTime(v interface{}) (time.Duration, error) {
+	if v == nil {
+		return 0, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return 0, errors.New("not actually a string, bailing")
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	if s == "24:00" {
+		// the end of the day
+		return 24 * time.Hour, nil
+	}
+
+	return 0, fmt.Errorf("%q isn't a time of day", s)
+}
+
+// inBusinessHours runs a business_hours rule, using the hours worked out
+// when the rule was compiled unless they come from a parameter or another property
+func (r *Ruler) inBusinessHours(f *compiledRule, actual, expected interface{}) (bool, error) {
+	h := f.hours
+	if h == nil {
+		var err error
+		if h, err = r.businessHours(expected); err != nil {
+			return false, err
+		}
+	}
+
+	t, err := toTime(actual)
+	if err != nil {
+		return false, fmt.Errorf("business hours on (%s): %w", f.Path, err)
+	}
+
+	return h.Contains(t), nil
+}
@@ -0,0 +1,148 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Inference is what Infer worked out about a document
+type Inference struct {
+	// Document is a copy of the document with every derived fact set on it
+	Document map[string]interface{}
+	// Derived is every fact that was set, by its path
+	Derived map[string]interface{}
+	// Fired is the rules that set facts, in the order they first did
+	Fired []*Rule
+	// Rounds is how many times the rules were tested before nothing changed
+	Rounds int
+	// Errors are the rules that couldn't be tested in the last round,
+	// usually because they read a fact nothing derived
+	Errors []error
+}
+
+// ErrNoFixedPoint is returned by Infer when rules keep undoing each other's facts
+var ErrNoFixedPoint = errors.New("ruler: derived facts never settle")
+
+// Infer runs the rules forward: every top-level rule with a Set that matches
+// the document sets its facts on a copy of it, and the rules are tested again
+// until none of them change anything, so a rule can build on another's facts
+//
+//	[{"comparator": "gt", "path": "order.total", "value": 1000, "set": {"risk_tier": "high"}},
+//	 {"comparator": "eq", "path": "risk_tier", "value": "high", "set": {"review.required": true}}]
+//
+// each round tests every rule against the document as the last round left it,
+// then sets the facts of the ones that matched, later rules winning if two set the same path
+// a rule that can't be tested, like one reading a fact that hasn't been derived yet,
+// doesn't fire, and top-level rules without a Set are ignored
+// Limits.MaxInferenceRounds caps the rounds, and rules that go back to a set of facts
+// they've already had return ErrNoFixedPoint
+func (r *Ruler) Infer(o map[string]interface{}) (*Inference, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Infer(o)
+}
+
+// Infer is the compiled version of Ruler's Infer
+func (c *CompiledRuler) Infer(o map[string]interface{}) (*Inference, error) {
+	return c.InferContext(context.Background(), o)
+}
+
+// InferContext is Infer with a context, checked between rules
+func (c *CompiledRuler) InferContext(ctx context.Context, o map[string]interface{}) (*Inference, error) {
+	doc, _ := cloneValue(o).(map[string]interface{})
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	inf := &Inference{Document: doc, Derived: make(map[string]interface{})}
+
+	fired := make(map[*Rule]bool)
+	seen := make(map[string]bool)
+	for {
+		if max := c.ruler.limits.MaxInferenceRounds; max > 0 && inf.Rounds >= max {
+			return nil, &LimitError{"MaxInferenceRounds", max}
+		}
+		inf.Rounds++
+
+		sets, errs, err := c.inferRound(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		inf.Errors = errs
+
+		// the round's sets are merged first, later rules winning, so two rules
+		// setting the same path don't look like a change every round
+		merged := make(map[string]interface{})
+		for _, f := range sets {
+			for path, v := range f.Set {
+				merged[path] = v
+			}
+			if !fired[f.Rule] {
+				fired[f.Rule] = true
+				inf.Fired = append(inf.Fired, f.Rule)
+			}
+		}
+
+		changed := false
+		for path, v := range merged {
+			if current, ok := inf.Derived[path]; ok && reflect.DeepEqual(current, v) {
+				continue
+			}
+			v = cloneValue(v)
+			setPath(doc, splitPath(path), v)
+			inf.Derived[path] = v
+			changed = true
+		}
+		if !changed {
+			return inf, nil
+		}
+
+		// json sorts the keys, so the same facts always come out the same
+		state, err := json.Marshal(inf.Derived)
+		if err != nil {
+			return nil, err
+		}
+		if seen[string(state)] {
+			return nil, ErrNoFixedPoint
+		}
+		seen[string(state)] = true
+	}
+}
+
+// inferRound tests every rule with a Set against doc,
+// returning the ones that matched and what went wrong with the ones that couldn't be tested
+func (c *CompiledRuler) inferRound(ctx context.Context, doc map[string]interface{}) ([]*compiledRule, []error, error) {
+	e := c.ruler.newEvaluation(ctx, doc, nil)
+	defer e.release()
+
+	var matched []*compiledRule
+	var errs []error
+	for _, f := range c.rules {
+		if f.Set == nil || !f.active(e) {
+			continue
+		}
+		if err := e.ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		ok, err := c.ruler.testRule(e, f)
+		if err != nil {
+			var limit *LimitError
+			if errors.As(err, &limit) {
+				return nil, nil, err
+			}
+			errs = append(errs, fmt.Errorf("rule (%s) on (%s): %w", f.ID, f.Path, err))
+			continue
+		}
+		if ok {
+			matched = append(matched, f)
+		}
+	}
+
+	return matched, errs, nil
+}
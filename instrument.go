@@ -0,0 +1,181 @@
+package ruler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Instrumentation is told about every document a Ruler tests and every rule
+// tested along the way, so you can export metrics on which rules are hot and
+// which never fire, e.g. to Prometheus:
+//
+//	type metrics struct{}
+//
+//	func (metrics) Evaluated(matched bool, err error, took time.Duration) {
+//		latency.Observe(took.Seconds())
+//		outcomes.WithLabelValues(outcome(matched, err)).Inc()
+//	}
+//
+//	func (metrics) RuleTested(f *ruler.Rule, passed bool) {
+//		hits.WithLabelValues(f.ID, strconv.FormatBool(passed)).Inc()
+//	}
+//
+// methods are called from whichever goroutine is testing, so they have to be safe
+// for concurrent use; they're on the hot path too so keep them quick
+type Instrumentation interface {
+	// Evaluated is called once per document with how it went and how long it took
+	Evaluated(matched bool, err error, took time.Duration)
+	// RuleTested is called for every top-level rule tested against a document,
+	// passed is false if the rule failed or couldn't be tested
+	RuleTested(f *Rule, passed bool)
+}
+
+// WithInstrumentation reports evaluations to i
+// it covers Test, Evaluate and EvaluateEvery and their variations
+func WithInstrumentation(i Instrumentation) Option {
+	return func(r *Ruler) {
+		r.instrumentation = i
+	}
+}
+
+// observer keeps track of how an evaluation goes, for the Ruler's
+// Instrumentation and AuditSink; it lives on walk's stack and never
+// holds onto walk's fail, so evaluating stays allocation free
+type observer struct {
+	c        *CompiledRuler
+	start    time.Time
+	rec      AuditRecord
+	matched  bool
+	stopped  bool
+	firstErr error
+}
+
+// observe starts observing the evaluation in e, o.done reports it once walk is finished
+func (c *CompiledRuler) observe(e *evaluation, o *observer) {
+	*o = observer{c: c, start: time.Now(), matched: true}
+	if c.ruler.audit != nil {
+		o.rec = c.auditRecord(e)
+	}
+}
+
+// failed notes a rule that didn't pass
+func (o *observer) failed(res *Result) {
+	r := o.c.ruler
+	if o.matched {
+		o.matched, o.firstErr = false, res.Err
+	}
+	if res.Failed != nil {
+		if r.instrumentation != nil {
+			r.instrumentation.RuleTested(res.Failed, false)
+		}
+		o.rec.Failed = append(o.rec.Failed, auditName(res.Failed))
+	}
+	if res.Err != nil && o.rec.Error == "" {
+		o.rec.Error = res.Err.Error()
+	}
+}
+
+func (o *observer) done(fail func(*Result) bool) {
+	r := o.c.ruler
+	if r.audit != nil {
+		o.rec.Matched = o.matched
+		if err := r.writeAudit(o.rec); err != nil {
+			// without a record the document can't be let through
+			if !o.stopped {
+				fail(newResult(Result{Err: err}))
+			}
+			o.matched, o.firstErr = false, err
+		}
+	}
+	if r.instrumentation != nil {
+		r.instrumentation.Evaluated(o.matched, o.firstErr, time.Since(o.start))
+	}
+}
+
+// Stats is an Instrumentation that just counts, for when
+// you want a quick look without wiring up a metrics library
+type Stats struct {
+	evaluations atomic.Int64
+	matches     atomic.Int64
+	errors      atomic.Int64
+	nanos       atomic.Int64
+	rules       sync.Map // *Rule to *ruleStats
+}
+
+type ruleStats struct {
+	passed atomic.Int64
+	failed atomic.Int64
+}
+
+// StatsSnapshot is how Stats' counts stood at one point in time
+type StatsSnapshot struct {
+	Evaluations int64
+	Matches     int64
+	Errors      int64
+	// Took is the total time spent evaluating
+	Took time.Duration
+	// Passed and Failed count each top-level rule's results, by rule
+	Passed map[*Rule]int64
+	Failed map[*Rule]int64
+}
+
+// Evaluated counts an evaluation
+func (s *Stats) Evaluated(matched bool, err error, took time.Duration) {
+	s.evaluations.Add(1)
+	if matched {
+		s.matches.Add(1)
+	}
+	if err != nil {
+		s.errors.Add(1)
+	}
+	s.nanos.Add(int64(took))
+}
+
+// RuleTested counts a rule's result
+func (s *Stats) RuleTested(f *Rule, passed bool) {
+	v, ok := s.rules.Load(f)
+	if !ok {
+		v, _ = s.rules.LoadOrStore(f, &ruleStats{})
+	}
+
+	if passed {
+		v.(*ruleStats).passed.Add(1)
+	} else {
+		v.(*ruleStats).failed.Add(1)
+	}
+}
+
+// Snapshot returns the counts so far
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		Evaluations: s.evaluations.Load(),
+		Matches:     s.matches.Load(),
+		Errors:      s.errors.Load(),
+		Took:        time.Duration(s.nanos.Load()),
+		Passed:      make(map[*Rule]int64),
+		Failed:      make(map[*Rule]int64),
+	}
+
+	s.rules.Range(func(k, v interface{}) bool {
+		f, rs := k.(*Rule), v.(*ruleStats)
+		snap.Passed[f] = rs.passed.Load()
+		snap.Failed[f] = rs.failed.Load()
+		return true
+	})
+
+	return snap
+}
+
+// Unused lists the rules that were never tested, or never passed, out of rules
+// handy for spotting rules that never fire
+func (s StatsSnapshot) Unused(rules []*Rule) []*Rule {
+	var unused []*Rule
+	for _, f := range rules {
+		if s.Passed[f] == 0 {
+			unused = append(unused, f)
+		}
+	}
+
+	return unused
+}
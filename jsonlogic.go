@@ -0,0 +1,329 @@
+package ruler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// comparisons that map straight across between JsonLogic and go-ruler
+var jsonLogicOps = map[string]string{
+	"==": "eq", "===": "eq", "!=": "neq", "!==": "neq",
+	">": "gt", ">=": "gte", "<": "lt", "<=": "lte",
+}
+
+// the JsonLogic operator to use for each comparator, and what it becomes
+// when the sides are swapped (1 < x is the same as x > 1)
+var (
+	jsonLogicComparators = map[string]string{
+		"eq": "==", "neq": "!=", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+	}
+	flippedComparators = map[string]string{
+		"eq": "eq", "neq": "neq", "gt": "lt", "gte": "lte", "lt": "gt", "lte": "gte",
+	}
+)
+
+// NewRulerWithJSONLogic returns a new ruler with rules converted from a JsonLogic
+// (jsonlogic.com) expression; see ParseJSONLogic for what's supported
+func NewRulerWithJSONLogic(data []byte, opts ...Option) (*Ruler, error) {
+	rules, err := ParseJSONLogic(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRuler(rules, opts...)
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ParseJSONLogic converts a JsonLogic expression into rules
+// it supports and, or, !, the comparison operators (between a var and a literal,
+// or two vars), the three argument forms of < and <=, missing, and in
+// a literal string in a var is treated as a substring check, and a var in a literal
+// array as a membership check
+// JsonLogic's == is loose equality, but it becomes go-ruler's strict eq
+func ParseJSONLogic(data []byte) ([]*Rule, error) {
+	var expr interface{}
+	if err := json.Unmarshal(data, &expr); err != nil {
+		return nil, err
+	}
+
+	f, err := fromJSONLogic(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.All) > 0 {
+		return f.All, nil
+	}
+
+	return []*Rule{f}, nil
+}
+
+func fromJSONLogic(expr interface{}) (*Rule, error) {
+	m, ok := expr.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("jsonlogic: expected an operation, got %v", expr)
+	}
+
+	var op string
+	var arg interface{}
+	for op, arg = range m {
+	}
+
+	args, ok := arg.([]interface{})
+	if !ok {
+		// JsonLogic lets you skip the array for a single argument
+		args = []interface{}{arg}
+	}
+
+	switch op {
+	case "and", "or":
+		rules := make([]*Rule, len(args))
+		for i, a := range args {
+			f, err := fromJSONLogic(a)
+			if err != nil {
+				return nil, err
+			}
+			rules[i] = f
+		}
+		if op == "and" {
+			return &Rule{All: rules}, nil
+		}
+		return &Rule{Any: rules}, nil
+
+	case "!":
+		if len(args) != 1 {
+			return nil, errors.New("jsonlogic: ! takes one argument")
+		}
+		f, err := fromJSONLogic(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Not: f}, nil
+
+	case "missing":
+		rules := make([]*Rule, len(args))
+		for i, a := range args {
+			path, ok := a.(string)
+			if !ok {
+				return nil, errors.New("jsonlogic: missing takes property names")
+			}
+			rules[i] = &Rule{Comparator: "nexists", Path: path}
+		}
+		if len(rules) == 1 {
+			return rules[0], nil
+		}
+		return &Rule{Any: rules}, nil
+
+	case "in":
+		return jsonLogicIn(args)
+	}
+
+	comparator, ok := jsonLogicOps[op]
+	if !ok {
+		return nil, fmt.Errorf("jsonlogic: operation %s isn't supported", op)
+	}
+
+	if len(args) == 3 && (op == "<" || op == "<=") {
+		// between: {"<": [1, {"var": "x"}, 10]}
+		lo, err := jsonLogicCompare(comparator, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		hi, err := jsonLogicCompare(comparator, args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{All: []*Rule{lo, hi}}, nil
+	}
+
+	if len(args) != 2 {
+		return nil, fmt.Errorf("jsonlogic: %s takes two arguments", op)
+	}
+
+	return jsonLogicCompare(comparator, args[0], args[1])
+}
+
+// jsonLogicCompare turns "left comparator right" into a rule,
+// flipping it around if the var is on the right
+func jsonLogicCompare(comparator string, left, right interface{}) (*Rule, error) {
+	lvar, lok := jsonLogicVar(left)
+	rvar, rok := jsonLogicVar(right)
+
+	switch {
+	case lok && rok:
+		return &Rule{Comparator: comparator, Path: lvar, ValuePath: rvar}, nil
+	case lok:
+		return &Rule{Comparator: comparator, Path: lvar, Value: right}, nil
+	case rok:
+		return &Rule{Comparator: flippedComparators[comparator], Path: rvar, Value: left}, nil
+	}
+
+	return nil, errors.New("jsonlogic: a comparison needs a var on one side")
+}
+
+func jsonLogicIn(args []interface{}) (*Rule, error) {
+	if len(args) != 2 {
+		return nil, errors.New("jsonlogic: in takes two arguments")
+	}
+
+	if path, ok := jsonLogicVar(args[1]); ok {
+		needle, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("jsonlogic: in a var needs a literal string")
+		}
+		return &Rule{Comparator: "contains", Path: path, Value: regexp.QuoteMeta(needle)}, nil
+	}
+
+	path, ok := jsonLogicVar(args[0])
+	list, isList := args[1].([]interface{})
+	if !ok || !isList {
+		return nil, errors.New("jsonlogic: in needs a var and a literal array")
+	}
+
+	rules := make([]*Rule, len(list))
+	for i, v := range list {
+		rules[i] = &Rule{Comparator: "eq", Path: path, Value: v}
+	}
+
+	return &Rule{Any: rules}, nil
+}
+
+// jsonLogicVar returns the path of a {"var": "path"} operation
+func jsonLogicVar(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+
+	switch path := m["var"].(type) {
+	case string:
+		return path, true
+	case []interface{}:
+		// {"var": ["path", default]}, we don't do defaults
+		if len(path) > 0 {
+			p, ok := path[0].(string)
+			return p, ok
+		}
+	}
+
+	return "", false
+}
+
+// JSONLogic converts the Ruler's rules to a JsonLogic expression
+// only the comparisons, exists/nexists and groups have JsonLogic equivalents,
+// anything else (regexes, aggregates, parameters, ...) is an error
+func (r *Ruler) JSONLogic() ([]byte, error) {
+	var expr interface{}
+	if len(r.rules) == 1 {
+		var err error
+		if expr, err = toJSONLogic(r.rules[0]); err != nil {
+			return nil, err
+		}
+	} else {
+		all, err := toJSONLogicList(r.rules)
+		if err != nil {
+			return nil, err
+		}
+		expr = map[string]interface{}{"and": all}
+	}
+
+	// JsonLogic is all < and >, don't turn them into \u003c
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(expr); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func toJSONLogicList(rules []*Rule) ([]interface{}, error) {
+	list := make([]interface{}, len(rules))
+	for i, f := range rules {
+		expr, err := toJSONLogic(f)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = expr
+	}
+
+	return list, nil
+}
+
+func toJSONLogic(f *Rule) (interface{}, error) {
+	if f.Scheduled() {
+		return nil, fmt.Errorf("jsonlogic: can't convert the activation window on (%s)", f.Path)
+	}
+	if f.Ruleset != "" {
+		return nil, fmt.Errorf("jsonlogic: can't convert the reference to ruleset %s", f.Ruleset)
+	}
+
+	switch {
+	case f.All != nil:
+		list, err := toJSONLogicList(f.All)
+		return map[string]interface{}{"and": list}, err
+	case f.Any != nil:
+		list, err := toJSONLogicList(f.Any)
+		return map[string]interface{}{"or": list}, err
+	case f.Not != nil:
+		expr, err := toJSONLogic(f.Not)
+		return map[string]interface{}{"!": expr}, err
+	}
+
+	if f.Aggregate != "" {
+		return nil, fmt.Errorf("jsonlogic: can't convert the %s aggregate on (%s)", f.Aggregate, f.Path)
+	}
+
+	if _, ok := paramName(f.Value); ok {
+		return nil, fmt.Errorf("jsonlogic: can't convert the parameter on (%s)", f.Path)
+	}
+	if _, ok := secretName(f.Value); ok {
+		return nil, fmt.Errorf("jsonlogic: can't convert the secret on (%s)", f.Path)
+	}
+	if len(f.Transforms) > 0 {
+		return nil, fmt.Errorf("jsonlogic: can't convert the transforms on (%s)", f.Path)
+	}
+	if f.Type != "" {
+		return nil, fmt.Errorf("jsonlogic: can't convert the %s type on (%s)", f.Type, f.Path)
+	}
+	if hasPathFunctions(f) {
+		return nil, fmt.Errorf("jsonlogic: can't convert the functions in the path (%s)", f.Path)
+	}
+	if readsContext(f) {
+		return nil, fmt.Errorf("jsonlogic: can't convert the context variable on (%s)", f.Path)
+	}
+	if strings.Contains(f.Path, "*") || strings.Contains(f.ValuePath, "*") {
+		return nil, fmt.Errorf("jsonlogic: can't convert the wildcard in (%s)", f.Path)
+	}
+
+	missing := map[string]interface{}{"missing": []interface{}{f.Path}}
+	switch f.Comparator {
+	case "exists":
+		return map[string]interface{}{"!": missing}, nil
+	case "nexists":
+		return missing, nil
+	}
+
+	op, ok := jsonLogicComparators[f.Comparator]
+	if !ok {
+		return nil, fmt.Errorf("jsonlogic: can't convert comparator %s on (%s)", f.Comparator, f.Path)
+	}
+
+	var value interface{} = f.Value
+	if f.ValuePath != "" {
+		value = map[string]interface{}{"var": f.ValuePath}
+	}
+
+	return map[string]interface{}{
+		op: []interface{}{map[string]interface{}{"var": f.Path}, value},
+	}, nil
+}
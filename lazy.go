@@ -0,0 +1,55 @@
+package ruler
+
+import "sync"
+
+// LazyValue is a value in a document that's only worked out if a rule's path
+// actually reaches it, for properties that are expensive to come by
+// (a database lookup, geo-IP) and that most rules don't look at
+type LazyValue struct {
+	once sync.Once
+	fn   func() (interface{}, error)
+	v    interface{}
+	err  error
+}
+
+// Lazy puts off calling fn until a rule needs its value, e.g.
+//
+//	doc := map[string]interface{}{
+//		"user": user,
+//		"geo":  ruler.Lazy(func() (interface{}, error) { return geoip.Lookup(addr) }),
+//	}
+//
+// fn is called at most once, however many rules (or Rulers) test the document,
+// and an error from it is the error of every rule that needed the value
+// fn can return a map to walk further into, or another Lazy
+func Lazy(fn func() (interface{}, error)) *LazyValue {
+	return &LazyValue{fn: fn}
+}
+
+// Value works out the value the first time it's called, and remembers it
+func (l *LazyValue) Value() (interface{}, error) {
+	l.once.Do(func() {
+		l.v, l.err = l.fn()
+	})
+
+	return l.v, l.err
+}
+
+// resolveLazy works out v if it's a LazyValue, nil standing in for it
+// when that fails, with the error kept in n for whoever's walking the document
+func resolveLazy(v interface{}, n *nodes) interface{} {
+	for {
+		l, ok := v.(*LazyValue)
+		if !ok {
+			return v
+		}
+
+		var err error
+		if v, err = l.Value(); err != nil {
+			if n != nil && n.err == nil {
+				n.err = err
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package ruler
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits caps how much work a Ruler will do on behalf of its rules,
+// which matters when the rules come from people you don't fully trust
+// a zero value for any limit means there is no limit
+type Limits struct {
+	// MaxRegexLength is the longest regex pattern a rule may use
+	MaxRegexLength int
+	// MaxRegexInput is the longest string a regex will be run against
+	MaxRegexInput int
+	// RegexTimeout is how long a single regex match may run
+	RegexTimeout time.Duration
+	// MaxRules is the most rules a Ruler may hold
+	MaxRules int
+	// MaxPathDepth is the most segments a rule's path may have
+	MaxPathDepth int
+	// MaxGroupDepth is how deeply groups of rules may be nested
+	MaxGroupDepth int
+	// MaxDocumentNodes is the most values a single Test may visit
+	// while walking a document
+	MaxDocumentNodes int
+	// MaxInferenceRounds is the most times Infer tests the rules
+	// before they've stopped deriving new facts
+	MaxInferenceRounds int
+}
+
+// WithLimits sets the limits a Ruler enforces
+func WithLimits(l Limits) Option {
+	return func(r *Ruler) {
+		r.limits = l
+	}
+}
+
+// checkLimits makes sure the rules themselves are within the Ruler's limits
+func (r *Ruler) checkLimits() error {
+	count := 0
+	return r.limits.check(r.rules, 0, &count)
+}
+
+// check walks the rules at one level of nesting,
+// counting every rule in every group along the way
+func (l Limits) check(rules []*Rule, depth int, count *int) error {
+	if l.MaxGroupDepth > 0 && depth > l.MaxGroupDepth {
+		return &LimitError{"MaxGroupDepth", l.MaxGroupDepth}
+	}
+
+	for _, f := range rules {
+		*count++
+		if l.MaxRules > 0 && *count > l.MaxRules {
+			return &LimitError{"MaxRules", l.MaxRules}
+		}
+
+		if f.IsGroup() {
+			if err := l.check(f.All, depth+1, count); err != nil {
+				return err
+			}
+			if err := l.check(f.Any, depth+1, count); err != nil {
+				return err
+			}
+			if f.Not != nil {
+				if err := l.check([]*Rule{f.Not}, depth+1, count); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if l.MaxPathDepth > 0 {
+			if len(splitPath(f.Path)) > l.MaxPathDepth {
+				return &LimitError{"MaxPathDepth", l.MaxPathDepth}
+			}
+			if f.ValuePath != "" && len(splitPath(f.ValuePath)) > l.MaxPathDepth {
+				return &LimitError{"MaxPathDepth", l.MaxPathDepth}
+			}
+		}
+	}
+
+	return nil
+}
+
+// nodes counts the values visited while walking a document
+type nodes struct {
+	count int
+	max   int
+	// err is the first error from a LazyValue found along the way
+	err error
+}
+
+// visit counts another value, and reports whether we're still within the limit
+// a nil *nodes doesn't count anything
+func (n *nodes) visit() bool {
+	if n == nil {
+		return true
+	}
+
+	n.count++
+	return !n.exceeded()
+}
+
+func (n *nodes) exceeded() bool {
+	return n.max > 0 && n.count > n.max
+}
+
+// lazyErr hands back the error a LazyValue gave while walking, if one did,
+// and forgets it so the next path starts clean
+func (n *nodes) lazyErr() error {
+	err := n.err
+	n.err = nil
+	return err
+}
+
+// LimitError is returned when a rule or document goes over one of the Ruler's Limits
+type LimitError struct {
+	// Limit is the name of the Limits field that was exceeded
+	Limit string
+	// Max is the configured value of that limit
+	Max interface{}
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ruler: %s limit of %v exceeded", e.Limit, e.Max)
+}
@@ -0,0 +1,301 @@
+package ruler
+
+import (
+	"fmt"
+	"math"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+// FindingKind says what sort of problem a Finding is
+type FindingKind string
+
+// the kinds of Finding that Lint reports
+const (
+	Contradiction     FindingKind = "contradiction"
+	Duplicate         FindingKind = "duplicate"
+	LiteralRegex      FindingKind = "literal_regex"
+	AlwaysTrue        FindingKind = "always_true"
+	AlwaysFalse       FindingKind = "always_false"
+	UnknownComparator FindingKind = "unknown_comparator"
+	BadRegex          FindingKind = "bad_regex"
+)
+
+// A Finding is something Lint thinks is wrong with a rule
+type Finding struct {
+	Kind FindingKind
+	// Rule is the rule the finding is about
+	Rule    *Rule
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+}
+
+// Lint looks over the Ruler's rules, without any document, for rules
+// that can never pass together, duplicates, regexes that are really
+// just literals, and conditions that always (or never) pass
+func Lint(r *Ruler) []Finding {
+	var findings []Finding
+	lintScope(r.rules, &findings)
+
+	return findings
+}
+
+// lintScope lints a list of rules that all have to pass together,
+// which is the top level and every all group
+func lintScope(rules []*Rule, findings *[]Finding) {
+	add := func(kind FindingKind, f *Rule, format string, args ...interface{}) {
+		*findings = append(*findings, Finding{kind, f, fmt.Sprintf(format, args...)})
+	}
+
+	byPath := make(map[string][]*Rule)
+	var paths []string
+
+	for i, f := range rules {
+		for _, prev := range rules[:i] {
+			if sameRule(prev, f) {
+				add(Duplicate, f, "rule on (%s) duplicates an earlier rule", f.Path)
+				break
+			}
+		}
+
+		if f.IsGroup() {
+			lintGroup(f, findings, add)
+			continue
+		}
+
+		lintRule(f, add)
+
+		if f.Aggregate == "" && f.ValuePath == "" {
+			key := lintKey(f)
+			if _, ok := byPath[key]; !ok {
+				paths = append(paths, key)
+			}
+			byPath[key] = append(byPath[key], f)
+		}
+	}
+
+	for _, key := range paths {
+		lintRange(byPath[key][0].Path, byPath[key], add)
+	}
+}
+
+// lintKey is what a rule's comparison is really made against: its path,
+// through any functions wrapped around it and then its transforms,
+// so len(tags) isn't checked against tags, but lower(name) is the same
+// value as name with a lower transform
+func lintKey(f *Rule) string {
+	inner, names := pathFunctions(f.Path)
+	names = append(names, f.Transforms...)
+	if len(names) == 0 {
+		return inner
+	}
+
+	return inner + "|" + strings.Join(names, ",")
+}
+
+func lintGroup(f *Rule, findings *[]Finding, add func(FindingKind, *Rule, string, ...interface{})) {
+	switch {
+	case f.All != nil:
+		if len(f.All) == 0 {
+			add(AlwaysTrue, f, "empty all group (%s) always passes", f.ID)
+		}
+		lintScope(f.All, findings)
+	case f.Any != nil:
+		if len(f.Any) == 0 {
+			add(AlwaysFalse, f, "empty any group (%s) never passes", f.ID)
+		}
+		for i, g := range f.Any {
+			for _, prev := range f.Any[:i] {
+				if sameRule(prev, g) {
+					add(Duplicate, g, "rule on (%s) duplicates an earlier rule in its any group", g.Path)
+					break
+				}
+			}
+			lintScope([]*Rule{g}, findings)
+		}
+	case f.Not != nil:
+		lintScope([]*Rule{f.Not}, findings)
+	}
+}
+
+// lintRule looks at a single condition by itself
+func lintRule(f *Rule, add func(FindingKind, *Rule, string, ...interface{})) {
+	if !knownComparators[f.Comparator] {
+		add(UnknownComparator, f, "unknown comparator (%s) on (%s)", f.Comparator, f.Path)
+		return
+	}
+
+	switch f.Comparator {
+	case "regex", "matches", "contains", "ncontains", "regex_extract":
+		streg, ok := f.Value.(string)
+		if !ok || f.ValuePath != "" {
+			return
+		}
+
+		re, err := syntax.Parse(streg, syntax.Perl)
+		if err != nil {
+			add(BadRegex, f, "regex on (%s) doesn't compile: %v", f.Path, err)
+			return
+		}
+		re = re.Simplify()
+
+		negated := f.Comparator == "ncontains"
+		if matchesEverything(re) {
+			if negated {
+				add(AlwaysFalse, f, "regex (%s) on (%s) matches everything, so this never passes", streg, f.Path)
+			} else {
+				add(AlwaysTrue, f, "regex (%s) on (%s) matches every string", streg, f.Path)
+			}
+		} else if lit, anchored, ok := literalRegex(re); ok {
+			if anchored {
+				add(LiteralRegex, f, "regex (%s) on (%s) is the same as comparing to %q", streg, f.Path, lit)
+			} else {
+				add(LiteralRegex, f, "regex (%s) on (%s) is just a substring check for %q", streg, f.Path, lit)
+			}
+		}
+
+	case "percent":
+		if pct, ok := rolloutPercent(f.Value); ok {
+			if pct >= 100 {
+				add(AlwaysTrue, f, "percent rollout on (%s) covers everyone", f.Path)
+			} else if pct <= 0 {
+				add(AlwaysFalse, f, "percent rollout on (%s) covers no one", f.Path)
+			}
+		}
+	}
+}
+
+// lintRange checks the comparisons on one path for values that can't all be true
+func lintRange(path string, rules []*Rule, add func(FindingKind, *Rule, string, ...interface{})) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	loStrict, hiStrict := false, false
+	var eqRule *Rule
+
+	for _, f := range rules {
+		if _, ok := paramName(f.Value); ok {
+			continue
+		}
+		if _, ok := secretName(f.Value); ok {
+			continue
+		}
+
+		switch f.Comparator {
+		case "eq":
+			if eqRule != nil && !deepEqual(eqRule.Value, f.Value) {
+				add(Contradiction, f, "(%s) can't equal both %v and %v", path, eqRule.Value, f.Value)
+			}
+			if eqRule == nil {
+				eqRule = f
+			}
+		}
+
+		n, ok := toFloat(f.Value)
+		if !ok {
+			continue
+		}
+
+		switch f.Comparator {
+		case "gt", "gte":
+			if n > lo || (n == lo && f.Comparator == "gt") {
+				lo, loStrict = n, f.Comparator == "gt"
+			}
+		case "lt", "lte":
+			if n < hi || (n == hi && f.Comparator == "lt") {
+				hi, hiStrict = n, f.Comparator == "lt"
+			}
+		case "eq":
+			if n > lo {
+				lo, loStrict = n, false
+			}
+			if n < hi {
+				hi, hiStrict = n, false
+			}
+		}
+
+		if lo > hi || (lo == hi && (loStrict || hiStrict)) {
+			add(Contradiction, f, "(%s) can't be both above %v and below %v", path, lo, hi)
+			return
+		}
+	}
+
+	if eqRule == nil {
+		return
+	}
+	for _, f := range rules {
+		if f.Comparator == "neq" && deepEqual(f.Value, eqRule.Value) {
+			add(Contradiction, f, "(%s) can't both equal and not equal %v", path, f.Value)
+		}
+	}
+}
+
+// sameRule reports whether two rules are the same condition,
+// transformed, typed and active the same way
+func sameRule(a, b *Rule) bool {
+	if a.IsGroup() || b.IsGroup() {
+		return false
+	}
+
+	return a.Path == b.Path &&
+		a.Comparator == b.Comparator &&
+		a.Aggregate == b.Aggregate &&
+		a.ValuePath == b.ValuePath &&
+		a.Type == b.Type &&
+		strings.Join(a.Transforms, ",") == strings.Join(b.Transforms, ",") &&
+		sameTime(a.ActiveFrom, b.ActiveFrom) &&
+		sameTime(a.ActiveUntil, b.ActiveUntil) &&
+		a.Schedule == b.Schedule &&
+		deepEqual(a.Value, b.Value)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+// matchesEverything reports whether a (simplified) regex matches any string
+func matchesEverything(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return true
+	case syntax.OpStar:
+		return re.Sub[0].Op == syntax.OpAnyChar || re.Sub[0].Op == syntax.OpAnyCharNotNL
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			switch sub.Op {
+			case syntax.OpBeginText, syntax.OpBeginLine:
+				continue
+			default:
+				if !matchesEverything(sub) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// literalRegex reports whether a (simplified) regex is just a literal string,
+// and whether it's anchored at both ends
+func literalRegex(re *syntax.Regexp) (string, bool, bool) {
+	if re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 {
+		return string(re.Rune), false, true
+	}
+
+	if re.Op == syntax.OpConcat && len(re.Sub) == 3 &&
+		re.Sub[0].Op == syntax.OpBeginText &&
+		re.Sub[1].Op == syntax.OpLiteral && re.Sub[1].Flags&syntax.FoldCase == 0 &&
+		re.Sub[2].Op == syntax.OpEndText {
+		return string(re.Sub[1].Rune), true, true
+	}
+
+	return "", false, false
+}
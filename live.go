@@ -0,0 +1,88 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// LiveRuler tests documents against rules that are kept up to date from a RuleStore
+// when the stored rules change they're loaded and compiled in the background, then
+// swapped in all at once, so every Test sees either the old rules or the new ones
+type LiveRuler struct {
+	current atomic.Pointer[CompiledRuler]
+}
+
+var _ Tester = (*LiveRuler)(nil)
+
+// WatchRuler loads the rules saved as name and keeps them up to date until ctx is done
+// rules that change into something that can't be loaded are skipped, keeping
+// the ones before, and the error goes to onError, which can be nil
+// opts are used for every version of the rules
+func WatchRuler(ctx context.Context, store RuleStore, name string, onError func(error), opts ...Option) (*LiveRuler, error) {
+	// watch before loading, so a change in between isn't missed
+	updates, err := store.Watch(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := LoadRuler(ctx, store, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LiveRuler{}
+	l.current.Store(c)
+
+	go func() {
+		for data := range updates {
+			if err := l.load(data, opts); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+		// the store gave up before ctx was done, so the rules won't change any more
+		if ctx.Err() == nil && onError != nil {
+			onError(fmt.Errorf("watching rules (%s) stopped", name))
+		}
+	}()
+
+	return l, nil
+}
+
+func (l *LiveRuler) load(data []byte, opts []Option) error {
+	r, err := NewRulerWithJSON(data, opts...)
+	if err != nil {
+		return err
+	}
+	c, err := r.Compile()
+	if err != nil {
+		return err
+	}
+
+	l.current.Store(c)
+	return nil
+}
+
+// Compiled is the rules in effect right now
+func (l *LiveRuler) Compiled() *CompiledRuler {
+	return l.current.Load()
+}
+
+// Test tests a document against the rules in effect right now
+func (l *LiveRuler) Test(o map[string]interface{}) (bool, error) {
+	return l.Compiled().Test(o)
+}
+
+// TestContext is Test with a context, like CompiledRuler's TestContext
+func (l *LiveRuler) TestContext(ctx context.Context, o map[string]interface{}) (bool, error) {
+	return l.Compiled().TestContext(ctx, o)
+}
+
+// Evaluate is CompiledRuler's Evaluate with the rules in effect right now
+func (l *LiveRuler) Evaluate(o map[string]interface{}) *Result {
+	return l.Compiled().Evaluate(o)
+}
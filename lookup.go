@@ -0,0 +1,163 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// LookupFunc fetches a fact about key from outside the document,
+// like whether a user is on a blocklist service, for lookup rules
+type LookupFunc func(ctx context.Context, key interface{}) (interface{}, error)
+
+// LookupErrorPolicy says what a lookup rule does when its lookup fails
+type LookupErrorPolicy int
+
+const (
+	// LookupFail makes the lookup's error the rule's error, like any other
+	LookupFail LookupErrorPolicy = iota
+	// LookupPass lets the rule pass, failing open
+	LookupPass
+	// LookupDeny fails the rule without an error, failing closed
+	LookupDeny
+)
+
+type lookup struct {
+	fn      LookupFunc
+	timeout time.Duration
+	onError LookupErrorPolicy
+}
+
+// WithLookup registers fn as the lookup called name, so a rule like
+//
+//	{"comparator": "lookup", "path": "user.id", "value": "blocklist"}
+//
+// calls it with the property, and passes when it returns something truthy:
+// true, or anything but nil and false
+// each call gets at most timeout (0 for no limit besides the evaluation's context),
+// and onError decides what a failed or timed out call does to the rule
+// a key is only looked up once per document, however many rules use it
+func WithLookup(name string, fn LookupFunc, timeout time.Duration, onError LookupErrorPolicy) Option {
+	return func(r *Ruler) {
+		if r.lookups == nil {
+			r.lookups = make(map[string]*lookup)
+		}
+		r.lookups[name] = &lookup{fn: fn, timeout: timeout, onError: onError}
+	}
+}
+
+// lookupKey is one call of one lookup, for caching it for the rest of the evaluation
+type lookupKey struct {
+	name string
+	key  interface{}
+}
+
+type lookupResult struct {
+	v   interface{}
+	err error
+}
+
+// compileLookup checks a lookup rule names a lookup that's been registered
+func (r *Ruler) compileLookup(f *Rule) error {
+	name, ok := f.Value.(string)
+	if !ok {
+		return fmt.Errorf("lookup rule on (%s) needs the name of a lookup as its value", f.Path)
+	}
+	if r.lookups[name] == nil {
+		return fmt.Errorf("unknown lookup %s on (%s)", name, f.Path)
+	}
+
+	return nil
+}
+
+// lookup runs a lookup rule, see WithLookup
+func (r *Ruler) lookup(e *evaluation, f *compiledRule, actual, expected interface{}) (bool, error) {
+	name, ok := expected.(string)
+	if !ok {
+		return false, errors.New("lookup name not actually a string, bailing")
+	}
+	l := r.lookups[name]
+	if l == nil {
+		return false, fmt.Errorf("unknown lookup %s", name)
+	}
+
+	v, err := e.lookup(name, l, actual)
+	if err != nil {
+		switch l.onError {
+		case LookupPass:
+			return true, nil
+		case LookupDeny:
+			return false, nil
+		}
+		return false, fmt.Errorf("lookup %s on (%s): %w", name, f.Path, err)
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+
+	return true, nil
+}
+
+// lookup calls l for key, or remembers what it said last time for this document
+func (e *evaluation) lookup(name string, l *lookup, key interface{}) (interface{}, error) {
+	cacheable := key == nil || reflect.TypeOf(key).Comparable()
+	k := lookupKey{name: name, key: key}
+	if cacheable {
+		if res, ok := e.lookups[k]; ok {
+			return res.v, res.err
+		}
+	}
+
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	v, err := callLookup(ctx, l.fn, key)
+	if cacheable {
+		if e.lookups == nil {
+			e.lookups = make(map[lookupKey]lookupResult)
+		}
+		e.lookups[k] = lookupResult{v: v, err: err}
+	}
+
+	return v, err
+}
+
+// callLookup calls fn, giving up once ctx is done
+// even if fn doesn't take any notice of it
+func callLookup(ctx context.Context, fn LookupFunc, key interface{}) (interface{}, error) {
+	if ctx.Done() == nil {
+		return fn(ctx, key)
+	}
+
+	// buffered so the goroutine can finish and go away
+	// even if nobody is waiting for it anymore
+	done := make(chan lookupResult, 1)
+	go func() {
+		v, err := fn(ctx, key)
+		done <- lookupResult{v: v, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err == nil && ctx.Err() != nil {
+			// the lookup didn't notice it ran out of time
+			res.err = ctx.Err()
+		}
+		return res.v, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MessageCatalog looks up translated failure messages,
+// by rule ID and a locale like "pt-BR"
+// the templates use the same placeholders as Rule's Message
+type MessageCatalog interface {
+	Message(ruleID, locale string) (string, bool)
+}
+
+// MapCatalog is a MessageCatalog held in memory, by locale and then rule ID
+type MapCatalog map[string]map[string]string
+
+// Message looks up a rule's message in the locale
+func (m MapCatalog) Message(ruleID, locale string) (string, bool) {
+	msg, ok := m[locale][ruleID]
+	return msg, ok
+}
+
+// WithMessageCatalog sets where EvaluateLocale finds translated messages
+// rules without one in the catalog (or without an ID) fall back to their own Message
+func WithMessageCatalog(catalog MessageCatalog) Option {
+	return func(r *Ruler) {
+		r.catalog = catalog
+	}
+}
+
+// EvaluateLocale works like Evaluate, but the Result's Message comes from
+// the MessageCatalog in the given locale
+func (r *Ruler) EvaluateLocale(o map[string]interface{}, locale string) *Result {
+	c, err := r.Compile()
+	if err != nil {
+		return &Result{Err: err}
+	}
+
+	return c.EvaluateLocale(o, locale)
+}
+
+// EvaluateLocale is the compiled version of Ruler's EvaluateLocale
+func (c *CompiledRuler) EvaluateLocale(o map[string]interface{}, locale string) *Result {
+	e := c.ruler.newEvaluation(context.Background(), o, nil)
+	defer e.release()
+	e.locale = locale
+
+	return c.evaluate(e)
+}
+
+// messageTemplate finds the template for a rule's failure message, from the
+// catalog if there is one, trying the whole locale and then just its language
+func (r *Ruler) messageTemplate(e *evaluation, f *compiledRule) string {
+	if r.catalog == nil || e.locale == "" || f.ID == "" {
+		return f.Message
+	}
+
+	if msg, ok := r.catalog.Message(f.ID, e.locale); ok {
+		return msg
+	}
+	if i := strings.IndexAny(e.locale, "-_"); i > 0 {
+		if msg, ok := r.catalog.Message(f.ID, e.locale[:i]); ok {
+			return msg
+		}
+	}
+
+	return f.Message
+}
+
+// failureMessage fills in a rule's Message template for a document that failed it
+// the placeholders are {id}, {path}, {actual} and {expected}
+func (r *Ruler) failureMessage(e *evaluation, f *compiledRule, actual interface{}) string {
+	tmpl := r.messageTemplate(e, f)
+	if tmpl == "" {
+		return ""
+	}
+
+	var expected interface{}
+	if name, ok := secretName(f.Value); ok {
+		// secrets stay out of messages
+		expected = "the " + name + " secret"
+	} else if !f.IsGroup() {
+		var err error
+		if expected, err = r.expectedValue(e, f); err != nil {
+			expected = f.Value
+		}
+	}
+
+	return strings.NewReplacer(
+		"{id}", f.ID,
+		"{path}", f.Path,
+		"{actual}", messageValue(actual),
+		"{expected}", messageValue(expected),
+	).Replace(tmpl)
+}
+
+// messageValue renders a value for people to read, strings without quotes
+func messageValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%g", v)
+	}
+
+	return fmt.Sprint(v)
+}
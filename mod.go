@@ -0,0 +1,78 @@
+package ruler
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// modulo checks that actual % divisor == remainder, for sharding rules
+// expected is either the divisor, meaning the remainder must be 0, or an object like
+//
+//	{"divisor": 10, "remainder": 3}
+//
+// the remainder is never negative, so -7 % 10 is 3, not -7
+func modulo(actual, expected interface{}) (bool, error) {
+	var divisor, remainder int64
+	var ok bool
+
+	if m, isMap := asMap(expected); isMap {
+		if divisor, ok = toInt(m["divisor"]); !ok {
+			return false, errors.New("divisor not actually an integer, bailing")
+		}
+		if r, found := m["remainder"]; found {
+			if remainder, ok = toInt(r); !ok {
+				return false, errors.New("remainder not actually an integer, bailing")
+			}
+		}
+	} else if divisor, ok = toInt(expected); !ok {
+		return false, errors.New("expected value not actually an integer, bailing")
+	}
+	if divisor == 0 {
+		return false, errors.New("divisor can't be zero, bailing")
+	}
+
+	n, ok := toInt(actual)
+	if !ok {
+		return false, errors.New("actual value not actually an integer, bailing")
+	}
+
+	if divisor < 0 {
+		divisor = -divisor
+	}
+	mod := n % divisor
+	if mod < 0 {
+		mod += divisor
+	}
+
+	return mod == remainder, nil
+}
+
+// toInt reads an integer from any Go integer, a float64 with no fractional part,
+// or a string of digits (ids often come through as strings)
+func toInt(v interface{}) (int64, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+
+	return 0, false
+}
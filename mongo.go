@@ -0,0 +1,259 @@
+package ruler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Mongo comparison operators that map straight onto a comparator
+var mongoOps = map[string]string{
+	"$eq": "eq", "$ne": "neq", "$gt": "gt", "$gte": "gte", "$lt": "lt", "$lte": "lte",
+}
+
+// NewRulerFromMongoQuery returns a new ruler with rules converted from
+// a MongoDB query filter; see ParseMongoQuery for what's supported
+func NewRulerFromMongoQuery(data []byte, opts ...Option) (*Ruler, error) {
+	rules, err := ParseMongoQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRuler(rules, opts...)
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ParseMongoQuery converts a MongoDB query filter into rules
+// it supports the common subset: plain values, $eq, $ne, $gt, $gte, $lt,
+// $lte, $in, $nin, $exists, $regex (with the i, m and s $options),
+// $not, $and, $or and $nor
+// fields are converted in alphabetical order, since JSON objects don't have one,
+// and a missing field matches $ne, $nin and $not and nothing else,
+// the way it does in MongoDB
+func ParseMongoQuery(data []byte) ([]*Rule, error) {
+	var q map[string]interface{}
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, err
+	}
+
+	return fromMongoQuery(q)
+}
+
+func fromMongoQuery(q map[string]interface{}) ([]*Rule, error) {
+	var rules []*Rule
+	for _, key := range sortedKeys(q) {
+		val := q[key]
+
+		switch key {
+		case "$and", "$or", "$nor":
+			list, ok := val.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("mongo: %s takes a non-empty array", key)
+			}
+
+			group := make([]*Rule, len(list))
+			for i, item := range list {
+				sub, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("mongo: %s takes an array of queries", key)
+				}
+				subRules, err := fromMongoQuery(sub)
+				if err != nil {
+					return nil, err
+				}
+				group[i] = &Rule{All: subRules}
+			}
+
+			switch key {
+			case "$and":
+				rules = append(rules, &Rule{All: group})
+			case "$or":
+				rules = append(rules, &Rule{Any: group})
+			default:
+				rules = append(rules, &Rule{Not: &Rule{Any: group}})
+			}
+			continue
+		}
+
+		if strings.HasPrefix(key, "$") {
+			return nil, fmt.Errorf("mongo: operator %s isn't supported", key)
+		}
+
+		fieldRules, err := fromMongoField(key, val)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fieldRules...)
+	}
+
+	return rules, nil
+}
+
+// fromMongoField converts the condition on one field,
+// either a plain value or an object of operators
+func fromMongoField(path string, val interface{}) ([]*Rule, error) {
+	ops, ok := val.(map[string]interface{})
+	if !ok || !isMongoOperators(ops) {
+		return []*Rule{mongoPresent(path, mongoEq(path, val))}, nil
+	}
+
+	var rules []*Rule
+	for _, op := range sortedKeys(ops) {
+		arg := ops[op]
+
+		if comparator, ok := mongoOps[op]; ok {
+			if op == "$eq" {
+				rules = append(rules, mongoPresent(path, mongoEq(path, arg)))
+				continue
+			}
+			if op == "$ne" {
+				ne := &Rule{Comparator: comparator, Path: path, Value: arg}
+				switch arg.(type) {
+				case map[string]interface{}, []interface{}:
+					ne.Comparator = "deep_neq"
+				}
+				rules = append(rules, mongoOrMissing(path, ne))
+				continue
+			}
+			rules = append(rules, mongoPresent(path, &Rule{Comparator: comparator, Path: path, Value: arg}))
+			continue
+		}
+
+		switch op {
+		case "$in", "$nin":
+			list, ok := arg.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("mongo: %s on (%s) takes an array", op, path)
+			}
+			in := make([]*Rule, len(list))
+			for i, v := range list {
+				in[i] = mongoEq(path, v)
+			}
+			if op == "$in" {
+				rules = append(rules, mongoPresent(path, &Rule{Any: in}))
+			} else {
+				rules = append(rules, mongoOrMissing(path, &Rule{Not: &Rule{Any: in}}))
+			}
+
+		case "$exists":
+			want, ok := arg.(bool)
+			if !ok {
+				return nil, fmt.Errorf("mongo: $exists on (%s) takes true or false", path)
+			}
+			if want {
+				rules = append(rules, &Rule{Comparator: "exists", Path: path})
+			} else {
+				rules = append(rules, &Rule{Comparator: "nexists", Path: path})
+			}
+
+		case "$regex":
+			pattern, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("mongo: $regex on (%s) takes a string", path)
+			}
+			flags, err := mongoRegexOptions(ops["$options"])
+			if err != nil {
+				return nil, fmt.Errorf("mongo: $options on (%s): %w", path, err)
+			}
+			rules = append(rules, mongoPresent(path, &Rule{Comparator: "regex", Path: path, Value: flags + pattern}))
+
+		case "$options":
+			// handled with $regex
+			if _, ok := ops["$regex"]; !ok {
+				return nil, fmt.Errorf("mongo: $options on (%s) without $regex", path)
+			}
+
+		case "$not":
+			not, err := fromMongoField(path, arg)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, mongoOrMissing(path, &Rule{Not: &Rule{All: not}}))
+
+		default:
+			return nil, fmt.Errorf("mongo: operator %s on (%s) isn't supported", op, path)
+		}
+	}
+
+	return rules, nil
+}
+
+// mongoEq is the rule for an equality match, which compares
+// objects and arrays by their contents
+func mongoEq(path string, val interface{}) *Rule {
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		return &Rule{Comparator: "deep_eq", Path: path, Value: val}
+	}
+
+	return &Rule{Comparator: "eq", Path: path, Value: val}
+}
+
+// mongoPresent is rule, as long as path is there at all,
+// so a missing field is a non-match like it is in MongoDB, not an error
+// that would stop a $or from trying its other queries
+func mongoPresent(path string, rule *Rule) *Rule {
+	return &Rule{All: []*Rule{{Comparator: "exists", Path: path}, rule}}
+}
+
+// mongoOrMissing is rule, or else path isn't there at all,
+// since $ne, $nin and $not match documents without the field in MongoDB
+func mongoOrMissing(path string, rule *Rule) *Rule {
+	return &Rule{Any: []*Rule{{Comparator: "nexists", Path: path}, rule}}
+}
+
+// isMongoOperators says whether an object is a set of operators
+// rather than a value to match exactly
+func isMongoOperators(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mongoRegexOptions turns $options into the matching inline flags
+func mongoRegexOptions(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	opts, ok := v.(string)
+	if !ok {
+		return "", errors.New("expected a string")
+	}
+	if opts == "" {
+		return "", nil
+	}
+
+	for _, c := range opts {
+		switch c {
+		case 'i', 'm', 's':
+		default:
+			return "", fmt.Errorf("option %c isn't supported", c)
+		}
+	}
+
+	return "(?" + opts + ")", nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,95 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MultiResult is every problem with a document, from EvaluateEvery
+type MultiResult struct {
+	// Matched is true when the document passed every rule
+	Matched bool
+	// Failures has a Result for each rule the document failed or
+	// that couldn't be tested, in the order the rules were tested
+	Failures []*Result
+	// Version is the version of the rules that were tested
+	Version string
+	// Captures are the named groups regex_extract rules pulled out of the document
+	Captures map[string]string
+}
+
+// multiResults are reused once they've been released
+var multiResults = sync.Pool{
+	New: func() interface{} { return new(MultiResult) },
+}
+
+// Release hands the MultiResult and all its Failures back to be reused,
+// like Result's Release, don't use any of them afterwards
+func (m *MultiResult) Release() {
+	for i, res := range m.Failures {
+		res.Release()
+		m.Failures[i] = nil
+	}
+
+	*m = MultiResult{Failures: m.Failures[:0]}
+	multiResults.Put(m)
+}
+
+// Err joins the errors from every failure that had one, or is nil if none did
+func (m *MultiResult) Err() error {
+	var errs []error
+	for _, res := range m.Failures {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Messages lists the failure messages of the rules that have one
+func (m *MultiResult) Messages() []string {
+	var msgs []string
+	for _, res := range m.Failures {
+		if res.Message != "" {
+			msgs = append(msgs, res.Message)
+		}
+	}
+
+	return msgs
+}
+
+// EvaluateEvery works like Evaluate, but keeps going after a rule fails
+// or errors so every problem with the document comes back at once,
+// which is what form validation wants
+// only top-level rules are reported, a failing group is one failure
+func (r *Ruler) EvaluateEvery(o map[string]interface{}) *MultiResult {
+	c, err := r.Compile()
+	if err != nil {
+		return &MultiResult{Failures: []*Result{{Err: err}}}
+	}
+
+	return c.EvaluateEvery(o)
+}
+
+// EvaluateEvery is the compiled version of Ruler's EvaluateEvery
+func (c *CompiledRuler) EvaluateEvery(o map[string]interface{}) *MultiResult {
+	e := c.ruler.newEvaluation(context.Background(), o, nil)
+	defer e.release()
+
+	return c.evaluateEvery(e)
+}
+
+func (c *CompiledRuler) evaluateEvery(e *evaluation) *MultiResult {
+	m := multiResults.Get().(*MultiResult)
+	c.walk(e, func(res *Result) bool {
+		m.Failures = append(m.Failures, res)
+		return true
+	})
+	m.Matched = len(m.Failures) == 0
+	m.Version = c.ruler.version
+	m.Captures = e.captures
+
+	return m
+}
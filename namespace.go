@@ -0,0 +1,36 @@
+package ruler
+
+// Namespaced puts named documents side by side in one document, so rules
+// can read each of them by its name, like user.plan and request.ip
+// for authorization rules that look at both a subject and a resource
+// the documents themselves aren't copied
+func Namespaced(docs map[string]map[string]interface{}) map[string]interface{} {
+	o := make(map[string]interface{}, len(docs))
+	for name, doc := range docs {
+		o[name] = doc
+	}
+
+	return o
+}
+
+// TestDocuments tests the rules against several named documents at once, see Namespaced
+//
+//	r.TestDocuments(map[string]map[string]interface{}{"request": req, "user": usr})
+func (r *Ruler) TestDocuments(docs map[string]map[string]interface{}) (bool, error) {
+	return r.Test(Namespaced(docs))
+}
+
+// EvaluateDocuments is Evaluate for several named documents, see TestDocuments
+func (r *Ruler) EvaluateDocuments(docs map[string]map[string]interface{}) *Result {
+	return r.Evaluate(Namespaced(docs))
+}
+
+// TestDocuments is the compiled version of Ruler's TestDocuments
+func (c *CompiledRuler) TestDocuments(docs map[string]map[string]interface{}) (bool, error) {
+	return c.Test(Namespaced(docs))
+}
+
+// EvaluateDocuments is the compiled version of Ruler's EvaluateDocuments
+func (c *CompiledRuler) EvaluateDocuments(docs map[string]map[string]interface{}) *Result {
+	return c.Evaluate(Namespaced(docs))
+}
@@ -0,0 +1,64 @@
+package ruler
+
+import (
+	"fmt"
+	"math"
+)
+
+// NonFinitePolicy is what comparisons do when either side is NaN or ±Inf
+type NonFinitePolicy int
+
+const (
+	// NonFiniteIEEE follows IEEE 754: NaN equals nothing (not even NaN) and isn't
+	// bigger or smaller than anything, and the infinities compare like any other number
+	NonFiniteIEEE NonFinitePolicy = iota
+	// NonFiniteFalse makes every comparison involving NaN or ±Inf fail,
+	// neq included
+	NonFiniteFalse
+	// NonFiniteError makes every comparison involving NaN or ±Inf an error
+	NonFiniteError
+)
+
+// WithNonFinite sets how eq, neq, approx_eq and the ordering comparators
+// treat NaN and ±Inf, NonFiniteIEEE by default
+func WithNonFinite(policy NonFinitePolicy) Option {
+	return func(r *Ruler) {
+		r.nonFinite = policy
+	}
+}
+
+// the comparators the policy applies to
+var numericComparators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true, "approx_eq": true,
+}
+
+// checkNonFinite applies the Ruler's policy to a comparison,
+// decided is set when the policy settles the result
+func (r *Ruler) checkNonFinite(f *compiledRule, actual, expected interface{}) (result bool, decided bool, err error) {
+	if r.nonFinite == NonFiniteIEEE || !numericComparators[f.Comparator] {
+		return false, false, nil
+	}
+	if !isNonFinite(actual) && !isNonFinite(expected) {
+		return false, false, nil
+	}
+
+	if r.nonFinite == NonFiniteError {
+		return false, true, fmt.Errorf("can't compare non-finite number on (%s)", f.Path)
+	}
+
+	return false, true, nil
+}
+
+func isNonFinite(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return math.IsNaN(n) || math.IsInf(n, 0)
+	case float32:
+		return math.IsNaN(float64(n)) || math.IsInf(float64(n), 0)
+	case map[string]interface{}:
+		// approx_eq's {"value": ..., "epsilon": ...}
+		return isNonFinite(n["value"]) || isNonFinite(n["epsilon"])
+	}
+
+	return false
+}
@@ -0,0 +1,174 @@
+package ruler
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Comparable lets your own types decide how they compare against a rule's value
+// when they show up in a document built in Go
+// Compare returns a negative number, zero or a positive number when the value
+// is less than, equal to or greater than other, just like strings.Compare
+// it's used for eq, neq, gt, gte, lt and lte
+type Comparable interface {
+	Compare(other interface{}) (int, error)
+}
+
+// the comparators native values are compared with, and the op for each ordering one
+var nativeComparators = map[string]int{
+	"eq": eq, "neq": neq, "gt": gt, "gte": gte, "lt": lt, "lte": lte,
+}
+
+// compareNative compares Go values that don't come out of JSON:
+// Comparables, time.Time, time.Duration and net.IP
+// against either the same type or the string (or number) a JSON rule would have,
+// and any other fmt.Stringer as its string
+// decided is false when actual isn't one of these
+func (r *Ruler) compareNative(f *compiledRule, actual, expected interface{}) (result bool, decided bool, err error) {
+	// most values aren't native, so check that before looking up the comparator
+	switch actual.(type) {
+	case Comparable, time.Time, time.Duration, net.IP:
+	default:
+		return false, false, nil
+	}
+
+	op, ok := nativeComparators[f.Comparator]
+	if !ok {
+		return false, false, nil
+	}
+
+	var cmp int
+	switch a := actual.(type) {
+	case Comparable:
+		cmp, err = a.Compare(expected)
+	case time.Time:
+		cmp, err = compareTime(a, expected)
+	case time.Duration:
+		cmp, err = compareDuration(a, expected)
+	case net.IP:
+		cmp, err = compareIP(a, expected)
+	default:
+		return false, false, nil
+	}
+	if err != nil {
+		return false, true, fmt.Errorf("comparing (%s): %w", f.Path, err)
+	}
+
+	switch op {
+	case eq:
+		return cmp == 0, true, nil
+	case neq:
+		return cmp != 0, true, nil
+	}
+
+	return ordered(op, cmp), true, nil
+}
+
+// stringerValue turns a fmt.Stringer (a uuid.UUID, say) into its string,
+// so it's compared and matched like one
+func stringerValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, float64, bool, Comparable, time.Time, time.Duration, net.IP:
+		return v
+	}
+
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return v
+}
+
+// the layouts a time in a rule can be written in
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// compareTime compares against a time.Time, a string in one of timeLayouts,
+// or a number of seconds since the Unix epoch
+func compareTime(a time.Time, expected interface{}) (int, error) {
+	b, err := toTime(expected)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case a.Before(b):
+		return -1, nil
+	case a.After(b):
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// toTime reads a time.Time, a string in one of timeLayouts,
+// or a number of seconds since the Unix epoch
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q isn't a time", t)
+	}
+
+	secs, ok := toFloat(v)
+	if !ok {
+		return time.Time{}, fmt.Errorf("can't compare a time to a %T", v)
+	}
+
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
+
+// compareDuration compares against a time.Duration, a string like "1h30m",
+// or a number of seconds
+func compareDuration(a time.Duration, expected interface{}) (int, error) {
+	var b time.Duration
+	switch v := expected.(type) {
+	case time.Duration:
+		b = v
+	case string:
+		var err error
+		if b, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("%q isn't a duration", v)
+		}
+	default:
+		secs, ok := toFloat(expected)
+		if !ok {
+			return 0, fmt.Errorf("can't compare a duration to a %T", expected)
+		}
+		b = time.Duration(secs * float64(time.Second))
+	}
+
+	switch {
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// compareIP compares against a net.IP or a string address
+// IPv4 addresses and their IPv6 forms are equal, and ordering is by address bytes
+func compareIP(a net.IP, expected interface{}) (int, error) {
+	var b net.IP
+	switch v := expected.(type) {
+	case net.IP:
+		b = v
+	case string:
+		if b = net.ParseIP(v); b == nil {
+			return 0, fmt.Errorf("%q isn't an IP address", v)
+		}
+	default:
+		return 0, fmt.Errorf("can't compare an IP address to a %T", expected)
+	}
+
+	return bytes.Compare(a.To16(), b.To16()), nil
+}
@@ -0,0 +1,78 @@
+package ruler
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Overlay is a patch for a base ruleset, like the changes one environment
+// or tenant needs, so near-duplicate copies of the same rules aren't needed
+//
+//	{
+//		"name": "staging",
+//		"remove": ["require_mfa"],
+//		"override": [{"id": "max_amount", "comparator": "lte", "path": "amount", "value": 1000000}],
+//		"add": [{"id": "test_accounts_only", "comparator": "eq", "path": "account.test", "value": true}]
+//	}
+//
+// rules are found by ID, wherever they're nested
+type Overlay struct {
+	// Name is for errors, so a bad overlay can be found among several
+	Name string `json:"name,omitempty"`
+	// Remove are the IDs of the rules the overlay takes out
+	Remove []string `json:"remove,omitempty"`
+	// Override are rules that replace the base rules with the same IDs
+	Override []*Rule `json:"override,omitempty"`
+	// Add are new rules put after the rest
+	Add []*Rule `json:"add,omitempty"`
+}
+
+// ParseOverlay reads an Overlay from JSON
+func ParseOverlay(data []byte) (*Overlay, error) {
+	var o Overlay
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// Overlay resolves overlays on top of r's rules, in order, into a new
+// effective Ruler with r's options; r itself isn't changed
+// each overlay removes, then overrides, then adds, and it's an error for one
+// to remove or override a rule that isn't there, so a typo doesn't go unnoticed
+func (r *Ruler) Overlay(overlays ...*Overlay) (*Ruler, error) {
+	c := r.Clone()
+	for _, o := range overlays {
+		if err := c.applyOverlay(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *Ruler) applyOverlay(o *Overlay) error {
+	for _, id := range o.Remove {
+		if !r.RemoveRule(id) {
+			return fmt.Errorf("overlay %s: no rule (%s) to remove", o.Name, id)
+		}
+	}
+
+	for _, f := range o.Override {
+		if f.ID == "" {
+			return fmt.Errorf("overlay %s: override on (%s) has no ID", o.Name, f.Path)
+		}
+		if !r.ReplaceRule(f.ID, f.Clone()) {
+			return fmt.Errorf("overlay %s: no rule (%s) to override", o.Name, f.ID)
+		}
+	}
+
+	r.rules = append(r.rules, cloneRules(o.Add)...)
+
+	return nil
+}
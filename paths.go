@@ -0,0 +1,107 @@
+package ruler
+
+import "strings"
+
+// Paths lists every document path the Ruler's rules look at,
+// including value_path references, rules inside groups and the rules
+// of the rulesets they refer to, in the order they first show up
+// (with functions like len() taken off)
+// handy for fetching only the fields you need before testing a document
+func (r *Ruler) Paths() []string {
+	pc := newPathCollector()
+	pc.ruler(r)
+
+	return pc.paths
+}
+
+// Paths is the compiled version of Ruler's Paths
+// references follow the rulesets as they were when it was compiled
+func (c *CompiledRuler) Paths() []string {
+	pc := newPathCollector()
+	pc.compiled(c)
+
+	return pc.paths
+}
+
+// pathCollector gathers the paths of rules, following references
+// to each ruleset once, which also keeps it out of cycles
+type pathCollector struct {
+	paths    []string
+	seen     map[string]bool
+	followed map[interface{}]bool
+}
+
+func newPathCollector() *pathCollector {
+	return &pathCollector{seen: make(map[string]bool), followed: make(map[interface{}]bool)}
+}
+
+func (pc *pathCollector) add(p string) {
+	// the property under any functions, len(tags) looks at tags
+	p, _ = pathFunctions(p)
+	if p == ctxPrefix || strings.HasPrefix(p, ctxPrefix+".") {
+		// context vars aren't in the document
+		return
+	}
+	if p != "" && !pc.seen[p] {
+		pc.seen[p] = true
+		pc.paths = append(pc.paths, p)
+	}
+}
+
+func (pc *pathCollector) ruler(r *Ruler) {
+	if pc.followed[r] {
+		return
+	}
+	pc.followed[r] = true
+
+	var collect func(rules []*Rule)
+	collect = func(rules []*Rule) {
+		for _, f := range rules {
+			if f.IsGroup() {
+				collect(f.All)
+				collect(f.Any)
+				if f.Not != nil {
+					collect([]*Rule{f.Not})
+				}
+				if f.Ruleset != "" && r.rulerSet != nil {
+					if ref := r.rulerSet.Get(f.Ruleset); ref != nil {
+						pc.ruler(ref)
+					}
+				}
+				continue
+			}
+
+			pc.add(f.Path)
+			pc.add(f.ValuePath)
+		}
+	}
+	collect(r.rules)
+}
+
+func (pc *pathCollector) compiled(c *CompiledRuler) {
+	if pc.followed[c] {
+		return
+	}
+	pc.followed[c] = true
+
+	var collect func(rules []*compiledRule)
+	collect = func(rules []*compiledRule) {
+		for _, f := range rules {
+			if f.IsGroup() {
+				collect(f.all)
+				collect(f.any)
+				if f.not != nil {
+					collect([]*compiledRule{f.not})
+				}
+				if f.ref != nil {
+					pc.compiled(f.ref)
+				}
+				continue
+			}
+
+			pc.add(f.Path)
+			pc.add(f.ValuePath)
+		}
+	}
+	collect(c.rules)
+}
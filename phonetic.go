@@ -0,0 +1,219 @@
+package ruler
+
+import (
+	"errors"
+	"strings"
+)
+
+// soundsLike compares two names by how they sound, a word at a time,
+// so "Jon Smith" sounds like "John Smyth" but not "Smith"
+// encode is soundex or metaphone
+func soundsLike(actual, expected interface{}, encode func(word string) string) (bool, error) {
+	astring, ok := actual.(string)
+	if !ok {
+		return false, errors.New("actual value not actually a string, bailing")
+	}
+	estring, ok := expected.(string)
+	if !ok {
+		return false, errors.New("expected value not actually a string, bailing")
+	}
+
+	a, e := phoneticWords(astring), phoneticWords(estring)
+	if len(e) == 0 || len(a) != len(e) {
+		return false, nil
+	}
+	for i := range a {
+		if encode(a[i]) != encode(e[i]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// apostrophes are part of a word, O'Brien is one name
+var apostrophes = strings.NewReplacer("'", "", "’", "")
+
+// phoneticWords splits a name into upper case words of plain A to Z letters,
+// with accents taken off and anything else treated as a space
+func phoneticWords(s string) []string {
+	s = apostrophes.Replace(stripDiacritics(s))
+	return strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return r < 'A' || r > 'Z'
+	})
+}
+
+// soundexCodes are the digits soundex gives each letter,
+// 0 for vowels, which keep letters either side of them apart,
+// and - for H and W, which don't
+var soundexCodes = [26]byte{
+	'0', '1', '2', '3', '0', '1', '2', '-', '0', '2', '2', '4', '5',
+	'5', '0', '1', '2', '6', '2', '3', '0', '1', '-', '2', '0', '2',
+}
+
+// soundex is the American Soundex code of an upper case word, like R163 for ROBERT
+func soundex(word string) string {
+	if word == "" {
+		return ""
+	}
+
+	code := []byte{word[0], '0', '0', '0'}
+	n := 1
+	last := soundexCodes[word[0]-'A']
+	for i := 1; i < len(word) && n < len(code); i++ {
+		c := soundexCodes[word[i]-'A']
+		switch {
+		case c == '-':
+		case c == '0':
+			last = c
+		case c != last:
+			code[n] = c
+			n++
+			last = c
+		}
+	}
+
+	return string(code)
+}
+
+// metaphone is Lawrence Philips' original Metaphone code of an upper case word,
+// like SM0 for SMITH, with 0 standing for TH and X for SH
+func metaphone(word string) string {
+	// the start of some words isn't said the way it's spelled
+	switch {
+	case strings.HasPrefix(word, "AE"):
+		word = word[1:]
+	case strings.HasPrefix(word, "GN"), strings.HasPrefix(word, "KN"),
+		strings.HasPrefix(word, "PN"), strings.HasPrefix(word, "WR"):
+		word = word[1:]
+	case strings.HasPrefix(word, "X"):
+		word = "S" + word[1:]
+	case strings.HasPrefix(word, "WH"):
+		word = "W" + word[2:]
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(word) {
+			return 0
+		}
+		return word[i]
+	}
+	vowel := func(c byte) bool {
+		return c == 'A' || c == 'E' || c == 'I' || c == 'O' || c == 'U'
+	}
+	soft := func(c byte) bool {
+		return c == 'E' || c == 'I' || c == 'Y'
+	}
+
+	var code strings.Builder
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		// doubled letters are said once, except for CC as in ACCENT
+		if c != 'C' && c == at(i-1) {
+			continue
+		}
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				code.WriteByte(c)
+			}
+		case 'B':
+			// silent at the end after M, as in DUMB
+			if !(at(i-1) == 'M' && i == len(word)-1) {
+				code.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case at(i+1) == 'I' && at(i+2) == 'A':
+				code.WriteByte('X')
+			case at(i+1) == 'H':
+				if at(i-1) == 'S' {
+					code.WriteByte('K')
+				} else {
+					code.WriteByte('X')
+				}
+				i++
+			case soft(at(i + 1)):
+				// silent in SCI, SCE and SCY
+				if at(i-1) != 'S' {
+					code.WriteByte('S')
+				}
+			default:
+				code.WriteByte('K')
+			}
+		case 'D':
+			if at(i+1) == 'G' && soft(at(i+2)) {
+				code.WriteByte('J')
+				i += 2
+			} else {
+				code.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case at(i+1) == 'H' && i+2 < len(word) && !vowel(at(i+2)):
+				// silent in the middle of NIGHT
+			case at(i+1) == 'N' && (i+2 == len(word) || word[i+2:] == "ED"):
+				// silent in SIGN and SIGNED
+			case soft(at(i+1)) && at(i-1) != 'G':
+				code.WriteByte('J')
+			default:
+				code.WriteByte('K')
+			}
+		case 'H':
+			// only said before a vowel, and not after the letters it changes
+			if vowel(at(i+1)) && !strings.ContainsRune("CGPST", rune(at(i-1))) {
+				code.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				code.WriteByte('K')
+			}
+		case 'P':
+			if at(i+1) == 'H' {
+				code.WriteByte('F')
+			} else {
+				code.WriteByte('P')
+			}
+		case 'Q':
+			code.WriteByte('K')
+		case 'S':
+			switch {
+			case at(i+1) == 'H':
+				code.WriteByte('X')
+				i++
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code.WriteByte('X')
+			default:
+				code.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code.WriteByte('X')
+			case at(i+1) == 'H':
+				code.WriteByte('0')
+				i++
+			case at(i+1) == 'C' && at(i+2) == 'H':
+				// silent in MATCH
+			default:
+				code.WriteByte('T')
+			}
+		case 'V':
+			code.WriteByte('F')
+		case 'W', 'Y':
+			if vowel(at(i + 1)) {
+				code.WriteByte(c)
+			}
+		case 'X':
+			code.WriteString("KS")
+		case 'Z':
+			code.WriteByte('S')
+		default:
+			// F, J, L, M, N and R are said the way they're written
+			code.WriteByte(c)
+		}
+	}
+
+	return code.String()
+}
@@ -0,0 +1,206 @@
+package ruler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// TestJSON tests a JSON document against the rules without unmarshaling all of it:
+// only the parts of the document the rules have paths into are decoded,
+// everything else is scanned past, which is a lot cheaper for giant documents
+// that only have a few properties anyone cares about
+func (r *Ruler) TestJSON(data []byte) (bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return false, err
+	}
+
+	return c.TestJSON(data)
+}
+
+// TestJSON is the compiled version of Ruler's TestJSON
+func (c *CompiledRuler) TestJSON(data []byte) (bool, error) {
+	doc, err := c.decodeJSON(data)
+	if err != nil {
+		return false, err
+	}
+
+	return c.Test(doc)
+}
+
+// decodeJSON decodes as much of data as the rules need
+func (c *CompiledRuler) decodeJSON(data []byte) (map[string]interface{}, error) {
+	p := &partialJSON{paths: c.needed}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+
+	switch doc := p.v.(type) {
+	case map[string]interface{}:
+		return doc, nil
+	case nil:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("TestJSON needs a JSON object, not %s", jsonKind(p.v))
+}
+
+// neededPaths works out which parts of a document the rules read
+func (c *CompiledRuler) neededPaths() *jsonPaths {
+	t := &jsonPaths{}
+	// audit records a digest of the whole document, so it needs all of it,
+	// and the keys of a flattened one aren't path segments
+	if c.ruler.audit != nil || c.ruler.flattened {
+		t.all = true
+	}
+	for _, cf := range c.rules {
+		t.addRule(cf)
+	}
+
+	return t
+}
+
+// jsonPaths is a tree of the paths rules read, one level per path segment
+type jsonPaths struct {
+	// all is set when the whole value is needed: a path ends here,
+	// or goes through a wildcard
+	all      bool
+	children map[string]*jsonPaths
+}
+
+func (t *jsonPaths) addRule(cf *compiledRule) {
+	if cf.path != nil && !cf.path.vars {
+		t.add(cf.path.parts)
+	}
+	if cf.valuePath != nil && !cf.valuePath.vars {
+		t.add(cf.valuePath.parts)
+	}
+	for _, sub := range cf.all {
+		t.addRule(sub)
+	}
+	for _, sub := range cf.any {
+		t.addRule(sub)
+	}
+	if cf.not != nil {
+		t.addRule(cf.not)
+	}
+	if cf.ref != nil {
+		t.merge(cf.ref.needed)
+	}
+}
+
+// merge adds every path in other to t
+func (t *jsonPaths) merge(other *jsonPaths) {
+	if t.all {
+		return
+	}
+	if other.all {
+		t.all = true
+		t.children = nil
+		return
+	}
+
+	for part, child := range other.children {
+		next, ok := t.children[part]
+		if !ok {
+			if t.children == nil {
+				t.children = make(map[string]*jsonPaths)
+			}
+			next = &jsonPaths{}
+			t.children[part] = next
+		}
+		next.merge(child)
+	}
+}
+
+func (t *jsonPaths) add(parts []string) {
+	for _, part := range parts {
+		if t.all {
+			return
+		}
+		if part == "*" {
+			break
+		}
+
+		next, ok := t.children[part]
+		if !ok {
+			if t.children == nil {
+				t.children = make(map[string]*jsonPaths)
+			}
+			next = &jsonPaths{}
+			t.children[part] = next
+		}
+		t = next
+	}
+
+	t.all = true
+	t.children = nil
+}
+
+// partialJSON decodes the parts of a JSON value its paths need,
+// objects keep only the properties that are on a path
+type partialJSON struct {
+	paths *jsonPaths
+	v     interface{}
+}
+
+func (p *partialJSON) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if p.paths.all || len(data) == 0 || data[0] != '{' {
+		// arrays are decoded whole, paths into them are rare
+		// and they're usually what a wildcard wants anyway
+		return json.Unmarshal(data, &p.v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	m := make(map[string]interface{}, len(p.paths.children))
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		next, ok := p.paths.children[key]
+		if !ok {
+			if err := dec.Decode(&skipJSON{}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sub := &partialJSON{paths: next}
+		if err := dec.Decode(sub); err != nil {
+			return err
+		}
+		m[key] = sub.v
+	}
+
+	p.v = m
+	return nil
+}
+
+// skipJSON is decoded into to get past a value without doing anything with it
+type skipJSON struct{}
+
+func (*skipJSON) UnmarshalJSON([]byte) error { return nil }
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
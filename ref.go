@@ -0,0 +1,78 @@
+package ruler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithRulerSet lets rules refer to the other rulesets in s by name, like
+//
+//	{"ruleset": "is_premium_user"}
+//
+// which passes when the document matches every rule in the Ruler
+// s has under that name, so shared conditions are only written once
+// references are resolved when the rules are compiled, so a CompiledRuler
+// keeps the rulesets as they were then
+func WithRulerSet(s *RulerSet) Option {
+	return func(r *Ruler) {
+		r.rulerSet = s
+	}
+}
+
+// compileRef compiles the ruleset a rule refers to
+func (r *Ruler) compileRef(f *Rule) (*CompiledRuler, error) {
+	if r.rulerSet == nil {
+		return nil, fmt.Errorf("ruleset reference (%s) needs a RulerSet, see WithRulerSet", f.Ruleset)
+	}
+	ref := r.rulerSet.Get(f.Ruleset)
+	if ref == nil {
+		return nil, fmt.Errorf("unknown ruleset %s", f.Ruleset)
+	}
+
+	return ref.Compile()
+}
+
+// checkRefs makes sure no ruleset refers back to itself,
+// directly or through others, before anything's compiled
+func (r *Ruler) checkRefs() error {
+	return r.walkRefs(nil, map[*Ruler]bool{r: true})
+}
+
+// walkRefs follows every reference in r's rules, with the names
+// followed to get here in trail and the rulers on the way in visiting
+func (r *Ruler) walkRefs(trail []string, visiting map[*Ruler]bool) error {
+	var err error
+	var walk func(rules []*Rule)
+	walk = func(rules []*Rule) {
+		for _, f := range rules {
+			if err != nil {
+				return
+			}
+			walk(f.All)
+			walk(f.Any)
+			if f.Not != nil {
+				walk([]*Rule{f.Not})
+			}
+			if f.Ruleset == "" || r.rulerSet == nil {
+				continue
+			}
+
+			ref := r.rulerSet.Get(f.Ruleset)
+			if ref == nil {
+				// compiling it will say so
+				continue
+			}
+			path := append(trail[:len(trail):len(trail)], f.Ruleset)
+			if visiting[ref] {
+				err = fmt.Errorf("ruleset reference cycle: %s", strings.Join(path, " -> "))
+				return
+			}
+			visiting[ref] = true
+			err = ref.walkRefs(path, visiting)
+			delete(visiting, ref)
+		}
+	}
+	walk(r.rules)
+
+	return err
+}
@@ -0,0 +1,44 @@
+package ruler
+
+import "regexp"
+
+// A Matcher is a compiled regex, all a rule needs from one is whether it matches
+// *regexp.Regexp is a Matcher
+type Matcher interface {
+	MatchString(s string) bool
+}
+
+// A RegexEngine compiles the patterns of regex, matches, contains and ncontains rules,
+// so rulesets with thousands of patterns can use something faster than regexp,
+// like a prefiltered literal matcher or hyperscan bindings
+// the patterns are the same strings a rule holds, so an engine should
+// understand the same syntax regexp does for the rules you give it
+type RegexEngine interface {
+	Compile(pattern string) (Matcher, error)
+}
+
+// RegexEngineFunc lets a plain function be a RegexEngine
+type RegexEngineFunc func(pattern string) (Matcher, error)
+
+// Compile calls fn
+func (fn RegexEngineFunc) Compile(pattern string) (Matcher, error) {
+	return fn(pattern)
+}
+
+// StdRegexp is the RegexEngine a Ruler uses unless it's given another, Go's regexp
+var StdRegexp RegexEngine = RegexEngineFunc(func(pattern string) (Matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return re, nil
+})
+
+// WithRegexEngine compiles the Ruler's regexes with engine instead of regexp
+// the MaxRegexLength, MaxRegexInput and RegexTimeout limits still apply
+func WithRegexEngine(engine RegexEngine) Option {
+	return func(r *Ruler) {
+		r.regexEngine = engine
+	}
+}
@@ -0,0 +1,294 @@
+package ruler
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// regexSetMin is how many regexes a path needs before they're worth
+// prefiltering as a set, fewer than that and running them all is cheap enough
+const regexSetMin = 16
+
+// regexSet prefilters the regexes of the rules on one path
+// every pattern is boiled down to a literal any match has to contain,
+// and one pass over a string finds all of those literals at once,
+// so only the regexes whose literal turned up are run at all
+type regexSet struct {
+	// literals is an Aho-Corasick automaton over the distinct literals
+	literals *literalMatcher
+	// members are the rules in the set for each literal
+	members [][]int
+	// always are the rules with no literal, which always have to be run
+	always []uint64
+}
+
+// compileRegexSets gives the regex rules in each of c's runs on a path a regexSet,
+// when there are enough of them and they were compiled with regexp
+// other engines and lazily compiled regexes are left alone
+func (c *CompiledRuler) compileRegexSets() {
+	if c.ruler.regexEngine != nil || c.ruler.lazyRegexps {
+		return
+	}
+
+	for _, pr := range c.index {
+		if pr.path == nil {
+			continue
+		}
+
+		var rules []*compiledRule
+		for _, f := range pr.rules {
+			if f.re != nil {
+				rules = append(rules, f)
+			}
+		}
+		if len(rules) < regexSetMin {
+			continue
+		}
+
+		set := newRegexSet(rules)
+		for i, f := range rules {
+			f.set, f.member = set, i
+		}
+	}
+}
+
+func newRegexSet(rules []*compiledRule) *regexSet {
+	set := &regexSet{always: make([]uint64, (len(rules)+63)/64)}
+
+	ids := make(map[string]int)
+	var literals []string
+	for i, f := range rules {
+		lit := requiredLiteral(f.Value.(string))
+		if lit == "" {
+			set.always[i/64] |= 1 << (i % 64)
+			continue
+		}
+
+		id, ok := ids[lit]
+		if !ok {
+			id = len(literals)
+			ids[lit] = id
+			literals = append(literals, lit)
+			set.members = append(set.members, nil)
+		}
+		set.members[id] = append(set.members[id], i)
+	}
+	set.literals = newLiteralMatcher(literals)
+
+	return set
+}
+
+// requiredLiteral is the longest literal string every match of pattern
+// has to contain, or "" if there isn't one worth looking for
+func requiredLiteral(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	lit := mustContain(re.Simplify())
+	// regexp reads bad UTF-8 as utf8.RuneError, so a literal with one
+	// can match bytes that don't look anything like it
+	if strings.ContainsRune(lit, utf8.RuneError) {
+		return ""
+	}
+
+	return lit
+}
+
+func mustContain(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return ""
+		}
+		return string(re.Rune)
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return mustContain(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return ""
+		}
+		return mustContain(re.Sub[0])
+
+	case syntax.OpConcat:
+		// runs of literals next to each other have to show up together,
+		// anything else in between only promises whatever it must contain itself
+		var run strings.Builder
+		longest := ""
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0 {
+				run.WriteString(string(sub.Rune))
+				continue
+			}
+			if run.Len() > len(longest) {
+				longest = run.String()
+			}
+			run.Reset()
+			if lit := mustContain(sub); len(lit) > len(longest) {
+				longest = lit
+			}
+		}
+		if run.Len() > len(longest) {
+			longest = run.String()
+		}
+		return longest
+	}
+
+	return ""
+}
+
+// regexScan is what a regexSet found in the last string it scanned
+// during an evaluation, so every rule in the set after the first
+// is only a bit lookup
+type regexScan struct {
+	set   *regexSet
+	input string
+	hits  []uint64
+}
+
+// mayMatch reports whether f's regex could match s, scanning s with
+// f's regexSet unless it was the last string the set scanned
+func (e *evaluation) mayMatch(f *compiledRule, s string) bool {
+	var scan *regexScan
+	free := -1
+	for i := range e.scans {
+		if e.scans[i].set == f.set {
+			scan = &e.scans[i]
+			break
+		}
+		if free < 0 && e.scans[i].set == nil {
+			free = i
+		}
+	}
+
+	if scan == nil {
+		if free < 0 {
+			e.scans = append(e.scans, regexScan{})
+			free = len(e.scans) - 1
+		}
+		scan = &e.scans[free]
+		scan.set = f.set
+		f.set.scan(scan, s)
+	} else if scan.input != s {
+		f.set.scan(scan, s)
+	}
+
+	return scan.hits[f.member/64]&(1<<(f.member%64)) != 0
+}
+
+// scan finds the rules whose literals are in s
+func (set *regexSet) scan(scan *regexScan, s string) {
+	scan.input = s
+	if cap(scan.hits) < len(set.always) {
+		scan.hits = make([]uint64, len(set.always))
+	}
+	scan.hits = scan.hits[:len(set.always)]
+	copy(scan.hits, set.always)
+
+	set.literals.find(s, func(id int) {
+		for _, i := range set.members[id] {
+			scan.hits[i/64] |= 1 << (i % 64)
+		}
+	})
+}
+
+// literalMatcher finds every one of a set of literals in a string in one pass,
+// it's an Aho-Corasick automaton turned into a DFA over the bytes the literals use
+type literalMatcher struct {
+	// classes maps each byte to its column in next, 0 for bytes no literal has
+	classes [256]uint16
+	width   int
+	// next is the state after each state and byte class
+	next []int32
+	// out is the literal that ends at each state, -1 for none,
+	// and dict the next state down its fail links that ends one
+	out  []int32
+	dict []int32
+}
+
+func newLiteralMatcher(literals []string) *literalMatcher {
+	m := &literalMatcher{width: 1}
+	for _, lit := range literals {
+		for i := 0; i < len(lit); i++ {
+			if m.classes[lit[i]] == 0 {
+				m.classes[lit[i]] = uint16(m.width)
+				m.width++
+			}
+		}
+	}
+
+	// build the trie, with -1 for edges it doesn't have yet
+	m.addState()
+	for id, lit := range literals {
+		state := int32(0)
+		for i := 0; i < len(lit); i++ {
+			edge := int(state)*m.width + int(m.classes[lit[i]])
+			if m.next[edge] < 0 {
+				m.next[edge] = m.addState()
+			}
+			state = m.next[edge]
+		}
+		m.out[state] = int32(id)
+	}
+
+	// then fill in the missing edges breadth first from the fail links
+	fail := make([]int32, len(m.out))
+	queue := make([]int32, 0, len(m.out))
+	for c := 0; c < m.width; c++ {
+		if s := m.next[c]; s < 0 {
+			m.next[c] = 0
+		} else {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		if f := fail[state]; m.out[f] >= 0 {
+			m.dict[state] = f
+		} else {
+			m.dict[state] = m.dict[f]
+		}
+
+		for c := 0; c < m.width; c++ {
+			edge := int(state)*m.width + c
+			via := m.next[int(fail[state])*m.width+c]
+			if s := m.next[edge]; s < 0 {
+				m.next[edge] = via
+			} else {
+				fail[s] = via
+				queue = append(queue, s)
+			}
+		}
+	}
+
+	return m
+}
+
+func (m *literalMatcher) addState() int32 {
+	for c := 0; c < m.width; c++ {
+		m.next = append(m.next, -1)
+	}
+	m.out = append(m.out, -1)
+	m.dict = append(m.dict, 0)
+	return int32(len(m.out) - 1)
+}
+
+// find calls found with the id of every literal in s,
+// once for each place it ends
+func (m *literalMatcher) find(s string, found func(id int)) {
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = m.next[int(state)*m.width+int(m.classes[s[i]])]
+		for o := state; o != 0; o = m.dict[o] {
+			if m.out[o] >= 0 {
+				found(int(m.out[o]))
+			}
+		}
+	}
+}
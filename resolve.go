@@ -0,0 +1,191 @@
+package ruler
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// fieldPath is a rule's path split into segments, along with
+// the key for every prefix of it, e.g. "a", "a.b", "a.b.c"
+type fieldPath struct {
+	parts []string
+	keys  []string
+	// wild is the index of the first wildcard segment, or len(parts)
+	wild int
+	// fns are the functions wrapped around the path, like len(tags),
+	// innermost first
+	fns   []Transform
+	names []string
+	// vars is set for a $ctx path, which reads the context's vars, see ContextWithVars
+	vars bool
+}
+
+// compilePath compiles a path that can be wrapped in functions, e.g. lower(trim(email))
+// the functions are the same ones rules can list in Transforms
+func (r *Ruler) compilePath(path string) (*fieldPath, error) {
+	inner, names := pathFunctions(path)
+
+	p := compilePath(inner)
+	for _, name := range names {
+		fn, ok := r.transform(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s in path (%s)", name, path)
+		}
+		p.fns = append(p.fns, fn)
+		p.names = append(p.names, name)
+	}
+
+	return p, nil
+}
+
+// pathFunctions peels function calls off a path, returning
+// the plain path and the function names, innermost first
+func pathFunctions(path string) (string, []string) {
+	var names []string
+	for strings.HasSuffix(path, ")") {
+		open := strings.Index(path, "(")
+		if open < 1 || strings.IndexFunc(path[:open], func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_'
+		}) >= 0 {
+			break
+		}
+		names = append([]string{path[:open]}, names...)
+		path = path[open+1 : len(path)-1]
+	}
+
+	return path, names
+}
+
+// hasPathFunctions reports whether either of f's paths is wrapped in functions
+func hasPathFunctions(f *Rule) bool {
+	_, names := pathFunctions(f.Path)
+	_, valueNames := pathFunctions(f.ValuePath)
+	return len(names) > 0 || len(valueNames) > 0
+}
+
+func compilePath(path string) *fieldPath {
+	p := &fieldPath{
+		parts: splitPath(path),
+	}
+
+	p.vars = len(p.parts) > 0 && p.parts[0] == ctxPrefix
+	p.wild = len(p.parts)
+	p.keys = make([]string, len(p.parts))
+	for i, part := range p.parts {
+		p.keys[i] = strings.Join(p.parts[:i+1], ".")
+		if part == "*" && i < p.wild {
+			p.wild = i
+		}
+	}
+
+	return p
+}
+
+// pluck pulls a property from the document being evaluated,
+// keeping track of how much of the document has been walked
+// every prefix along the way is remembered, so rules on the same path
+// or on paths that share a prefix (user.profile.*) don't walk it again
+func (e *evaluation) pluck(p *fieldPath) (interface{}, error) {
+	if p.vars {
+		return e.contextVar(p)
+	}
+
+	// start from the longest prefix we've already been down
+	var v interface{} = e.doc
+	if e.root != nil {
+		v = e.root
+	}
+	start := 0
+	for i := len(p.parts) - 1; i >= 0; i-- {
+		if i >= p.wild && i != len(p.parts)-1 {
+			// a wildcard's result is a flat array, not something
+			// the rest of a longer path can be walked from
+			continue
+		}
+		if cv, ok := e.resolved[p.keys[i]]; ok {
+			v, start = cv, i+1
+			break
+		}
+	}
+	if e.flat && e.root == nil {
+		// a flattened document might have the whole path, or more of it, as one key
+		for i := len(p.parts) - 1; i > 0 && i >= start; i-- {
+			if i >= p.wild {
+				continue
+			}
+			if fv, ok := e.doc[p.keys[i]]; ok {
+				if fv = resolveLazy(fv, &e.nodes); e.nodes.err == nil {
+					e.remember(p.keys[i], fv)
+				}
+				v, start = fv, i+1
+				break
+			}
+		}
+	}
+
+	for i := start; i < len(p.parts); i++ {
+		if v == nil {
+			break
+		}
+
+		if p.parts[i] == "*" {
+			// results under a wildcard depend on every element,
+			// so just walk the rest of the way
+			v = pluckParts(v, p.parts[i:], &e.nodes)
+			if !e.nodes.exceeded() && e.nodes.err == nil {
+				e.remember(p.keys[len(p.keys)-1], v)
+			}
+			break
+		}
+
+		v = pluckParts(v, p.parts[i:i+1], &e.nodes)
+		if e.nodes.exceeded() || e.nodes.err != nil {
+			break
+		}
+		e.remember(p.keys[i], v)
+	}
+
+	if e.nodes.exceeded() {
+		return nil, &LimitError{"MaxDocumentNodes", e.nodes.max}
+	}
+	if err := e.nodes.lazyErr(); err != nil {
+		// not remembered, so every rule that needs it gets the error
+		return nil, fmt.Errorf("resolving (%s): %w", strings.Join(p.parts, "."), err)
+	}
+
+	// functions run on the finished value, which is what gets remembered
+	// above, so every path with the same property shares one walk
+	return p.apply(v)
+}
+
+// apply runs the path's functions on a value plucked from it
+func (p *fieldPath) apply(v interface{}) (interface{}, error) {
+	var err error
+	for i, fn := range p.fns {
+		if v == nil {
+			break
+		}
+		if v, err = fn(v); err != nil {
+			return nil, fmt.Errorf("%s(%s): %w", p.names[i], strings.Join(p.parts, "."), err)
+		}
+	}
+
+	return v, nil
+}
+
+func (e *evaluation) remember(key string, v interface{}) {
+	if e.resolved == nil {
+		e.resolved = make(map[string]interface{})
+	}
+
+	e.resolved[key] = v
+}
+
+// forget drops every path remembered so far, for when e moves on to another document
+func (e *evaluation) forget() {
+	for k := range e.resolved {
+		delete(e.resolved, k)
+	}
+	e.nodes.count = 0
+}
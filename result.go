@@ -0,0 +1,42 @@
+package ruler
+
+import "sync"
+
+// Result is the outcome of testing one document against a set of rules
+// see Release if you evaluate enough documents for garbage to matter
+type Result struct {
+	// Matched is true when the document passed every rule
+	Matched bool
+	// Failed is the rule that kept the document from matching, if any
+	Failed *Rule
+	// Message is the failed rule's Message, filled in for this document
+	Message string
+	// Err is set when the rules couldn't be tested against the document
+	Err error
+	// Version is the version of the rules that were tested, see Ruler's Version
+	Version string
+	// Captures are the named groups regex_extract rules pulled out of the document,
+	// from every one that matched before the rules stopped
+	Captures map[string]string
+}
+
+// results are reused once they've been released
+var results = sync.Pool{
+	New: func() interface{} { return new(Result) },
+}
+
+// newResult is a Result from the pool, set to res
+func newResult(res Result) *Result {
+	pooled := results.Get().(*Result)
+	*pooled = res
+
+	return pooled
+}
+
+// Release hands the Result back so a later Evaluate can reuse it,
+// which cuts down on garbage when you're evaluating lots of documents
+// calling it is optional, but once you have don't use the Result again
+func (res *Result) Release() {
+	*res = Result{}
+	results.Put(res)
+}
@@ -0,0 +1,54 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// how finely the percent comparator slices things up,
+// 10000 buckets gets us down to a hundredth of a percent
+const rolloutBuckets = 10000
+
+// inRollout checks whether actual lands in the first N percent of buckets
+// expected is either the percentage itself, or an object like
+//
+//	{"percent": 25, "salt": "new-checkout"}
+//
+// the salt keeps different rollouts on the same key from picking the same values
+func inRollout(actual, expected interface{}) (bool, error) {
+	var pct float64
+	var salt string
+
+	if m, ok := asMap(expected); ok {
+		if pct, ok = toFloat(m["percent"]); !ok {
+			return false, errors.New("percent not actually a number, bailing")
+		}
+		if s, found := m["salt"]; found {
+			if salt, ok = s.(string); !ok {
+				return false, errors.New("salt not actually a string, bailing")
+			}
+		}
+	} else if pct, ok = toFloat(expected); !ok {
+		return false, errors.New("expected value not actually a percentage, bailing")
+	}
+
+	return rolloutBucket(salt, actual) < pct*rolloutBuckets/100, nil
+}
+
+// rolloutPercent pulls the percentage out of a percent rule's value
+func rolloutPercent(expected interface{}) (float64, bool) {
+	if m, ok := asMap(expected); ok {
+		return toFloat(m["percent"])
+	}
+
+	return toFloat(expected)
+}
+
+// rolloutBucket hashes the salt and value into one of rolloutBuckets buckets
+func rolloutBucket(salt string, v interface{}) float64 {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%v", salt, v)
+
+	return float64(h.Sum32() % rolloutBuckets)
+}
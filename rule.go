@@ -1,5 +1,10 @@
 package ruler
 
+import (
+	"encoding/json"
+	"time"
+)
+
 /*
 This struct is the main format for rules or conditions in ruler-compatable libraries.
 Here's a sample in JSON format:
@@ -9,15 +14,232 @@ Here's a sample in JSON format:
 		"value": "James"
 	}
 
-Valid comparators are: eq, neq, lt, lte, gt, gte, contains (regex), ncontains (!regex)
+Valid comparators are: eq, neq, lt, lte, gt, gte, contains (regex), ncontains (!regex),
+deep_eq, deep_neq (structural equality for objects and arrays),
+supermap (the object contains at least the given key/value pairs),
+subset, superset, intersects (set comparisons between arrays),
+haskey (the object has the given key, or every key in an array of keys),
+percent (the value hashes into the given percentage, for gradual rollouts),
+approx_eq (the number is within a tolerance of the value, either the Ruler's epsilon
+or one given with the value as {"value": 20, "epsilon": 0.01}),
+istrue, isfalse (the property is that boolean, no value needed),
+is_email, is_url, is_uuid (the property is a string in that format; is_url can
+be given an array of allowed schemes),
+mime_type (the content type matches a pattern like "image/*", or any in an array),
+extension (the file name ends in the extension, or any in an array, ignoring case),
+bitand_any, bitand_all (the property has any or all of the value's bits set,
+the mask can be a number or a string like "0x0c"),
+mod (the integer property divided by the value's divisor leaves its remainder,
+given as {"divisor": 10, "remainder": 3}, or just the divisor for a remainder of 0),
+lookup (the lookup registered under the value's name, see WithLookup, says something
+truthy about the property),
+business_hours (the timestamp is within the hours registered under the value's name,
+see WithBusinessHours, or given as an object like {"days": ["mon", "fri"], "open": "09:00",
+"close": "17:00", "timezone": "Europe/London", "calendar": "uk"}, where every field is optional
+and the calendar's holidays, see WithCalendar, are closed),
+threshold (the property's value has been seen more than count times within a window,
+given as {"counter": "failed_logins", "count": 5, "within": "10m"}, counting every
+document the rule is tested against in the Ruler's StateStore, see WithState),
+regex_extract (like regex, but the named groups of a match end up in the Result's Captures),
+soundex, metaphone (the name sounds like the value's, word for word by that phonetic code,
+so "Jon Smyth" matches "John Smith", accents are ignored)
+
+The comparator can also be written as an operator, which is turned into
+its name when the rule is decoded: == (eq), != (neq), > (gt), >= (gte),
+< (lt), <= (lte) and ~= (matches)
+
+Paths can walk arrays with a `*` segment, e.g. "items.*.price" or "items[*].price",
+which yields an array of every value found. An optional aggregate (sum, avg, min, max, count)
+reduces that array to a single number before the comparator runs:
+	{
+		"comparator": "gte",
+		"path": "items[*].price",
+		"aggregate": "sum",
+		"value": 100
+	}
+
+A regex_extract rule with "extract_to" also puts its captures under that path
+for the rules tested after it, so a URL can be matched and picked apart in one go:
+	{
+		"comparator": "regex_extract",
+		"path": "url",
+		"value": "^/users/(?P<user>[0-9]+)/orders/(?P<order>[0-9]+)$",
+		"extract_to": "route"
+	},
+	{"comparator": "neq", "path": "route.user", "value": "0"}
+
+Paths starting with $ctx read the variables attached to the context the rules
+are tested with instead of the document, like $ctx.region, see ContextWithVars.
+
+Instead of a literal value, a rule can compare against another property
+of the same document with value_path:
+	{
+		"comparator": "lte",
+		"path": "discount",
+		"value_path": "price"
+	}
+
+Transforms clean up the property's value before it's compared, in order.
+The built in ones are trim, lower, upper, collapse_spaces, strip_diacritics,
+to_number, to_string, len and abs, and WithTransform adds more:
+	{
+		"comparator": "eq",
+		"path": "user.email",
+		"transforms": ["trim", "lower"],
+		"value": "jo@example.com"
+	}
+
+A path can also be wrapped in any of those functions,
+to compare something worked out from the property instead:
+	{
+		"comparator": "lte",
+		"path": "len(tags)",
+		"value": 5
+	}
+
+Setting "type" to "decimal" makes eq, neq, gt, gte, lt and lte compare
+the property and value as exact decimals, so amounts kept as strings
+like "19.99" don't pick up float64 rounding errors:
+	{
+		"comparator": "gte",
+		"path": "order.total",
+		"type": "decimal",
+		"value": "100.00"
+	}
+
+A value can also be a placeholder that is filled in by Ruler's TestWithParams function:
+	{
+		"comparator": "gte",
+		"path": "user.age",
+		"value": {"$param": "min_age"}
+	}
+
+Rules can also be grouped: a rule with "all" passes when every rule in it passes,
+a rule with "any" passes when at least one of them does, and a rule with "not"
+passes when the single rule in it doesn't. Groups can be nested:
+	{
+		"id": "north_america",
+		"any": [
+			{"comparator": "eq", "path": "country", "value": "US"},
+			{"comparator": "eq", "path": "country", "value": "CA"}
+		],
+		"outcome": "na-pool"
+	}
+
+A rule can have a message for people to read when a document fails it,
+in the Result from Evaluate (and any error), with {path}, {actual}, {expected}
+and {id} filled in:
+	{
+		"comparator": "gte",
+		"path": "order.total",
+		"value": 50,
+		"message": "Order total must be at least ${expected}, it's ${actual}"
+	}
+
+A rule or group can be limited to when it's active: from active_from (inclusive) until
+active_until, both RFC 3339 timestamps, and during the minutes that match schedule,
+a cron expression like "* 9-17 * * 1-5" (see WithClock for where the time comes from):
+	{
+		"comparator": "gte",
+		"path": "order.total",
+		"value": 50,
+		"active_from": "2024-11-29T00:00:00Z",
+		"active_until": "2024-12-03T00:00:00Z"
+	}
+Outside those times the rule is left out, as if it wasn't in the ruleset.
+
+The object form of rule JSON can declare definitions, named rules that
+any rule can be replaced with by a $ref, expanded when the rules are loaded:
+	{
+		"definitions": {
+			"adult": {"comparator": "gte", "path": "user.age", "value": 18}
+		},
+		"rules": [
+			{"any": [{"$ref": "#/definitions/adult"}, {"comparator": "eq", "path": "user.guardian", "value": true}]}
+		]
+	}
+
+A group can also be another ruleset, by its name in the Ruler's RulerSet
+(see WithRulerSet), passing when the document matches every rule in it:
+	{"ruleset": "is_premium_user"}
+
+A top-level rule with an outcome is also a decision for Ruler's Decide function,
+and a top-level rule's weight is what it adds to the score from Ruler's Score function
+when it passes (rules without a weight count as 1).
+A top-level rule's set is the facts Ruler's Infer function sets on the document
+when it passes, keyed by path, for later rules to build on:
+	{
+		"comparator": "gt",
+		"path": "order.total",
+		"value": 1000,
+		"set": {"risk_tier": "high"}
+	}
 
 This struct is exported here so that you can include it in your own JSON encoding/decoding,
 but go-ruler has a facility to help decode your rules from JSON into its own structs.
 */
 type Rule struct {
-	Comparator string      `json:"comparator"`
-	Path       string      `json:"path"`
-	Value      interface{} `json:"value"`
+	ID          string                 `json:"id,omitempty"`
+	Comparator  string                 `json:"comparator,omitempty"`
+	Path        string                 `json:"path,omitempty"`
+	Value       interface{}            `json:"value,omitempty"`
+	Aggregate   string                 `json:"aggregate,omitempty"`
+	ValuePath   string                 `json:"value_path,omitempty"`
+	Transforms  []string               `json:"transforms,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	All         []*Rule                `json:"all,omitempty"`
+	Any         []*Rule                `json:"any,omitempty"`
+	Not         *Rule                  `json:"not,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Outcome     interface{}            `json:"outcome,omitempty"`
+	Weight      float64                `json:"weight,omitempty"`
+	ActiveFrom  *time.Time             `json:"active_from,omitempty"`
+	ActiveUntil *time.Time             `json:"active_until,omitempty"`
+	Schedule    string                 `json:"schedule,omitempty"`
+	Set         map[string]interface{} `json:"set,omitempty"`
+	Ruleset     string                 `json:"ruleset,omitempty"`
+	ExtractTo   string                 `json:"extract_to,omitempty"`
+	// Ref is a $ref to one of the ruleset's definitions, which is only
+	// here until the rules are loaded and it's replaced with the definition
+	Ref string `json:"$ref,omitempty"`
+}
+
+// comparatorAliases are the operators that can be written in place of a comparator's name
+var comparatorAliases = map[string]string{
+	"==": "eq", "!=": "neq", ">": "gt", ">=": "gte", "<": "lt", "<=": "lte", "~=": "matches",
+}
+
+// UnmarshalJSON decodes a rule, turning comparator aliases like ">=" into their names
+func (f *Rule) UnmarshalJSON(data []byte) error {
+	// a type without this method, so decoding it doesn't come back here
+	type plainRule Rule
+	if err := json.Unmarshal(data, (*plainRule)(f)); err != nil {
+		return err
+	}
+
+	if name, ok := comparatorAliases[f.Comparator]; ok {
+		f.Comparator = name
+	}
+
+	return nil
+}
+
+// IsGroup reports whether the rule is a group of other rules
+// rather than a condition on a path, a reference to a ruleset counts as one
+func (f *Rule) IsGroup() bool {
+	return f.All != nil || f.Any != nil || f.Not != nil || f.Ruleset != ""
+}
+
+// PathValue can be passed to RulerRule's condition functions in place of a literal
+// to compare against another property of the same document, e.g.
+//	engine.Rule("discount").Lte(ruler.PathValue("price"))
+type PathValue string
+
+// Param returns a placeholder value that is filled in at Test time
+// from the params given to TestWithParams, e.g.
+//	engine.Rule("user.age").Gte(ruler.Param("min_age"))
+func Param(name string) map[string]interface{} {
+	return map[string]interface{}{"$param": name}
 }
 
 /*
@@ -70,11 +292,271 @@ func (rf *RulerRule) NotMatches(value interface{}) *RulerRule {
 	return rf.compare(ncontains, value)
 }
 
+// Regex adds a regex condition
+func (rf *RulerRule) Regex(value interface{}) *RulerRule {
+	return rf.compare(regex, value)
+}
+
+// Contains adds a contains (regex) condition
+func (rf *RulerRule) Contains(value interface{}) *RulerRule {
+	return rf.compare(contains, value)
+}
+
+// NotContains adds a not contains (!regex) condition
+func (rf *RulerRule) NotContains(value interface{}) *RulerRule {
+	return rf.compare(ncontains, value)
+}
+
+// Exists adds a condition that the property is on the document
+func (rf *RulerRule) Exists() *RulerRule {
+	return rf.compare(exists, nil)
+}
+
+// IsTrue adds a condition that the property is the boolean true
+func (rf *RulerRule) IsTrue() *RulerRule {
+	return rf.compare(isTrue, nil)
+}
+
+// IsFalse adds a condition that the property is the boolean false
+func (rf *RulerRule) IsFalse() *RulerRule {
+	return rf.compare(isFalse, nil)
+}
+
+// IsEmail adds a condition that the property is an email address
+func (rf *RulerRule) IsEmail() *RulerRule {
+	return rf.compare(isEmail, nil)
+}
+
+// IsURL adds a condition that the property is an absolute URL,
+// with one of the given schemes if there are any
+func (rf *RulerRule) IsURL(schemes ...string) *RulerRule {
+	if len(schemes) == 0 {
+		return rf.compare(isURL, nil)
+	}
+
+	return rf.compare(isURL, stringValues(schemes))
+}
+
+// IsUUID adds a condition that the property is a UUID string
+func (rf *RulerRule) IsUUID() *RulerRule {
+	return rf.compare(isUUID, nil)
+}
+
+// MimeType adds a condition that the property is a content type matching
+// one of the patterns, which can use wildcards like "image/*"
+func (rf *RulerRule) MimeType(patterns ...string) *RulerRule {
+	return rf.compare(mimeTypes, stringValues(patterns))
+}
+
+// Extension adds a condition that the property is a file name
+// ending in one of the extensions, ignoring case
+func (rf *RulerRule) Extension(exts ...string) *RulerRule {
+	return rf.compare(extension, stringValues(exts))
+}
+
+// BitandAny adds a condition that the property, as a bitfield,
+// has at least one of mask's bits set
+func (rf *RulerRule) BitandAny(mask interface{}) *RulerRule {
+	return rf.compare(bitandAny, mask)
+}
+
+// BitandAll adds a condition that the property, as a bitfield,
+// has every one of mask's bits set
+func (rf *RulerRule) BitandAll(mask interface{}) *RulerRule {
+	return rf.compare(bitandAll, mask)
+}
+
+// Mod adds a condition that the property, an integer, leaves remainder
+// when divided by divisor, e.g. Mod(10, 3) for every tenth id
+func (rf *RulerRule) Mod(divisor, remainder int64) *RulerRule {
+	return rf.compare(mod, map[string]interface{}{
+		"divisor":   divisor,
+		"remainder": remainder,
+	})
+}
+
+// Lookup adds a condition that the lookup registered as name,
+// see WithLookup, says something truthy about the property
+func (rf *RulerRule) Lookup(name string) *RulerRule {
+	return rf.compare(lookupCmp, name)
+}
+
+// InBusinessHours adds a condition that the property, a timestamp,
+// is within the hours registered as name, see WithBusinessHours
+func (rf *RulerRule) InBusinessHours(name string) *RulerRule {
+	return rf.compare(bizHours, name)
+}
+
+// Threshold adds a condition that the property's value has been seen
+// more than count times within the window, in the counter called counter,
+// e.g. Threshold("failed_logins", 5, 10*time.Minute) on an IP address
+func (rf *RulerRule) Threshold(counter string, count int64, within time.Duration) *RulerRule {
+	return rf.compare(thresholds, map[string]interface{}{
+		"counter": counter,
+		"count":   count,
+		"within":  within.String(),
+	})
+}
+
+// Extract adds a regex condition whose named groups end up in the Result's Captures
+func (rf *RulerRule) Extract(value interface{}) *RulerRule {
+	return rf.compare(extractCmp, value)
+}
+
+// Soundex adds a condition that the name sounds like this one by Soundex
+func (rf *RulerRule) Soundex(name string) *RulerRule {
+	return rf.compare(soundexes, name)
+}
+
+// Metaphone adds a condition that the name sounds like this one by Metaphone,
+// which knows more of English spelling than Soundex and keeps more of the name
+func (rf *RulerRule) Metaphone(name string) *RulerRule {
+	return rf.compare(metaphones, name)
+}
+
+// NotExists adds a condition that the property isn't on the document
+func (rf *RulerRule) NotExists() *RulerRule {
+	return rf.compare(nexists, nil)
+}
+
+// Between adds conditions that the property is at least lo and at most hi
+func (rf *RulerRule) Between(lo, hi interface{}) *RulerRule {
+	return rf.Gte(lo).Lte(hi)
+}
+
+// DeepEq adds a deep equals condition, matching whole objects or arrays
+func (rf *RulerRule) DeepEq(value interface{}) *RulerRule {
+	return rf.compare(deepEq, value)
+}
+
+// DeepNeq adds a deep not equals condition
+func (rf *RulerRule) DeepNeq(value interface{}) *RulerRule {
+	return rf.compare(deepNeq, value)
+}
+
+// Supermap adds a partial object condition: the property must be an object
+// containing at least the key/value pairs in value
+func (rf *RulerRule) Supermap(value map[string]interface{}) *RulerRule {
+	return rf.compare(supermap, value)
+}
+
+// Subset adds a condition that every element of the property is in value
+func (rf *RulerRule) Subset(value interface{}) *RulerRule {
+	return rf.compare(subset, value)
+}
+
+// Superset adds a condition that the property contains every element of value
+func (rf *RulerRule) Superset(value interface{}) *RulerRule {
+	return rf.compare(superset, value)
+}
+
+// Intersects adds a condition that the property shares at least one element with value
+func (rf *RulerRule) Intersects(value interface{}) *RulerRule {
+	return rf.compare(intersects, value)
+}
+
+// HasKey adds a condition that the property is an object with the key in value,
+// which can be a single key or an array of keys that must all be present
+func (rf *RulerRule) HasKey(value interface{}) *RulerRule {
+	return rf.compare(haskey, value)
+}
+
+// Percent adds a rollout condition: the property (e.g. a user id) is hashed with salt
+// and passes for a stable pct percent of all values
+func (rf *RulerRule) Percent(pct float64, salt string) *RulerRule {
+	return rf.compare(percent, map[string]interface{}{
+		"percent": pct,
+		"salt":    salt,
+	})
+}
+
+// ApproxEq adds a condition that the property is a number within
+// the Ruler's epsilon (see WithEpsilon) of value
+func (rf *RulerRule) ApproxEq(value interface{}) *RulerRule {
+	return rf.compare(approxEq, value)
+}
+
+// ApproxEqWithin adds a condition that the property is a number within epsilon of value
+func (rf *RulerRule) ApproxEqWithin(value, epsilon float64) *RulerRule {
+	return rf.compare(approxEq, map[string]interface{}{
+		"value":   value,
+		"epsilon": epsilon,
+	})
+}
+
+// Transform runs the property's value through the named transforms before comparing it
+func (rf *RulerRule) Transform(names ...string) *RulerRule {
+	rf.Transforms = append(rf.Transforms, names...)
+	return rf
+}
+
+// Decimal compares the property and value as exact decimals instead of floats,
+// for money and anything else kept as strings like "19.99"
+func (rf *RulerRule) Decimal() *RulerRule {
+	rf.Type = "decimal"
+	return rf
+}
+
+// Sum compares the sum of the property's array of numbers
+func (rf *RulerRule) Sum() *RulerRule {
+	return rf.aggregate("sum")
+}
+
+// Avg compares the average of the property's array of numbers
+func (rf *RulerRule) Avg() *RulerRule {
+	return rf.aggregate("avg")
+}
+
+// Min compares the smallest of the property's array of numbers
+func (rf *RulerRule) Min() *RulerRule {
+	return rf.aggregate("min")
+}
+
+// Max compares the largest of the property's array of numbers
+func (rf *RulerRule) Max() *RulerRule {
+	return rf.aggregate("max")
+}
+
+// Count compares the number of elements in the property's array
+func (rf *RulerRule) Count() *RulerRule {
+	return rf.aggregate("count")
+}
+
+// ID sets the current rule's ID, so it can be found later
+// by Ruler's RemoveRule and ReplaceRule functions
+func (rf *RulerRule) ID(id string) *RulerRule {
+	rf.Rule.ID = id
+	return rf
+}
+
+// Message sets the message a Result carries when a document fails the current rule
+// {path}, {actual}, {expected} and {id} are filled in from the rule and document
+func (rf *RulerRule) Message(tmpl string) *RulerRule {
+	rf.Rule.Message = tmpl
+	return rf
+}
+
+// Weight sets how much the current rule adds to Ruler's Score when it passes
+func (rf *RulerRule) Weight(w float64) *RulerRule {
+	rf.Rule.Weight = w
+	return rf
+}
+
 // End Stops chaining for the current rule, allowing you to add rules for other properties
 func (rf *RulerRule) End() *Ruler {
 	return rf.Ruler
 }
 
+// stringValues makes the []interface{} a rule value would be if it came from JSON
+func stringValues(strs []string) []interface{} {
+	values := make([]interface{}, len(strs))
+	for i, s := range strs {
+		values[i] = s
+	}
+
+	return values
+}
+
 // comparator will either create a new ruler filter and add its filter
 func (rf *RulerRule) compare(comp int, value interface{}) *RulerRule {
 	var comparator string
@@ -91,12 +573,68 @@ func (rf *RulerRule) compare(comp int, value interface{}) *RulerRule {
 		comparator = "gt"
 	case gte:
 		comparator = "gte"
+	case exists:
+		comparator = "exists"
+	case nexists:
+		comparator = "nexists"
+	case regex:
+		comparator = "regex"
 	case contains:
 		comparator = "contains"
 	case matches:
 		comparator = "matches"
 	case ncontains:
 		comparator = "ncontains"
+	case deepEq:
+		comparator = "deep_eq"
+	case deepNeq:
+		comparator = "deep_neq"
+	case supermap:
+		comparator = "supermap"
+	case subset:
+		comparator = "subset"
+	case superset:
+		comparator = "superset"
+	case intersects:
+		comparator = "intersects"
+	case haskey:
+		comparator = "haskey"
+	case percent:
+		comparator = "percent"
+	case approxEq:
+		comparator = "approx_eq"
+	case isTrue:
+		comparator = "istrue"
+	case isFalse:
+		comparator = "isfalse"
+	case isEmail:
+		comparator = "is_email"
+	case isURL:
+		comparator = "is_url"
+	case isUUID:
+		comparator = "is_uuid"
+	case mimeTypes:
+		comparator = "mime_type"
+	case extension:
+		comparator = "extension"
+	case bitandAny:
+		comparator = "bitand_any"
+	case bitandAll:
+		comparator = "bitand_all"
+	case mod:
+		comparator = "mod"
+	case lookupCmp:
+		comparator = "lookup"
+	case bizHours:
+		comparator = "business_hours"
+	case thresholds:
+		comparator = "threshold"
+	case extractCmp:
+		comparator = "regex_extract"
+	case soundexes:
+		comparator = "soundex"
+	case metaphones:
+		comparator = "metaphone"
 	}
 
 	// if this thing has a comparator already, we need to make a new ruler filter
@@ -104,9 +642,11 @@ func (rf *RulerRule) compare(comp int, value interface{}) *RulerRule {
 		rf = &RulerRule{
 			rf.Ruler,
 			&Rule{
-				comparator,
-				rf.Path,
-				value,
+				Comparator: comparator,
+				Path:       rf.Path,
+				Aggregate:  rf.Aggregate,
+				Transforms: rf.Transforms,
+				Type:       rf.Type,
 			},
 		}
 		// attach the new filter to the ruler
@@ -114,8 +654,20 @@ func (rf *RulerRule) compare(comp int, value interface{}) *RulerRule {
 	} else {
 		//if there is no comparator, we can just set things on the current filter
 		rf.Comparator = comparator
+	}
+
+	if p, ok := value.(PathValue); ok {
+		rf.ValuePath = string(p)
+	} else {
 		rf.Value = value
 	}
 
 	return rf
 }
+
+// aggregate sets the aggregate for the current rule
+// conditions added after this will compare the aggregated value
+func (rf *RulerRule) aggregate(fn string) *RulerRule {
+	rf.Aggregate = fn
+	return rf
+}
@@ -1,62 +1,173 @@
 package ruler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // we'll use these values
 // to avoid passing strings to our
 // special comparison func for these comparators
 const (
-	eq        = iota
-	neq       = iota
-	gt        = iota
-	gte       = iota
-	lt        = iota
-	lte       = iota
-	exists    = iota
-	nexists   = iota
-	regex     = iota
-	matches   = iota
-	contains  = iota
-	ncontains = iota
+	eq         = iota
+	neq        = iota
+	gt         = iota
+	gte        = iota
+	lt         = iota
+	lte        = iota
+	exists     = iota
+	nexists    = iota
+	regex      = iota
+	matches    = iota
+	contains   = iota
+	ncontains  = iota
+	deepEq     = iota
+	deepNeq    = iota
+	supermap   = iota
+	subset     = iota
+	superset   = iota
+	intersects = iota
+	haskey     = iota
+	percent    = iota
+	approxEq   = iota
+	isTrue     = iota
+	isFalse    = iota
+	isEmail    = iota
+	isURL      = iota
+	isUUID     = iota
+	mimeTypes  = iota
+	extension  = iota
+	bitandAny  = iota
+	bitandAll  = iota
+	mod        = iota
+	lookupCmp  = iota
+	bizHours   = iota
+	thresholds = iota
+	extractCmp = iota
+	soundexes  = iota
+	metaphones = iota
+)
+
+// Tester is anything that can test a document against rules,
+// which both Ruler and CompiledRuler can
+// depend on this instead of *Ruler if you want to swap in a fake in tests
+type Tester interface {
+	Test(o map[string]interface{}) (bool, error)
+}
+
+var (
+	_ Tester = (*Ruler)(nil)
+	_ Tester = (*CompiledRuler)(nil)
 )
 
 // Ruler holds an array of Rules
 type Ruler struct {
-	rules []*Rule
+	rules   []*Rule
+	version string
+	limits  Limits
+
+	decisionMode   DecisionMode
+	defaultOutcome interface{}
+
+	epsilon        float64
+	nonFinite      NonFinitePolicy
+	numericStrings bool
+	collation      *collation
+	normalize      *norm.Form
+	transforms     map[string]Transform
+	boolStrings    bool
+	decimalCompare DecimalCompare
+	regexEngine    RegexEngine
+	catalog        MessageCatalog
+	secrets        SecretResolver
+	lookups        map[string]*lookup
+	hours          map[string]*BusinessHours
+	calendars      map[string]HolidayCalendar
+	state          StateStore
+	rulerSet       *RulerSet
+
+	instrumentation Instrumentation
+	audit           AuditSink
+	coverage        *Coverage
+	clock           func() time.Time
+
+	strict    bool
+	flattened bool
+	// lazyRegexps puts off compiling regexes until they're used,
+	// for rules loaded with LoadCompiled
+	lazyRegexps bool
+}
+
+// An Option configures a Ruler when it's created
+type Option func(*Ruler)
+
+// WithNumericStrings makes gt, gte, lt and lte compare strings as numbers,
+// so "10" is bigger than "9", instead of ordering them byte by byte
+// strings that aren't numbers are an error
+func WithNumericStrings() Option {
+	return func(r *Ruler) {
+		r.numericStrings = true
+	}
+}
+
+// WithFlattened is for documents that have already been flattened, keyed by dotted
+// paths like {"user.address.city": "Boston"} the way a lot of log pipelines write them
+// a path is looked up as a key first, then its longest prefix that is one,
+// before falling back to walking the document, so half-flattened documents work too
+func WithFlattened() Option {
+	return func(r *Ruler) {
+		r.flattened = true
+	}
 }
 
 // NewRuler creates a new Ruler for you
 // optionally accepts a pointer to a slice of filters
 // if you have filters that you want to start with
-func NewRuler(rules []*Rule) *Ruler {
-	if rules != nil {
-		return &Ruler{
-			rules,
-		}
+func NewRuler(rules []*Rule, opts ...Option) *Ruler {
+	r := &Ruler{
+		// copied so adding rules later can't write into the caller's slice
+		rules: append([]*Rule(nil), rules...),
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
 
-	return &Ruler{}
+	return r
 }
 
 // NewRulerWithJSON returns a new ruler with filters parsed from JSON data
 // expects JSON as a slice of bytes and will parse your JSON for you!
-func NewRulerWithJSON(jsonstr []byte) (*Ruler, error) {
-	var rules []*Rule
+// that's an array of rules, or an object with the rules and their version:
+// {"version": "...", "rules": [...]}
+// see WithStrict for checking the rules more carefully as they're loaded
+func NewRulerWithJSON(jsonstr []byte, opts ...Option) (*Ruler, error) {
+	r := NewRuler(nil, opts...)
 
-	err := json.Unmarshal(jsonstr, &rules)
+	var err error
+	if r.strict {
+		err = r.parseStrict(jsonstr)
+	} else {
+		err = r.parseRules(jsonstr)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return NewRuler(rules), nil
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
 // Rule adds a new rule for the property at `path`
@@ -64,9 +175,7 @@ func NewRulerWithJSON(jsonstr []byte) (*Ruler, error) {
 // and more filters
 func (r *Ruler) Rule(path string) *RulerRule {
 	rule := &Rule{
-		"",
-		path,
-		nil,
+		Path: path,
 	}
 
 	r.rules = append(r.rules, rule)
@@ -81,49 +190,446 @@ func (r *Ruler) Rule(path string) *RulerRule {
 // given a map that looks like a JSON object
 // (map[string]interface{})
 func (r *Ruler) Test(o map[string]interface{}) (bool, error) {
-	for _, f := range r.rules {
-		val := pluck(o, f.Path)
+	return r.TestWithParams(o, nil)
+}
+
+// TestWithParams works like Test, but fills in any `{"$param": "name"}`
+// placeholder values in the rules from params first, so one set of rules
+// can be evaluated with different thresholds
+func (r *Ruler) TestWithParams(o map[string]interface{}, params map[string]interface{}) (bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return false, err
+	}
+
+	return c.TestWithParams(o, params)
+}
+
+// TestContext works like Test, but stops and returns the context's error
+// if it is cancelled or its deadline passes before all the rules are tested
+func (r *Ruler) TestContext(ctx context.Context, o map[string]interface{}) (bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return false, err
+	}
+
+	return c.TestContext(ctx, o)
+}
+
+// Evaluate tests the rules against a document like Test does,
+// but tells you which rule failed
+func (r *Ruler) Evaluate(o map[string]interface{}) *Result {
+	c, err := r.Compile()
+	if err != nil {
+		return &Result{Err: err}
+	}
+
+	return c.Evaluate(o)
+}
 
-		if val != nil {
-			// both the actual and expected value must be comparable
-			a := reflect.TypeOf(val)
-			e := reflect.TypeOf(f.Value)
+// EvaluateContext is Evaluate with a context, like TestContext
+func (r *Ruler) EvaluateContext(ctx context.Context, o map[string]interface{}) *Result {
+	c, err := r.Compile()
+	if err != nil {
+		return &Result{Err: err}
+	}
+
+	return c.EvaluateContext(ctx, o)
+}
+
+// TestAll tests many documents against the rules, compiling them only once
+// the bools line up with docs; the first error stops the whole batch
+func (r *Ruler) TestAll(docs []map[string]interface{}) ([]bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.TestAll(docs)
+}
+
+// TestAllParallel works like TestAll, but spreads the documents across
+// a number of goroutines; results stay in the same order as docs
+// the first error seen (or cancelling ctx) stops the whole batch
+func (r *Ruler) TestAllParallel(ctx context.Context, docs []map[string]interface{}, workers int) ([]bool, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.TestAllParallel(ctx, docs, workers)
+}
+
+// Filter reads documents from in and passes along only the ones that match
+// out is unbuffered, so a slow reader slows down the filter too
+// both channels are closed when in is closed, ctx is cancelled or a document
+// can't be tested; in the last two cases the error is sent on the error channel first
+func (r *Ruler) Filter(ctx context.Context, in <-chan map[string]interface{}) (<-chan map[string]interface{}, <-chan error) {
+	c, err := r.Compile()
+	if err != nil {
+		out := make(chan map[string]interface{})
+		errs := make(chan error, 1)
+		errs <- err
+		close(out)
+		close(errs)
+		return out, errs
+	}
+
+	return c.Filter(ctx, in)
+}
+
+// EvaluateAll is TestAll with a Result for every document,
+// so one bad document doesn't stop the rest from being tested
+func (r *Ruler) EvaluateAll(docs []map[string]interface{}) ([]*Result, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.EvaluateAll(docs), nil
+}
+
+// evaluation holds everything needed while testing one document
+type evaluation struct {
+	ctx    context.Context
+	doc    map[string]interface{}
+	params map[string]interface{}
+	nodes  nodes
+	// resolved holds every path (and path prefix) plucked so far
+	resolved map[string]interface{}
+	// locale picks failure messages from the Ruler's MessageCatalog
+	locale string
+	// root is the Go value being tested by an Evaluator, in place of doc
+	root interface{}
+	// flat looks paths up as keys of doc before walking it, see WithFlattened
+	flat bool
+	// lookups remembers what each lookup said about each key
+	lookups map[lookupKey]lookupResult
+	// clock is the Ruler's clock, and at the time it gave for this evaluation
+	clock func() time.Time
+	at    time.Time
+	// scans are what each regexSet last found, see mayMatch
+	scans []regexScan
+	// captures are the named groups regex_extract rules have found,
+	// and extracted the ones under each extract_to path
+	captures  map[string]string
+	extracted map[string]map[string]interface{}
+}
+
+// maxPooledPaths is the most resolved paths an evaluation can
+// remember and still go back in the pool with its map
+const maxPooledPaths = 1024
+
+// evaluations are reused from one document to the next,
+// so testing lots of them doesn't make garbage
+var evaluations = sync.Pool{
+	New: func() interface{} { return new(evaluation) },
+}
+
+// newEvaluation sets up the state for testing one document,
+// hand it back with release once the answer's been worked out
+func (r *Ruler) newEvaluation(ctx context.Context, o, params map[string]interface{}) *evaluation {
+	e := evaluations.Get().(*evaluation)
+	e.ctx = ctx
+	e.doc = o
+	e.params = params
+	e.nodes = nodes{max: r.limits.MaxDocumentNodes}
+	e.flat = r.flattened
+	e.clock = r.clock
+
+	return e
+}
+
+// release returns e to the pool, keeping its resolved map
+// (emptied) so the next evaluation doesn't have to make one
+// unless it grew big enough to be worth letting go of
+func (e *evaluation) release() {
+	resolved := e.resolved
+	if len(resolved) > maxPooledPaths {
+		resolved = nil
+	}
+	for k := range resolved {
+		delete(resolved, k)
+	}
+
+	scans := e.scans
+	for i := range scans {
+		scans[i].set, scans[i].input = nil, ""
+	}
+
+	*e = evaluation{resolved: resolved, scans: scans}
+	evaluations.Put(e)
+}
 
-			if !a.Comparable() || !e.Comparable() {
-				return false, nil
+// testRule tests a single rule or group of rules against the document in e
+func (r *Ruler) testRule(e *evaluation, f *compiledRule) (bool, error) {
+	if !f.IsGroup() {
+		val, err := e.pluck(f.path)
+		if err != nil {
+			f.cov.record(false, err)
+			return false, err
+		}
+
+		return r.testValue(e, f, val)
+	}
+
+	ok, err := r.testGroup(e, f)
+	f.cov.record(ok, err)
+	return ok, err
+}
+
+// testGroup tests the rules in an all, any or not group
+func (r *Ruler) testGroup(e *evaluation, f *compiledRule) (bool, error) {
+	if f.all != nil {
+		for _, g := range f.all {
+			if !g.active(e) {
+				continue
+			}
+			ok, err := r.testRule(e, g)
+			if err != nil || !ok {
+				return false, err
 			}
+		}
+		return true, nil
+	}
 
-			result, err := r.compare(f, val)
+	if f.any != nil {
+		for _, g := range f.any {
+			if !g.active(e) {
+				continue
+			}
+			ok, err := r.testRule(e, g)
 			if err != nil {
 				return false, err
 			}
-			if !result {
-				return false, nil
+			if ok {
+				return true, nil
 			}
-		} else if val == nil && (f.Comparator == "exists" || f.Comparator == "nexists") {
-			// either one of these can be done
-			return r.compare(f, val)
-		} else {
-			// if we couldn't find the value on the map
-			// and the comparator isn't exists/nexists, this fails
-			return false, fmt.Errorf("did not find property (%s) on map", f.Path)
 		}
+		return false, nil
+	}
 
+	if f.ref != nil {
+		// the ruleset's own options apply to its rules
+		re := f.ref.ruler.newEvaluation(e.ctx, e.doc, e.params)
+		defer re.release()
+		// but it's testing the same thing, Go values and what's been extracted included
+		re.root = e.root
+		re.extracted = e.extracted
+		for path, m := range e.extracted {
+			re.remember(path, m)
+		}
+		return f.ref.test(re)
 	}
 
-	return true, nil
+	if !f.not.active(e) {
+		// there's nothing to negate, like an empty all
+		return true, nil
+	}
+	ok, err := r.testRule(e, f.not)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// testValue tests a single rule against the value already plucked from its path
+func (r *Ruler) testValue(e *evaluation, f *compiledRule, val interface{}) (bool, error) {
+	ok, err := r.testPlucked(e, f, val)
+	f.cov.record(ok, err)
+	return ok, err
+}
+
+func (r *Ruler) testPlucked(e *evaluation, f *compiledRule, val interface{}) (bool, error) {
+	val, err := applyTransforms(f, val)
+	if err != nil {
+		return false, err
+	}
+
+	if val != nil && f.Aggregate != "" {
+		if val, err = aggregate(f.Aggregate, val); err != nil {
+			return false, err
+		}
+	}
+
+	expected, err := r.expectedValue(e, f)
+	if err != nil {
+		return false, err
+	}
+
+	if val == nil && f.Comparator != "exists" && f.Comparator != "nexists" {
+		// if we couldn't find the value on the map
+		// and the comparator isn't exists/nexists, this fails
+		return false, fmt.Errorf("did not find property (%s) on map", f.Path)
+	}
+
+	return r.compare(e, f, val, expected)
+}
+
+// expectedValue figures out what a rule compares against:
+// another property of the document, a parameter, a secret, or just its literal value
+func (r *Ruler) expectedValue(e *evaluation, f *compiledRule) (interface{}, error) {
+	if f.valuePath != nil {
+		// compare against another property instead of a literal
+		expected, err := e.pluck(f.valuePath)
+		if err != nil {
+			return nil, err
+		}
+		if expected == nil {
+			return nil, fmt.Errorf("did not find property (%s) on map", f.ValuePath)
+		}
+		return expected, nil
+	}
+
+	if name, ok := paramName(f.Value); ok {
+		expected, ok := e.params[name]
+		if !ok {
+			return nil, fmt.Errorf("no value given for parameter (%s)", name)
+		}
+		return expected, nil
+	}
+
+	if name, ok := secretName(f.Value); ok {
+		return r.secret(e.ctx, name)
+	}
+
+	return f.Value, nil
+}
+
+// paramName returns the parameter name if v is a `{"$param": "name"}` placeholder
+func paramName(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+
+	name, ok := m["$param"].(string)
+	return name, ok
+}
+
+// knownComparators are all the comparators compare understands
+var knownComparators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"exists": true, "nexists": true, "regex": true, "matches": true, "contains": true, "ncontains": true,
+	"deep_eq": true, "deep_neq": true, "supermap": true, "subset": true, "superset": true,
+	"intersects": true, "haskey": true, "percent": true, "approx_eq": true,
+	"istrue": true, "isfalse": true, "is_email": true, "is_url": true, "is_uuid": true,
+	"mime_type": true, "extension": true, "bitand_any": true, "bitand_all": true,
+	"mod": true, "lookup": true, "business_hours": true,
+	"threshold": true, "regex_extract": true, "soundex": true, "metaphone": true,
 }
 
 // compares real v. actual values
-func (r *Ruler) compare(f *Rule, actual interface{}) (bool, error) {
-	expected := f.Value
+// e is passed along so comparators that need to do work outside the document
+// can honor the evaluation's context
+func (r *Ruler) compare(e *evaluation, f *compiledRule, actual, expected interface{}) (bool, error) {
+	if result, decided, err := r.checkNonFinite(f, actual, expected); decided {
+		return result, err
+	}
+	if result, decided, err := r.compareDecimal(f, actual, expected); decided {
+		return result, err
+	}
+	if result, decided, err := r.compareNative(f, actual, expected); decided {
+		return result, err
+	}
+	actual, expected = stringerValue(actual), stringerValue(expected)
+	actual, expected = r.normalizeStrings(f, actual, expected)
+	if result, decided := r.compareBools(f, actual, expected); decided {
+		return result, nil
+	}
+
 	switch f.Comparator {
 	case "eq":
+		if equal, ok := r.collatedEqual(actual, expected); ok {
+			return equal, nil
+		}
+		// both the actual and expected value must be comparable
+		if !isComparable(actual, expected) {
+			return false, nil
+		}
 		return actual == expected, nil
 
 	case "neq":
+		if equal, ok := r.collatedEqual(actual, expected); ok {
+			return !equal, nil
+		}
+		if !isComparable(actual, expected) {
+			return false, nil
+		}
 		return actual != expected, nil
 
+	case "deep_eq":
+		return deepEqual(actual, expected), nil
+
+	case "deep_neq":
+		return !deepEqual(actual, expected), nil
+
+	case "supermap":
+		return r.supermap(actual, expected)
+
+	case "subset":
+		return r.sets(subset, actual, expected)
+
+	case "superset":
+		return r.sets(superset, actual, expected)
+
+	case "intersects":
+		return r.sets(intersects, actual, expected)
+
+	case "haskey":
+		return r.haskey(actual, expected)
+
+	case "percent":
+		return inRollout(actual, expected)
+
+	case "approx_eq":
+		return r.approxEqual(actual, expected)
+
+	case "istrue":
+		return r.isBool(actual, true), nil
+
+	case "isfalse":
+		return r.isBool(actual, false), nil
+
+	case "is_email":
+		return validEmail(actual), nil
+
+	case "is_url":
+		return validURL(actual, expected)
+
+	case "is_uuid":
+		return validUUID(actual), nil
+
+	case "soundex":
+		return soundsLike(actual, expected, soundex)
+
+	case "metaphone":
+		return soundsLike(actual, expected, metaphone)
+
+	case "mime_type":
+		return mimeType(actual, expected)
+
+	case "extension":
+		return hasExtension(actual, expected)
+
+	case "bitand_any":
+		return bitand(false, actual, expected)
+
+	case "bitand_all":
+		return bitand(true, actual, expected)
+
+	case "mod":
+		return modulo(actual, expected)
+
+	case "lookup":
+		return r.lookup(e, f, actual, expected)
+
+	case "business_hours":
+		return r.inBusinessHours(f, actual, expected)
+
+	case "threshold":
+		return r.threshold(e, f, actual, expected)
+
 	case "gt":
 		return r.inequality(gt, actual, expected)
 
@@ -153,10 +659,12 @@ func (r *Ruler) compare(f *Rule, actual interface{}) (bool, error) {
 	case "contains":
 		fallthrough
 	case "matches":
-		return r.regexp(actual, expected)
+		fallthrough
+	case "regex_extract":
+		return r.regexp(e, f, actual, expected)
 
 	case "ncontains":
-		result, err := r.regexp(actual, expected)
+		result, err := r.regexp(e, f, actual, expected)
 		if err != nil {
 			return false, err
 		}
@@ -166,7 +674,7 @@ func (r *Ruler) compare(f *Rule, actual interface{}) (bool, error) {
 		//should probably return an error or something
 		//but this is good for now
 		//if comparator is not implemented, return false
-		return false, errors.New("unknown comparator %s")
+		return false, fmt.Errorf("unknown comparator %s", f.Comparator)
 	}
 }
 
@@ -177,190 +685,508 @@ func (r *Ruler) compare(f *Rule, actual interface{}) (bool, error) {
 func (r *Ruler) inequality(op int, actual, expected interface{}) (bool, error) {
 
 	if reflect.TypeOf(actual) != reflect.TypeOf(expected) {
-		return false, errors.New("Value types are mismatched, cannot compare values")
-	}
-
-	t := reflect.TypeOf(actual).String()
-	switch t {
-	case "uint8":
-		return compareUint(op, actual, expected), nil
-	case "uint16":
-		return compareUint(op, actual, expected), nil
-	case "uint32":
-		return compareUint(op, actual, expected), nil
-	case "uint64":
-		return compareUint(op, actual, expected), nil
-	case "uint":
-		return compareUint(op, actual, expected), nil
-	case "int8":
-		return compareInt(op, actual, expected), nil
-	case "int16":
-		return compareInt(op, actual, expected), nil
-	case "int32":
-		return compareInt(op, actual, expected), nil
-	case "int64":
-		return compareInt(op, actual, expected), nil
-	case "int":
-		return compareInt(op, actual, expected), nil
-	case "float32":
-		return compareFloat(op, actual, expected), nil
-	case "float64":
-		return compareFloat(op, actual, expected), nil
-	case "string":
-		return compareStr(op, actual, expected), nil
+		return false, errMismatched
+	}
+
+	// a type switch rather than reflection, so the values
+	// don't get looked at (or boxed again) more than once
+	switch a := actual.(type) {
+	case float64:
+		return compareFloat(op, a, expected.(float64)), nil
+	case string:
+		return r.compareStr(op, a, expected.(string))
+	case int:
+		return compareInt(op, int64(a), int64(expected.(int))), nil
+	case int8:
+		return compareInt(op, int64(a), int64(expected.(int8))), nil
+	case int16:
+		return compareInt(op, int64(a), int64(expected.(int16))), nil
+	case int32:
+		return compareInt(op, int64(a), int64(expected.(int32))), nil
+	case int64:
+		return compareInt(op, a, expected.(int64)), nil
+	case uint:
+		return compareUint(op, uint64(a), uint64(expected.(uint))), nil
+	case uint8:
+		return compareUint(op, uint64(a), uint64(expected.(uint8))), nil
+	case uint16:
+		return compareUint(op, uint64(a), uint64(expected.(uint16))), nil
+	case uint32:
+		return compareUint(op, uint64(a), uint64(expected.(uint32))), nil
+	case uint64:
+		return compareUint(op, a, expected.(uint64)), nil
+	case float32:
+		return compareFloat(op, float64(a), float64(expected.(float32))), nil
 	default:
-		return false, errors.New("Invalid type for inequality comparison")
+		return false, errInvalidInequality
 	}
 
 }
 
-func (r *Ruler) regexp(actual, expected interface{}) (bool, error) {
+// the errors every failed comparison would otherwise allocate
+var (
+	errMismatched        = errors.New("Value types are mismatched, cannot compare values")
+	errInvalidInequality = errors.New("Invalid type for inequality comparison")
+)
+
+// regexp matches actual against the rule's regex, which was compiled ahead of time
+// unless it comes from a parameter or another property
+func (r *Ruler) regexp(e *evaluation, f *compiledRule, actual, expected interface{}) (bool, error) {
 	// regexps must be strings
-	var streg string
-	var ok bool
-	if streg, ok = expected.(string); !ok {
-		return false, errors.New("expected value not actually a string, bailing")
+	reg := f.re
+	if reg == nil && f.lazy != nil {
+		var err error
+		if reg, err = f.lazy.get(r); err != nil {
+			return false, err
+		}
+	}
+	if reg == nil {
+		streg, ok := expected.(string)
+		if !ok {
+			return false, errors.New("expected value not actually a string, bailing")
+		}
+
+		var err error
+		if reg, err = r.compileRegexp(streg); err != nil {
+			return false, err
+		}
 	}
 
-	var astring string
-	if astring, ok = actual.(string); !ok {
+	astring, ok := actual.(string)
+	if !ok {
 		return false, errors.New("actual value not actually a string, bailing")
 	}
 
-	reg, err := regexp.Compile(streg)
+	if max := r.limits.MaxRegexInput; max > 0 && len(astring) > max {
+		return false, &LimitError{"MaxRegexInput", max}
+	}
+
+	if f.set != nil && !e.mayMatch(f, astring) {
+		return false, nil
+	}
+	if f.Comparator == "regex_extract" {
+		return r.extract(e, f, reg, astring)
+	}
+
+	return r.match(reg, astring)
+}
+
+// compileRegexp compiles a rule's regex with the Ruler's RegexEngine,
+// as long as it's within the MaxRegexLength limit
+func (r *Ruler) compileRegexp(streg string) (Matcher, error) {
+	if max := r.limits.MaxRegexLength; max > 0 && len(streg) > max {
+		return nil, &LimitError{"MaxRegexLength", max}
+	}
+
+	engine := r.regexEngine
+	if engine == nil {
+		engine = StdRegexp
+	}
+	reg, err := engine.Compile(streg)
+	if err != nil {
+		return nil, errors.New("regexp is bad, bailing")
+	}
+
+	return reg, nil
+}
+
+// match runs the regexp, giving up after the RegexTimeout limit if there is one
+func (r *Ruler) match(reg Matcher, s string) (bool, error) {
+	timeout := r.limits.RegexTimeout
+	if timeout <= 0 {
+		return reg.MatchString(s), nil
+	}
+
+	// buffered so the goroutine can finish and go away
+	// even if nobody is waiting for it anymore
+	done := make(chan bool, 1)
+	go func() {
+		done <- reg.MatchString(s)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case matched := <-done:
+		return matched, nil
+	case <-timer.C:
+		return false, &LimitError{"RegexTimeout", timeout}
+	}
+}
+
+// isComparable reports whether both values can be used with == and !=
+// a nil value is always comparable
+func isComparable(actual, expected interface{}) bool {
+	a := reflect.TypeOf(actual)
+	e := reflect.TypeOf(expected)
+
+	return (a == nil || a.Comparable()) && (e == nil || e.Comparable())
+}
+
+// deepEqual compares two values structurally, so maps and slices
+// can be matched as a whole
+// if the values aren't identical in Go terms, we compare
+// their JSON representations, so a []string from Go code
+// still matches a []interface{} decoded from rule JSON
+func deepEqual(actual, expected interface{}) bool {
+	if reflect.DeepEqual(actual, expected) {
+		return true
+	}
+
+	a, err := normalize(actual)
+	if err != nil {
+		return false
+	}
+	e, err := normalize(expected)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, e)
+}
+
+// normalize round-trips a value through JSON so that it is made up
+// of the same types encoding/json produces when decoding
+func normalize(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var n interface{}
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// supermap checks that actual is an object holding at least
+// the key/value pairs in expected, recursing into nested objects
+func (r *Ruler) supermap(actual, expected interface{}) (bool, error) {
+	e, ok := asMap(expected)
+	if !ok {
+		return false, errors.New("expected value not actually an object, bailing")
+	}
+
+	a, ok := asMap(actual)
+	if !ok {
+		return false, nil
+	}
+
+	return containsMap(a, e), nil
+}
+
+func containsMap(actual, expected map[string]interface{}) bool {
+	for k, ev := range expected {
+		av, ok := actual[k]
+		if !ok {
+			return false
+		}
+
+		if em, ok := asMap(ev); ok {
+			am, ok := asMap(av)
+			if !ok || !containsMap(am, em) {
+				return false
+			}
+			continue
+		}
+
+		if !deepEqual(av, ev) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// asMap returns v as a map[string]interface{}, normalizing other
+// map types (e.g. map[string]string built in Go) along the way
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Map {
+		return nil, false
+	}
+
+	n, err := normalize(v)
 	if err != nil {
-		return false, errors.New("regexp is bad, bailing")
+		return nil, false
+	}
+
+	m, ok := n.(map[string]interface{})
+	return m, ok
+}
+
+// sets runs the array comparators; elements are matched with deepEqual
+// so they can be numbers, strings or whole objects
+func (r *Ruler) sets(op int, actual, expected interface{}) (bool, error) {
+	e, ok := asSlice(expected)
+	if !ok {
+		return false, errors.New("expected value not actually an array, bailing")
+	}
+
+	a, ok := asSlice(actual)
+	if !ok {
+		return false, errors.New("actual value not actually an array, bailing")
+	}
+
+	switch op {
+	case subset:
+		// every element of actual shows up in expected
+		return containsAll(e, a), nil
+	case superset:
+		// every element of expected shows up in actual
+		return containsAll(a, e), nil
+	case intersects:
+		for _, v := range e {
+			if containsValue(a, v) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// haskey checks that actual is an object with the key in expected,
+// or with every key when expected is an array of keys
+func (r *Ruler) haskey(actual, expected interface{}) (bool, error) {
+	var keys []interface{}
+	if k, ok := expected.(string); ok {
+		keys = []interface{}{k}
+	} else if keys, ok = asSlice(expected); !ok {
+		return false, errors.New("expected value not actually a key or array of keys, bailing")
+	}
+
+	a, ok := asMap(actual)
+	if !ok {
+		return false, nil
+	}
+
+	for _, k := range keys {
+		ks, ok := k.(string)
+		if !ok {
+			return false, errors.New("expected key not actually a string, bailing")
+		}
+		if _, ok := a[ks]; !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func containsAll(haystack, needles []interface{}) bool {
+	for _, v := range needles {
+		if !containsValue(haystack, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsValue(haystack []interface{}, needle interface{}) bool {
+	for _, v := range haystack {
+		if deepEqual(v, needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// asSlice returns v as a []interface{}, converting other
+// slice and array types along the way
+func asSlice(v interface{}) ([]interface{}, bool) {
+	if s, ok := v.([]interface{}); ok {
+		return s, true
+	}
+
+	if v == nil {
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
 	}
 
-	return reg.MatchString(astring), nil
+	return s, true
 }
 
 // given a map, pull a property from it at some deeply nested depth
 // this re-implements (most of) JS `pluck` in go: https://github.com/gjohnson/pluck
+// a `*` segment (or `name[*]`) walks every element of an array
+// and yields a flat array of whatever the rest of the path finds
 func pluck(o map[string]interface{}, path string) interface{} {
+	return pluckParts(o, splitPath(path), nil)
+}
+
+// splitPath breaks a path up into its segments
+func splitPath(path string) []string {
 	// support dots for now because thats all we need
-	parts := strings.Split(path, ".")
-
-	if len(parts) == 1 && o[parts[0]] != nil {
-		// if there is only one part, just return that property value
-		return o[parts[0]]
-	} else if len(parts) > 1 && o[parts[0]] != nil {
-		var prev map[string]interface{}
-		var ok bool
-		if prev, ok = o[parts[0]].(map[string]interface{}); !ok {
-			// not an object type! ...or a map, yeah, that.
+	return strings.Split(strings.Replace(path, "[*]", ".*", -1), ".")
+}
+
+// pluckParts walks v one path segment at a time
+// n counts every value visited along the way and can be nil
+func pluckParts(v interface{}, parts []string, n *nodes) interface{} {
+	for i, part := range parts {
+		if !n.visit() {
+			return nil
+		}
+		if v = resolveLazy(v, n); v == nil {
 			return nil
 		}
 
-		for i := 1; i < len(parts)-1; i++ {
-			// we need to check the existence of another
-			// map[string]interface for every property along the way
-			cp := parts[i]
-
-			if prev[cp] == nil {
-				// didn't find the property, it's missing
+		if part == "*" {
+			items, ok := asSlice(v)
+			if !ok {
 				return nil
 			}
-			var ok bool
-			if prev, ok = prev[cp].(map[string]interface{}); !ok {
+
+			rest := parts[i+1:]
+			out := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				if len(rest) == 0 && item != nil {
+					// elements of a Go slice, like []int, come out like JSON's
+					item = jsonish(reflect.ValueOf(item))
+				}
+				pv := pluckParts(item, rest, n)
+				if pv == nil {
+					// missing on this element, skip it
+					continue
+				}
+
+				if nested, ok := pv.([]interface{}); ok && hasWildcard(rest) {
+					out = append(out, nested...)
+				} else {
+					out = append(out, pv)
+				}
+			}
+
+			return out
+		}
+
+		// we need to check the existence of another
+		// map[string]interface{} for every property along the way
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			// not an object type! ...or a map, yeah, that.
+			// unless it's a struct or some other Go value we can walk
+			if v = pluckField(v, part); v == nil {
 				return nil
 			}
+			continue
 		}
 
-		if prev[parts[len(parts)-1]] != nil {
-			return prev[parts[len(parts)-1]]
-		} else {
+		if v = m[part]; v == nil {
+			// didn't find the property, it's missing
 			return nil
 		}
 	}
 
-	return nil
+	return resolveLazy(v, n)
 }
 
-func compareUint(op int, actual, expected interface{}) bool {
+func hasWildcard(parts []string) bool {
+	for _, p := range parts {
+		if p == "*" {
+			return true
+		}
+	}
 
-	var cmpUint [2]uint64
-	cmpUint[0] = actual.(uint64)
-	cmpUint[1] = expected.(uint64)
+	return false
+}
 
+func compareUint(op int, actual, expected uint64) bool {
 	switch op {
 	case gt:
-		return cmpUint[0] > cmpUint[1]
+		return actual > expected
 	case gte:
-		return cmpUint[0] >= cmpUint[1]
+		return actual >= expected
 	case lt:
-		return cmpUint[0] < cmpUint[1]
+		return actual < expected
 	case lte:
-		return cmpUint[0] <= cmpUint[1]
+		return actual <= expected
 	}
 
 	return false
 }
 
-func compareInt(op int, actual, expected interface{}) bool {
-
-	var cmpInt [2]int64
-	cmpInt[0] = actual.(int64)
-	cmpInt[1] = expected.(int64)
-
+func compareInt(op int, actual, expected int64) bool {
 	switch op {
 	case gt:
-		return cmpInt[0] > cmpInt[1]
+		return actual > expected
 	case gte:
-		return cmpInt[0] >= cmpInt[1]
+		return actual >= expected
 	case lt:
-		return cmpInt[0] < cmpInt[1]
+		return actual < expected
 	case lte:
-		return cmpInt[0] <= cmpInt[1]
+		return actual <= expected
 	}
 
 	return false
 }
 
-func compareFloat(op int, actual, expected interface{}) bool {
-
-	var cmpFloat [2]float64
-	cmpFloat[0] = actual.(float64)
-	cmpFloat[1] = expected.(float64)
-
+func compareFloat(op int, actual, expected float64) bool {
 	switch op {
 	case gt:
-		return cmpFloat[0] > cmpFloat[1]
+		return actual > expected
 	case gte:
-		return cmpFloat[0] >= cmpFloat[1]
+		return actual >= expected
 	case lt:
-		return cmpFloat[0] < cmpFloat[1]
+		return actual < expected
 	case lte:
-		return cmpFloat[0] <= cmpFloat[1]
+		return actual <= expected
 	}
 
 	return false
 }
 
-func compareStr(op int, actual, expected interface{}) bool {
-
-	var cmpStr [2]string
-	cmpStr[0] = actual.(string)
-	cmpStr[1] = expected.(string)
+// compareStr orders strings byte by byte, by WithCollation's language,
+// or as numbers with WithNumericStrings
+func (r *Ruler) compareStr(op int, actual, expected string) (bool, error) {
+	if !r.numericStrings {
+		if r.collation != nil {
+			return ordered(op, r.collation.compare(actual, expected)), nil
+		}
+		return ordered(op, strings.Compare(actual, expected)), nil
+	}
 
-	actualFloat, err := strconv.ParseFloat(cmpStr[0], 64)
+	actualFloat, err := strconv.ParseFloat(actual, 64)
 	if err != nil {
-		return false
+		return false, fmt.Errorf("actual value %q not actually a number, bailing", actual)
 	}
-	expectedFloat, err := strconv.ParseFloat(cmpStr[1], 64)
+	expectedFloat, err := strconv.ParseFloat(expected, 64)
 	if err != nil {
-		return false
+		return false, fmt.Errorf("expected value %q not actually a number, bailing", expected)
 	}
 
+	return compareFloat(op, actualFloat, expectedFloat), nil
+}
+
+// ordered turns the result of a three-way comparison into op's answer
+func ordered(op, cmp int) bool {
 	switch op {
 	case gt:
-		return actualFloat > expectedFloat
+		return cmp > 0
 	case gte:
-		return actualFloat >= expectedFloat
+		return cmp >= 0
 	case lt:
-		return actualFloat < expectedFloat
+		return cmp < 0
 	case lte:
-		return actualFloat <= expectedFloat
+		return cmp <= 0
 	}
 
 	return false
@@ -0,0 +1,134 @@
+/*
+Package rulerbson tests documents read with the MongoDB driver against rules
+
+bson.M, bson.D and bson.Raw documents are turned into the kind of document
+a JSON one unmarshals to, with Mongo's own values made into ones rules compare naturally:
+ObjectIDs become their hex string, DateTimes and Timestamps become time.Time,
+and every integer becomes a float64, so the same rules work on a record
+whether it came out of Mongo or over HTTP:
+
+	var rec bson.M
+	err := coll.FindOne(ctx, filter).Decode(&rec)
+	ok, err := rulerbson.Test(c, rec)
+*/
+package rulerbson
+
+import (
+	"fmt"
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Test tests a Mongo document against c's rules
+func Test(c *ruler.CompiledRuler, doc interface{}) (bool, error) {
+	m, err := Document(doc)
+	if err != nil {
+		return false, err
+	}
+
+	return c.Test(m)
+}
+
+// Evaluate tests a Mongo document against c's rules and says which one it failed, if any
+func Evaluate(c *ruler.CompiledRuler, doc interface{}) *ruler.Result {
+	m, err := Document(doc)
+	if err != nil {
+		return &ruler.Result{Err: err}
+	}
+
+	return c.Evaluate(m)
+}
+
+// Document converts a bson.M, bson.D or bson.Raw into a document rules can be tested against
+func Document(doc interface{}) (map[string]interface{}, error) {
+	if raw, ok := doc.(bson.Raw); ok {
+		var d bson.D
+		if err := bson.Unmarshal(raw, &d); err != nil {
+			return nil, fmt.Errorf("decoding bson: %w", err)
+		}
+		doc = d
+	}
+
+	switch v := Value(doc).(type) {
+	case map[string]interface{}:
+		return v, nil
+	case nil:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("rulerbson: %T isn't a document", doc)
+}
+
+// Value converts one value from a Mongo document, and everything in it
+func Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case primitive.M:
+		return object(v)
+	case map[string]interface{}:
+		return object(v)
+	case primitive.D:
+		// like decoding JSON, a repeated key keeps its last value
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = Value(e.Value)
+		}
+		return m
+	case primitive.A:
+		return array(v)
+	case []interface{}:
+		return array(v)
+	case bson.Raw:
+		doc, err := Document(v)
+		if err != nil {
+			return nil
+		}
+		return doc
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0).UTC()
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Regex:
+		return v.Pattern
+	case primitive.Symbol:
+		return string(v)
+	case primitive.JavaScript:
+		return string(v)
+	case primitive.Null, primitive.Undefined:
+		return nil
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+
+	return v
+}
+
+func object(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = Value(v)
+	}
+
+	return out
+}
+
+func array(items []interface{}) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = Value(item)
+	}
+
+	return out
+}
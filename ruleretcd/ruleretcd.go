@@ -0,0 +1,288 @@
+/*
+Package ruleretcd is a ruler.RuleStore backed by etcd
+
+rules are kept under a key prefix and streamed to watchers as soon as
+they change, so a fleet of ruler.LiveRulers rolls out new rules together:
+
+	store := ruleretcd.New(client, "/rules/")
+	live, err := ruler.WatchRuler(ctx, store, "checkout", logError)
+
+rules are checked before they're saved and before they're sent to watchers,
+so a bad update never reaches a running Ruler
+*/
+package ruleretcd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Store keeps rules in etcd
+type Store struct {
+	client *clientv3.Client
+	prefix string
+	// opts are what rules are checked with, they should match
+	// the options the Rulers loading them use
+	opts []ruler.Option
+}
+
+var _ ruler.RuleStore = (*Store)(nil)
+
+// Update is a change to one of the rulesets under the prefix
+type Update struct {
+	Name string
+	// Rules is nil when the ruleset was deleted
+	Rules []byte
+}
+
+// New stores rules as prefix+name in client, checking them with opts
+func New(client *clientv3.Client, prefix string, opts ...ruler.Option) *Store {
+	return &Store{client: client, prefix: prefix, opts: opts}
+}
+
+// check makes sure rules can be loaded and compiled
+func (s *Store) check(name string, rules []byte) error {
+	r, err := ruler.NewRulerWithJSON(rules, s.opts...)
+	if err == nil {
+		_, err = r.Compile()
+	}
+	if err != nil {
+		return fmt.Errorf("rules (%s) aren't valid: %w", name, err)
+	}
+
+	return nil
+}
+
+// Load gets the rules saved as name
+func (s *Store) Load(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.client.Get(ctx, s.prefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ruler.ErrNotFound
+	}
+
+	return resp.Kvs[0].Value, nil
+}
+
+// Save checks the rules and puts them in etcd
+func (s *Store) Save(ctx context.Context, name string, rules []byte) error {
+	if err := s.check(name, rules); err != nil {
+		return err
+	}
+
+	_, err := s.client.Put(ctx, s.prefix+name, string(rules))
+	return err
+}
+
+// List returns the names of the rulesets under the prefix
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		names[i] = strings.TrimPrefix(string(kv.Key), s.prefix)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Watch sends name's rules every time they change to something valid
+func (s *Store) Watch(ctx context.Context, name string) (<-chan []byte, error) {
+	updates, err := s.watch(ctx, s.prefix+name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+
+		for u := range updates {
+			if u.Rules == nil {
+				continue
+			}
+
+			select {
+			case ch <- u.Rules:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// WatchAll streams every valid change to any ruleset under the prefix,
+// including deletions, until ctx is done
+func (s *Store) WatchAll(ctx context.Context) (<-chan Update, error) {
+	return s.watch(ctx, s.prefix, true)
+}
+
+// watch reads key (or every key under it) once, so the watch can start
+// from just after that revision, and nothing changed after Watch returns is missed
+func (s *Store) watch(ctx context.Context, key string, prefix bool) (<-chan Update, error) {
+	w := &watcher{store: s, key: key, prefix: prefix, known: make(map[string]bool)}
+	resp, err := w.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range resp.Kvs {
+		w.known[strings.TrimPrefix(string(kv.Key), s.prefix)] = true
+	}
+
+	ch := make(chan Update)
+	go w.run(ctx, ch, resp.Header.Revision)
+
+	return ch, nil
+}
+
+// watcher follows a key, or every key under a prefix, from one revision to the next
+type watcher struct {
+	store  *Store
+	key    string
+	prefix bool
+	// known are the rulesets there as far as the watcher has seen,
+	// so ones deleted while it wasn't watching can still be sent
+	known map[string]bool
+}
+
+func (w *watcher) get(ctx context.Context) (*clientv3.GetResponse, error) {
+	if w.prefix {
+		return w.store.client.Get(ctx, w.key, clientv3.WithPrefix())
+	}
+	return w.store.client.Get(ctx, w.key)
+}
+
+// run sends the changes after rev until ctx is done
+// etcd ends a watch when the revisions it has to send have been compacted,
+// so when one ends it starts again from whatever's there now
+func (w *watcher) run(ctx context.Context, ch chan<- Update, rev int64) {
+	defer close(ch)
+
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRev(rev + 1)}
+		if w.prefix {
+			opts = append(opts, clientv3.WithPrefix())
+		}
+
+		compacted := false
+		for resp := range w.store.client.Watch(ctx, w.key, opts...) {
+			if resp.Err() != nil {
+				compacted = compacted || resp.CompactRevision != 0
+				continue
+			}
+
+			for _, ev := range resp.Events {
+				rev = ev.Kv.ModRevision
+				u, ok := w.update(ev.Kv.Key, ev.Kv.Value, ev.Type == clientv3.EventTypePut)
+				if ok && !w.send(ctx, ch, u) {
+					return
+				}
+			}
+		}
+
+		// anything else that ends a watch, like etcd going away,
+		// gets a moment to clear up before trying again
+		if !compacted && !w.wait(ctx) {
+			return
+		}
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			resp, err := w.get(ctx)
+			if err == nil {
+				rev = resp.Header.Revision
+				if !w.resync(ctx, ch, resp) {
+					return
+				}
+				break
+			}
+
+			if !w.wait(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// update is the Update for a put or delete of key,
+// ok is false for rules that aren't valid
+func (w *watcher) update(key, value []byte, put bool) (u Update, ok bool) {
+	u.Name = strings.TrimPrefix(string(key), w.store.prefix)
+	if !put {
+		delete(w.known, u.Name)
+		return u, true
+	}
+
+	// somebody could have put rules there without going through Save
+	if w.store.check(u.Name, value) != nil {
+		return u, false
+	}
+	u.Rules = value
+	w.known[u.Name] = true
+
+	return u, true
+}
+
+// resync sends everything that's there now, and deletions
+// for the rulesets that were there before and aren't any more
+func (w *watcher) resync(ctx context.Context, ch chan<- Update, resp *clientv3.GetResponse) bool {
+	gone := w.known
+	w.known = make(map[string]bool)
+
+	for _, kv := range resp.Kvs {
+		u, ok := w.update(kv.Key, kv.Value, true)
+		delete(gone, u.Name)
+		if ok && !w.send(ctx, ch, u) {
+			return false
+		}
+	}
+
+	names := make([]string, 0, len(gone))
+	for name := range gone {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !w.send(ctx, ch, Update{Name: name}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wait pauses before trying etcd again, reporting false if ctx is done first
+func (w *watcher) wait(ctx context.Context) bool {
+	select {
+	case <-time.After(time.Second):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (w *watcher) send(ctx context.Context, ch chan<- Update, u Update) bool {
+	select {
+	case ch <- u:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
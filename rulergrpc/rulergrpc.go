@@ -0,0 +1,190 @@
+/*
+Package rulergrpc checks gRPC calls against rules, a Ruler per method
+
+	rules := rulergrpc.Methods{
+		"/orders.Orders/Cancel": cancelRules,
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(rulergrpc.UnaryServerInterceptor(rules)),
+		grpc.StreamInterceptor(rulergrpc.StreamServerInterceptor(rules)),
+	)
+
+every call is turned into a document like
+
+	{"method": "/orders.Orders/Cancel", "metadata": {"x-tenant": "acme"}, "message": {"order_id": "7"}}
+
+with the message's fields as protojson names them, and metadata keys that are
+given more than once keeping only their first value
+calls that don't pass fail with PermissionDenied, with an ErrorInfo in the
+status details for each rule that failed
+methods without rules aren't checked
+*/
+package rulergrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Reason is the ErrorInfo reason given for a failed rule
+const Reason = "RULE_FAILED"
+
+// Methods are the rules for each method, by full method name
+type Methods map[string]*ruler.Ruler
+
+// compile compiles every method's rules up front
+func (m Methods) compile() (map[string]*ruler.CompiledRuler, error) {
+	compiled := make(map[string]*ruler.CompiledRuler, len(m))
+	for method, r := range m {
+		c, err := r.Compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled[method] = c
+	}
+
+	return compiled, nil
+}
+
+// UnaryServerInterceptor checks each unary call's request against its method's rules
+// it panics if any of the rules don't compile, like regexp.MustCompile
+func UnaryServerInterceptor(methods Methods) grpc.UnaryServerInterceptor {
+	compiled, err := methods.compile()
+	if err != nil {
+		panic("rulergrpc: " + err.Error())
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if c, ok := compiled[info.FullMethod]; ok {
+			if err := check(ctx, c, info.FullMethod, req); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor checks every message a stream receives against its method's rules
+// it panics if any of the rules don't compile
+func StreamServerInterceptor(methods Methods) grpc.StreamServerInterceptor {
+	compiled, err := methods.compile()
+	if err != nil {
+		panic("rulergrpc: " + err.Error())
+	}
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c, ok := compiled[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &checkedStream{ServerStream: ss, c: c, method: info.FullMethod})
+	}
+}
+
+// checkedStream checks each message as it's received
+type checkedStream struct {
+	grpc.ServerStream
+	c      *ruler.CompiledRuler
+	method string
+}
+
+func (s *checkedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return check(s.Context(), s.c, s.method, m)
+}
+
+// check tests one call against the rules, returning the error to fail it with
+func check(ctx context.Context, c *ruler.CompiledRuler, method string, msg interface{}) error {
+	doc, err := Document(ctx, method, msg)
+	if err != nil {
+		return status.Errorf(codes.Internal, "rulergrpc: %s", err)
+	}
+
+	m := c.EvaluateEvery(doc)
+	defer m.Release()
+
+	if m.Matched {
+		return nil
+	}
+
+	st := status.New(codes.PermissionDenied, "request didn't pass the rules for "+method)
+	var details []protoadapt.MessageV1
+	for _, res := range m.Failures {
+		details = append(details, errorInfo(res))
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+// errorInfo describes a failed rule for the status details
+func errorInfo(res *ruler.Result) *errdetails.ErrorInfo {
+	info := &errdetails.ErrorInfo{Reason: Reason, Domain: "go-ruler", Metadata: map[string]string{}}
+	if f := res.Failed; f != nil {
+		if f.ID != "" {
+			info.Metadata["id"] = f.ID
+		}
+		if f.Path != "" {
+			info.Metadata["path"] = f.Path
+		}
+	}
+	if res.Message != "" {
+		info.Metadata["message"] = res.Message
+	}
+	if res.Err != nil {
+		info.Metadata["error"] = res.Err.Error()
+	}
+
+	return info
+}
+
+// Document is the document a call is tested as, see the package docs
+func Document(ctx context.Context, method string, msg interface{}) (map[string]interface{}, error) {
+	md := make(map[string]interface{})
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range incoming {
+			if len(values) > 0 {
+				md[key] = values[0]
+			}
+		}
+	}
+
+	var data []byte
+	var err error
+	if pm, ok := msg.(proto.Message); ok {
+		data, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(pm)
+	} else {
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fields interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"method":   method,
+		"metadata": md,
+		"message":  fields,
+	}, nil
+}
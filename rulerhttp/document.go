@@ -0,0 +1,210 @@
+package rulerhttp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// A DocumentOption adds to the document Document builds for a request
+type DocumentOption func(*documenter)
+
+type documenter struct {
+	multi   bool
+	proxies []netip.Prefix
+	jwt     bool
+	verify  func(token string) error
+}
+
+// MultiValue keeps every value of headers and query parameters
+// that are given more than once, so they're all arrays
+func MultiValue() DocumentOption {
+	return func(d *documenter) {
+		d.multi = true
+	}
+}
+
+// TrustProxies works out "remote_ip" from X-Forwarded-For when the request
+// comes from one of prefixes, skipping over any of them in the header too,
+// so it's the client's address rather than the load balancer's
+func TrustProxies(prefixes ...netip.Prefix) DocumentOption {
+	return func(d *documenter) {
+		d.proxies = append(d.proxies, prefixes...)
+	}
+}
+
+// WithJWT adds the claims of the request's bearer token as "claims", once
+// verify says the token's good; verify can only be nil when something in front
+// of the service has already checked the token
+// a request without a token, or with one that doesn't verify, has no claims
+func WithJWT(verify func(token string) error) DocumentOption {
+	return func(d *documenter) {
+		d.jwt = true
+		d.verify = verify
+	}
+}
+
+// RequestDocument turns a request into a document like
+//
+//	{"method": "GET", "path": "/orders/7", "host": "example.com",
+//	 "remote_addr": "10.0.0.1:5123", "remote_ip": "10.0.0.1",
+//	 "headers": {"x-api-key": "..."}, "query": {"page": "2"}}
+//
+// header names are lowercased, and headers and query parameters
+// that are given more than once only keep their first value
+// the body is left alone, see JSONBody
+func RequestDocument(req *http.Request) map[string]interface{} {
+	return (&documenter{}).document(req)
+}
+
+// Document is RequestDocument with options, for passing to Middleware
+func Document(opts ...DocumentOption) func(*http.Request) map[string]interface{} {
+	d := &documenter{}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d.document
+}
+
+func (d *documenter) document(req *http.Request) map[string]interface{} {
+	headers := make(map[string]interface{}, len(req.Header))
+	for name, values := range req.Header {
+		if v := d.values(values); v != nil {
+			headers[strings.ToLower(name)] = v
+		}
+	}
+
+	query := make(map[string]interface{})
+	for name, values := range req.URL.Query() {
+		if v := d.values(values); v != nil {
+			query[name] = v
+		}
+	}
+
+	doc := map[string]interface{}{
+		"method":      req.Method,
+		"path":        req.URL.Path,
+		"host":        req.Host,
+		"remote_addr": req.RemoteAddr,
+		"headers":     headers,
+		"query":       query,
+	}
+	if ip := d.remoteIP(req); ip.IsValid() {
+		doc["remote_ip"] = ip.String()
+	}
+	if d.jwt {
+		if claims := d.claims(req); claims != nil {
+			doc["claims"] = claims
+		}
+	}
+
+	return doc
+}
+
+func (d *documenter) values(values []string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	if !d.multi {
+		return values[0]
+	}
+
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+
+	return out
+}
+
+// remoteIP is the address the request came from, or the client's
+// if it came through proxies we trust
+func (d *documenter) remoteIP(req *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	ip = ip.Unmap()
+
+	if !d.trusted(ip) {
+		return ip
+	}
+
+	// each proxy appends who it got the request from, so walk back
+	// from the end until something isn't one of ours
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		ip = hop.Unmap()
+		if !d.trusted(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (d *documenter) trusted(ip netip.Addr) bool {
+	for _, p := range d.proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *documenter) claims(req *http.Request) map[string]interface{} {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil
+	}
+	token = strings.TrimSpace(token)
+
+	if d.verify != nil && d.verify(token) != nil {
+		return nil
+	}
+
+	claims, err := ParseJWT(token)
+	if err != nil {
+		return nil
+	}
+
+	return claims
+}
+
+// ErrMalformedJWT is returned by ParseJWT for something that isn't a JWT
+var ErrMalformedJWT = errors.New("rulerhttp: malformed JWT")
+
+// ParseJWT decodes a JWT's claims without checking its signature,
+// so only use it on tokens that have already been verified
+func ParseJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedJWT
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, ErrMalformedJWT
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrMalformedJWT
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,124 @@
+package rulerhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+// A MiddlewareOption changes what Middleware does with a request
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	annotate bool
+	reject   func(w http.ResponseWriter, req *http.Request, res *ruler.Result)
+}
+
+// Annotate lets every request through, with its Result in the
+// request's context for the handler to act on, see ResultFromContext
+func Annotate() MiddlewareOption {
+	return func(m *middleware) {
+		m.annotate = true
+	}
+}
+
+// OnReject replaces the default 403 Forbidden (or 500 for a request the rules
+// couldn't be tested against) with fn, for redirecting or answering some other way
+func OnReject(fn func(w http.ResponseWriter, req *http.Request, res *ruler.Result)) MiddlewareOption {
+	return func(m *middleware) {
+		m.reject = fn
+	}
+}
+
+type resultKey struct{}
+
+// ResultFromContext is the Result Middleware left in a request's context
+// when it's annotating, nil if there isn't one
+func ResultFromContext(ctx context.Context) *ruler.Result {
+	res, _ := ctx.Value(resultKey{}).(*ruler.Result)
+	return res
+}
+
+// Middleware tests every request against r's rules, turning it into a document
+// with extract (RequestDocument if extract is nil, Document for more), and only passes on the ones
+// that match unless told to Annotate them instead
+// the rules are compiled once, up front, so changes to r afterwards aren't seen
+func Middleware(r *ruler.Ruler, extract func(*http.Request) map[string]interface{}, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{reject: reject}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if extract == nil {
+		extract = RequestDocument
+	}
+
+	c, compileErr := r.Compile()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var res *ruler.Result
+			if compileErr != nil {
+				res = &ruler.Result{Err: compileErr}
+			} else {
+				res = c.EvaluateContext(req.Context(), extract(req))
+			}
+
+			if m.annotate {
+				next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), resultKey{}, res)))
+				return
+			}
+
+			if res.Matched {
+				res.Release()
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			m.reject(w, req, res)
+		})
+	}
+}
+
+// reject answers a request that didn't match with the same JSON Handler uses
+func reject(w http.ResponseWriter, req *http.Request, res *ruler.Result) {
+	status := http.StatusForbidden
+	if res.Err != nil {
+		status = http.StatusInternalServerError
+	}
+
+	writeJSON(w, status, response(res))
+}
+
+// JSONBody decodes up to max bytes of a request's JSON body, putting the body
+// back so the next handler can still read it; an extract function can add it
+// to RequestDocument's document as "body"
+// a body that isn't JSON comes back nil
+func JSONBody(req *http.Request, max int64) interface{} {
+	if req.Body == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Body, max))
+	// whatever's past max is still there to read after what was decoded
+	req.Body = readCloser{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+	if err != nil {
+		return nil
+	}
+
+	var body interface{}
+	if json.Unmarshal(data, &body) != nil {
+		return nil
+	}
+
+	return body
+}
+
+// readCloser reads from one place and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,189 @@
+/*
+Package rulerhttp serves rules over HTTP, so services that aren't written in Go
+can use the same rules
+
+	http.Handle("/", rulerhttp.NewHandler(rulerhttp.Static{"checkout": compiled}))
+
+POST a JSON document to /evaluate/{ruleset} and get back something like
+
+	{"matched": false, "failed": {"id": "adults", "path": "user.age"}, "message": "too young"}
+
+add ?every=true to get every rule the document failed, under "failures"
+*/
+package rulerhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	ruler "github.com/hopkinsth/go-ruler"
+)
+
+// ErrUnknownRuleset is what a Source returns for a ruleset it doesn't have
+var ErrUnknownRuleset = errors.New("rulerhttp: unknown ruleset")
+
+// A Source finds the rules a request asks for by name
+type Source interface {
+	Ruleset(ctx context.Context, name string) (*ruler.CompiledRuler, error)
+}
+
+// Static is a Source with a fixed set of rules
+type Static map[string]*ruler.CompiledRuler
+
+// Ruleset looks up name
+func (s Static) Ruleset(ctx context.Context, name string) (*ruler.CompiledRuler, error) {
+	c, ok := s[name]
+	if !ok {
+		return nil, ErrUnknownRuleset
+	}
+
+	return c, nil
+}
+
+// Live is a Source of rules that are kept up to date, see ruler.WatchRuler
+type Live map[string]*ruler.LiveRuler
+
+// Ruleset looks up name and returns the rules in effect right now
+func (l Live) Ruleset(ctx context.Context, name string) (*ruler.CompiledRuler, error) {
+	live, ok := l[name]
+	if !ok {
+		return nil, ErrUnknownRuleset
+	}
+
+	return live.Compiled(), nil
+}
+
+// DefaultMaxBody is the biggest document a Handler accepts unless told otherwise
+const DefaultMaxBody = 1 << 20
+
+// Handler serves POST /evaluate/{ruleset}
+type Handler struct {
+	source Source
+	mux    *http.ServeMux
+	// MaxBody is the biggest document accepted, in bytes
+	MaxBody int64
+}
+
+// NewHandler serves the rules from source
+func NewHandler(source Source) *Handler {
+	h := &Handler{source: source, mux: http.NewServeMux(), MaxBody: DefaultMaxBody}
+	h.mux.HandleFunc("POST /evaluate/{ruleset}", h.evaluate)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// Response is the JSON a Handler answers with
+type Response struct {
+	Matched bool `json:"matched"`
+	// Failed is the rule the document failed, if it failed one
+	Failed *FailedRule `json:"failed,omitempty"`
+	// Failures are all the rules it failed, when asked for with ?every=true
+	Failures []FailedRule `json:"failures,omitempty"`
+	Message  string       `json:"message,omitempty"`
+	// Error is set when the rules couldn't be tested against the document
+	Error   string `json:"error,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// FailedRule describes a rule a document failed
+type FailedRule struct {
+	ID         string `json:"id,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Comparator string `json:"comparator,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (h *Handler) evaluate(w http.ResponseWriter, req *http.Request) {
+	c, err := h.source.Ruleset(req.Context(), req.PathValue("ruleset"))
+	if errors.Is(err, ErrUnknownRuleset) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var doc map[string]interface{}
+	body := http.MaxBytesReader(w, req.Body, h.MaxBody)
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var resp Response
+	if req.URL.Query().Get("every") == "true" {
+		m := c.EvaluateEvery(doc)
+		resp = everyResponse(m)
+		m.Release()
+	} else {
+		res := c.EvaluateContext(req.Context(), doc)
+		resp = response(res)
+		res.Release()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func response(res *ruler.Result) Response {
+	resp := Response{
+		Matched: res.Matched,
+		Message: res.Message,
+		Version: res.Version,
+	}
+	if res.Err != nil {
+		resp.Error = res.Err.Error()
+	}
+	if res.Failed != nil {
+		f := failedRule(res)
+		resp.Failed = &f
+	}
+
+	return resp
+}
+
+func everyResponse(m *ruler.MultiResult) Response {
+	resp := Response{Matched: m.Matched, Version: m.Version}
+	if err := m.Err(); err != nil {
+		resp.Error = err.Error()
+	}
+	for _, res := range m.Failures {
+		resp.Failures = append(resp.Failures, failedRule(res))
+	}
+
+	return resp
+}
+
+func failedRule(res *ruler.Result) FailedRule {
+	f := FailedRule{Message: res.Message}
+	if res.Failed != nil {
+		f.ID, f.Path, f.Comparator = res.Failed.ID, res.Failed.Path, res.Failed.Comparator
+	}
+	if res.Err != nil {
+		f.Error = res.Err.Error()
+	}
+
+	return f
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Response{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,133 @@
+/*
+Package rulerotel puts rule evaluation into OpenTelemetry traces
+
+	ok, err := rulerotel.TestContext(ctx, compiled, doc)
+
+tests doc inside a span of its own, while
+
+	ok, err := rulerotel.AnnotateContext(ctx, compiled, doc)
+
+adds an event to whatever span is already active in ctx, for when
+a span per evaluation would be too many
+
+both record whether the document matched, which rule it failed and how long it took
+spans come from the global TracerProvider unless WithTracerProvider says otherwise
+*/
+package rulerotel
+
+import (
+	"context"
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans are started with
+const instrumentationName = "github.com/hopkinsth/go-ruler/rulerotel"
+
+// the attributes set on spans and events
+const (
+	MatchedKey    = attribute.Key("ruler.matched")
+	FailedIDKey   = attribute.Key("ruler.failed_rule.id")
+	FailedPathKey = attribute.Key("ruler.failed_rule.path")
+	DurationKey   = attribute.Key("ruler.duration_ms")
+)
+
+type config struct {
+	provider trace.TracerProvider
+	name     string
+}
+
+// An Option configures TestContext and AnnotateContext
+type Option func(*config)
+
+// WithTracerProvider starts spans from p instead of the global TracerProvider
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = p
+	}
+}
+
+// WithSpanName names the span TestContext starts, "ruler.Test" by default
+// (or the name of the event AnnotateContext adds, "ruler.evaluated")
+func WithSpanName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// TestContext is CompiledRuler's TestContext in a span of its own
+func TestContext(ctx context.Context, c *ruler.CompiledRuler, o map[string]interface{}, opts ...Option) (bool, error) {
+	cfg := newConfig(opts, "ruler.Test")
+
+	ctx, span := cfg.provider.Tracer(instrumentationName).Start(ctx, cfg.name)
+	defer span.End()
+
+	res, took := evaluate(ctx, c, o)
+	defer res.Release()
+
+	span.SetAttributes(attributes(res, took)...)
+	if res.Err != nil {
+		span.RecordError(res.Err)
+		span.SetStatus(codes.Error, res.Err.Error())
+	}
+
+	return res.Matched, res.Err
+}
+
+// AnnotateContext is CompiledRuler's TestContext, recorded as an event
+// on the span already active in ctx; if there isn't one it just tests o
+func AnnotateContext(ctx context.Context, c *ruler.CompiledRuler, o map[string]interface{}, opts ...Option) (bool, error) {
+	cfg := newConfig(opts, "ruler.evaluated")
+
+	res, took := evaluate(ctx, c, o)
+	defer res.Release()
+
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(cfg.name, trace.WithAttributes(attributes(res, took)...))
+	if res.Err != nil {
+		span.RecordError(res.Err)
+	}
+
+	return res.Matched, res.Err
+}
+
+func newConfig(opts []Option, name string) *config {
+	cfg := &config{name: name}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.provider == nil {
+		cfg.provider = otel.GetTracerProvider()
+	}
+
+	return cfg
+}
+
+func evaluate(ctx context.Context, c *ruler.CompiledRuler, o map[string]interface{}) (*ruler.Result, time.Duration) {
+	start := time.Now()
+	res := c.EvaluateContext(ctx, o)
+
+	return res, time.Since(start)
+}
+
+func attributes(res *ruler.Result, took time.Duration) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		MatchedKey.Bool(res.Matched),
+		DurationKey.Float64(float64(took) / float64(time.Millisecond)),
+	}
+	if f := res.Failed; f != nil {
+		if f.ID != "" {
+			attrs = append(attrs, FailedIDKey.String(f.ID))
+		}
+		if f.Path != "" {
+			attrs = append(attrs, FailedPathKey.String(f.Path))
+		}
+	}
+
+	return attrs
+}
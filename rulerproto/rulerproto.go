@@ -0,0 +1,173 @@
+/*
+Package rulerproto tests protobuf messages against rules, walking them
+with protoreflect instead of going through JSON
+
+path segments are the messages' proto field names ("user_id", not "userId",
+though the JSON name works too when nothing's called that) and values come out
+the way protojson would give them: enums as their names, 64-bit integers as
+numbers, Timestamps as time.Time and Durations as time.Duration,
+wrapper types as the value they wrap and Structs as plain maps
+
+	ev, err := rulerproto.NewEvaluator(r)
+	ok, err := ev.Test(req)
+
+fields that track presence (messages, oneofs and optional fields) are missing
+when they aren't set, plain proto3 scalars are their zero value
+*/
+package rulerproto
+
+import (
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Evaluator tests messages against compiled rules
+type Evaluator struct {
+	ev *ruler.Evaluator[ruler.Walkable]
+}
+
+// NewEvaluator compiles r's rules for testing messages
+func NewEvaluator(r *ruler.Ruler) (*Evaluator, error) {
+	ev, err := ruler.NewEvaluator[ruler.Walkable](r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator{ev: ev}, nil
+}
+
+// Test tests a message against the rules
+func (ev *Evaluator) Test(msg proto.Message) (bool, error) {
+	return ev.ev.Test(Message(msg))
+}
+
+// Evaluate tests a message against the rules and says which one it failed, if any
+func (ev *Evaluator) Evaluate(msg proto.Message) *ruler.Result {
+	return ev.ev.Evaluate(Message(msg))
+}
+
+// Test tests one message against r's rules, use an Evaluator for more than one
+func Test(r *ruler.Ruler, msg proto.Message) (bool, error) {
+	ev, err := NewEvaluator(r)
+	if err != nil {
+		return false, err
+	}
+
+	return ev.Test(msg)
+}
+
+// Message makes msg walkable by rule paths
+func Message(msg proto.Message) ruler.Walkable {
+	return message{msg.ProtoReflect()}
+}
+
+// message is a protobuf message paths can walk into
+type message struct {
+	m protoreflect.Message
+}
+
+func (m message) Field(name string) interface{} {
+	fields := m.m.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		if fd = fields.ByJSONName(name); fd == nil {
+			return nil
+		}
+	}
+
+	if fd.HasPresence() && !m.m.Has(fd) {
+		return nil
+	}
+
+	return fieldValue(fd, m.m.Get(fd))
+}
+
+// MarshalJSON is how a message is written out, for audit records
+func (m message) MarshalJSON() ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(m.m.Interface())
+}
+
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		out := make([]interface{}, list.Len())
+		for i := range out {
+			out[i] = singular(fd, list.Get(i))
+		}
+		return out
+	case fd.IsMap():
+		out := make(map[string]interface{}, v.Map().Len())
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			out[k.String()] = singular(fd.MapValue(), mv)
+			return true
+		})
+		return out
+	}
+
+	return singular(fd, v)
+}
+
+// singular converts one value that isn't a list or map
+func singular(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return v.Bytes()
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return float64(v.Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageValue(v.Message())
+	}
+
+	return v.Interface()
+}
+
+// messageValue is a nested message, with the well-known types
+// turned into the values they stand for
+func messageValue(m protoreflect.Message) interface{} {
+	fields := m.Descriptor().Fields()
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp":
+		return time.Unix(m.Get(fields.ByNumber(1)).Int(), m.Get(fields.ByNumber(2)).Int()).UTC()
+	case "google.protobuf.Duration":
+		return time.Duration(m.Get(fields.ByNumber(1)).Int())*time.Second +
+			time.Duration(m.Get(fields.ByNumber(2)).Int())
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		fd := fields.ByNumber(1)
+		return singular(fd, m.Get(fd))
+	}
+
+	switch wk := m.Interface().(type) {
+	case *structpb.Struct:
+		return wk.AsMap()
+	case *structpb.Value:
+		return wk.AsInterface()
+	case *structpb.ListValue:
+		return wk.AsSlice()
+	}
+
+	return message{m}
+}
@@ -0,0 +1,127 @@
+/*
+Package rulerredis is a ruler.RuleStore backed by Redis, and a ruler.StateStore for threshold rules
+
+rules are kept as strings under a key prefix, and every Save publishes
+the name of the rules that changed, so a ruler.LiveRuler watching them
+on any machine picks up the change straight away:
+
+	store := rulerredis.New(client, "rules:")
+	live, err := ruler.WatchRuler(ctx, store, "checkout", logError)
+*/
+package rulerredis
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps rules in Redis
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ ruler.RuleStore = (*Store)(nil)
+
+// New stores rules as prefix+name in client
+// changes are announced on the prefix+"changed" channel
+func New(client redis.UniversalClient, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+func (s *Store) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *Store) channel() string {
+	return s.prefix + "changed"
+}
+
+// Load gets the rules saved as name
+func (s *Store) Load(ctx context.Context, name string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.key(name)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ruler.ErrNotFound
+	}
+
+	return data, err
+}
+
+// Save sets the rules and lets watchers know they've changed,
+// in one transaction so nobody hears about rules that weren't saved
+func (s *Store) Save(ctx context.Context, name string, rules []byte) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key(name), rules, 0)
+		pipe.Publish(ctx, s.channel(), name)
+		return nil
+	})
+
+	return err
+}
+
+// List scans for every key under the prefix
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	var names []string
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		if key := iter.Val(); key != s.channel() {
+			names = append(names, strings.TrimPrefix(key, s.prefix))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Watch subscribes to changes and sends name's rules whenever they're saved
+func (s *Store) Watch(ctx context.Context, name string) (<-chan []byte, error) {
+	sub := s.client.Subscribe(ctx, s.channel())
+	// wait for the subscription, so changes from here on aren't missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+		defer sub.Close()
+
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				if msg.Payload != name {
+					continue
+				}
+
+				data, err := s.Load(ctx, name)
+				if err != nil {
+					// a missed change will come around with the next one
+					continue
+				}
+
+				select {
+				case ch <- data:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,72 @@
+package rulerredis
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	ruler "github.com/hopkinsth/go-ruler"
+	"github.com/redis/go-redis/v9"
+)
+
+// State is a ruler.StateStore in Redis, so threshold rules and correlations
+// see events from every machine together:
+//
+//	r := ruler.NewRuler(rules, ruler.WithState(rulerredis.NewState(client, "ruler:state:")))
+//
+// a counted key is a sorted set of its events' times that expires with its window,
+// and an armed key is a string of when it expires that Redis expires too
+type State struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ ruler.StateStore = (*State)(nil)
+
+// NewState keeps state as prefix+key in client
+func NewState(client redis.UniversalClient, prefix string) *State {
+	return &State{client: client, prefix: prefix}
+}
+
+// Count records an event for key at at, see ruler.StateStore
+func (s *State) Count(ctx context.Context, key string, at time.Time, window time.Duration) (int64, error) {
+	k := s.prefix + key
+	ms := at.UnixMilli()
+	// events in the same millisecond still need members of their own
+	member := strconv.FormatInt(at.UnixNano(), 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
+
+	var card *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, k, "-inf", strconv.FormatInt(ms-window.Milliseconds(), 10))
+		pipe.ZAdd(ctx, k, redis.Z{Score: float64(ms), Member: member})
+		card = pipe.ZCard(ctx, k)
+		pipe.PExpire(ctx, k, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return card.Val(), nil
+}
+
+// Arm remembers key until ttl after at, see ruler.StateStore
+func (s *State) Arm(ctx context.Context, key string, at time.Time, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, at.Add(ttl).UnixMilli(), ttl).Err()
+}
+
+// Disarm forgets key, see ruler.StateStore
+// it needs Redis 6.2 or later, for GETDEL
+func (s *State) Disarm(ctx context.Context, key string, at time.Time) (bool, error) {
+	expires, err := s.client.GetDel(ctx, s.prefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return at.UnixMilli() < expires, nil
+}
@@ -0,0 +1,588 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// Violation is a generated document that fails one particular rule
+type Violation struct {
+	// Rule is the top-level rule the document fails
+	Rule *Rule
+	// Doc passes every other top-level rule, as far as that's possible
+	Doc map[string]interface{}
+}
+
+// SampleMatch generates a document that passes every rule in the Ruler
+// it tries likely values for each rule and keeps the ones that pass,
+// so it can fail for rules that are hard to satisfy (or contradictory)
+// rules using parameters can't be sampled
+func SampleMatch(r *Ruler) (map[string]interface{}, error) {
+	s := newSampler(r)
+
+	doc := make(map[string]interface{})
+	if err := s.satisfyAll(r.rules, doc); err != nil {
+		return nil, err
+	}
+
+	if ok, err := s.ruler.Test(doc); err != nil || !ok {
+		return nil, errors.New("couldn't generate a document that matches every rule")
+	}
+
+	return doc, nil
+}
+
+// SampleViolations generates, for every top-level rule, a document that fails
+// that rule but passes the rest; rules that can't be made to fail (like a regex
+// matching everything) are left out
+func SampleViolations(r *Ruler) ([]Violation, error) {
+	s := newSampler(r)
+
+	base, err := SampleMatch(r)
+	if err != nil {
+		// not everything can pass at once, but each rule can still fail
+		base = make(map[string]interface{})
+	}
+
+	var violations []Violation
+	for _, f := range r.rules {
+		doc := cloneValue(base).(map[string]interface{})
+		if err := s.violate(f, doc); err != nil {
+			continue
+		}
+		violations = append(violations, Violation{f, doc})
+	}
+
+	return violations, nil
+}
+
+// sampler generates documents for the rules of a Ruler
+type sampler struct {
+	ruler *Ruler
+}
+
+func newSampler(r *Ruler) *sampler {
+	// trying candidates isn't testing real documents
+	return &sampler{r.withoutSideEffects()}
+}
+
+// withoutSideEffects is a copy of r for testing documents that don't count:
+// nothing's audited, counted in coverage or instrumentation, or added to the counts
+// in the Ruler's StateStore, threshold rules count in memory instead
+func (r *Ruler) withoutSideEffects() *Ruler {
+	scratch := *r
+	scratch.audit = nil
+	scratch.coverage = nil
+	scratch.instrumentation = nil
+	if scratch.state != nil {
+		scratch.state = NewMemoryState()
+	}
+
+	return &scratch
+}
+
+// passes tests a single rule against doc, using the Ruler's options
+func (s *sampler) passes(f *Rule, doc map[string]interface{}) (bool, error) {
+	scratch := *s.ruler
+	scratch.rules = []*Rule{f}
+
+	return scratch.Test(doc)
+}
+
+// satisfyAll changes doc so every one of rules passes
+func (s *sampler) satisfyAll(rules []*Rule, doc map[string]interface{}) error {
+	// conditions on the same path have to be solved together
+	byPath := make(map[string][]*Rule)
+	var paths []string
+	var groups []*Rule
+
+	for _, f := range rules {
+		if f.IsGroup() {
+			groups = append(groups, f)
+			continue
+		}
+		if hasPathFunctions(f) {
+			return fmt.Errorf("can't sample the functions in the path (%s)", f.Path)
+		}
+		if _, ok := byPath[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		byPath[f.Path] = append(byPath[f.Path], f)
+	}
+
+	for _, path := range paths {
+		if err := s.solvePath(path, byPath[path], doc); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range groups {
+		if err := s.satisfy(g, doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// solvePath finds one value for path that passes all of rules
+func (s *sampler) solvePath(path string, rules []*Rule, doc map[string]interface{}) error {
+	for _, f := range rules {
+		for _, c := range s.candidates(f, doc, false) {
+			try := cloneValue(doc).(map[string]interface{})
+			setSample(try, f, c)
+
+			if s.allPass(rules, try) {
+				replaceDoc(doc, try)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("couldn't find a value for (%s) that passes all its rules", path)
+}
+
+func (s *sampler) allPass(rules []*Rule, doc map[string]interface{}) bool {
+	for _, f := range rules {
+		if ok, err := s.passes(f, doc); err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// satisfy changes doc so f passes
+func (s *sampler) satisfy(f *Rule, doc map[string]interface{}) error {
+	switch {
+	case f.All != nil:
+		return s.satisfyAll(f.All, doc)
+	case f.Any != nil:
+		for _, g := range f.Any {
+			try := cloneValue(doc).(map[string]interface{})
+			if s.satisfy(g, try) == nil {
+				if ok, err := s.passes(f, try); err == nil && ok {
+					replaceDoc(doc, try)
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("couldn't satisfy any rule in group (%s)", f.ID)
+	case f.Not != nil:
+		return s.violate(f.Not, doc)
+	case f.Ruleset != "":
+		return fmt.Errorf("can't sample the reference to ruleset %s", f.Ruleset)
+	}
+
+	return s.satisfyAll([]*Rule{f}, doc)
+}
+
+// violate changes doc so f fails
+func (s *sampler) violate(f *Rule, doc map[string]interface{}) error {
+	switch {
+	case f.All != nil:
+		// failing any one of them is enough
+		for _, g := range f.All {
+			try := cloneValue(doc).(map[string]interface{})
+			if s.violate(g, try) == nil {
+				if ok, err := s.passes(f, try); err == nil && !ok {
+					replaceDoc(doc, try)
+					return nil
+				}
+			}
+		}
+	case f.Any != nil:
+		// every one of them has to fail
+		try := cloneValue(doc).(map[string]interface{})
+		for _, g := range f.Any {
+			if err := s.violate(g, try); err != nil {
+				return err
+			}
+		}
+		if ok, err := s.passes(f, try); err == nil && !ok {
+			replaceDoc(doc, try)
+			return nil
+		}
+	case f.Not != nil:
+		return s.satisfy(f.Not, doc)
+	case f.Ruleset != "":
+		return fmt.Errorf("can't sample the reference to ruleset %s", f.Ruleset)
+	case hasPathFunctions(f):
+		return fmt.Errorf("can't sample the functions in the path (%s)", f.Path)
+	default:
+		for _, c := range s.candidates(f, doc, true) {
+			try := cloneValue(doc).(map[string]interface{})
+			setSample(try, f, c)
+			if ok, err := s.passes(f, try); err == nil && !ok {
+				replaceDoc(doc, try)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("couldn't make rule on (%s) fail", f.Path)
+}
+
+// missingValue is a candidate that means "leave the property off"
+type missingValue struct{}
+
+// candidates suggests values for f's path that are likely to make it pass,
+// or fail when violate is set; they all get tested, so guessing wrong is fine
+func (s *sampler) candidates(f *Rule, doc map[string]interface{}, violate bool) []interface{} {
+	v := f.Value
+	if f.ValuePath != "" {
+		if v = pluck(doc, f.ValuePath); v == nil {
+			// give the other property something to compare against
+			v = 1.0
+			setSample(doc, &Rule{Path: f.ValuePath}, v)
+		}
+	}
+
+	var pass, fail []interface{}
+	switch f.Comparator {
+	case "eq", "deep_eq":
+		pass, fail = []interface{}{v}, others(v)
+	case "approx_eq":
+		if m, ok := asMap(v); ok {
+			v = m["value"]
+		}
+		pass, fail = []interface{}{v}, others(v)
+	case "neq", "deep_neq":
+		pass, fail = others(v), []interface{}{v}
+	case "gt", "gte", "lt", "lte":
+		pass = nearby(v)
+		fail = pass
+	case "is_email":
+		pass, fail = []interface{}{"jo@example.com"}, []interface{}{"sample"}
+	case "is_url":
+		pass = []interface{}{"https://example.com", "http://example.com"}
+		if schemes, ok := asSlice(v); ok && len(schemes) > 0 {
+			pass = []interface{}{fmt.Sprintf("%v://example.com", schemes[0])}
+		}
+		fail = []interface{}{"sample"}
+	case "mod":
+		divisor, remainder := v, interface{}(0.0)
+		if m, ok := asMap(v); ok {
+			divisor = m["divisor"]
+			if r, found := m["remainder"]; found {
+				remainder = r
+			}
+		}
+		if d, ok := toInt(divisor); ok && d != 0 {
+			if r, ok := toInt(remainder); ok {
+				pass = []interface{}{float64(d*7 + r)}
+				fail = []interface{}{float64(d*7 + r + 1)}
+			}
+		}
+	case "bitand_any", "bitand_all":
+		if mask, ok := toUint(v); ok {
+			pass = []interface{}{sameKind(v, float64(mask))}
+		}
+		fail = []interface{}{0.0}
+	case "mime_type":
+		pass, fail = []interface{}{"text/plain"}, []interface{}{"sample"}
+		if want, err := patterns(v); err == nil && len(want) > 0 {
+			pass = []interface{}{strings.ReplaceAll(want[0], "*", "sample")}
+		}
+	case "extension":
+		pass, fail = []interface{}{"sample.txt"}, []interface{}{"sample"}
+		if want, err := patterns(v); err == nil && len(want) > 0 {
+			pass = []interface{}{"sample." + strings.TrimPrefix(want[0], ".")}
+		}
+	case "is_uuid":
+		pass, fail = []interface{}{"123e4567-e89b-12d3-a456-426614174000"}, []interface{}{"sample"}
+	case "soundex", "metaphone":
+		// an extra word is enough to sound different
+		if name, ok := v.(string); ok {
+			pass, fail = []interface{}{name}, []interface{}{name + " sample"}
+		}
+	case "istrue":
+		pass, fail = []interface{}{true}, []interface{}{false}
+	case "isfalse":
+		pass, fail = []interface{}{false}, []interface{}{true}
+	case "exists":
+		pass, fail = []interface{}{"sample"}, []interface{}{missingValue{}}
+	case "nexists":
+		pass, fail = []interface{}{missingValue{}}, []interface{}{"sample"}
+	case "regex", "matches", "contains", "ncontains", "regex_extract":
+		matching, nonMatching := regexSamples(v)
+		pass, fail = matching, nonMatching
+		if f.Comparator == "ncontains" {
+			pass, fail = fail, pass
+		}
+	case "supermap":
+		pass, fail = []interface{}{cloneValue(v)}, []interface{}{map[string]interface{}{}}
+	case "subset":
+		pass, fail = []interface{}{[]interface{}{}, cloneValue(v)}, []interface{}{[]interface{}{"sample"}}
+	case "superset":
+		pass, fail = []interface{}{cloneValue(v)}, []interface{}{[]interface{}{}}
+	case "intersects":
+		if items, ok := asSlice(v); ok && len(items) > 0 {
+			pass = []interface{}{[]interface{}{items[0]}}
+		}
+		fail = []interface{}{[]interface{}{}}
+	case "haskey":
+		m := map[string]interface{}{}
+		if k, ok := v.(string); ok {
+			m[k] = "sample"
+		} else if keys, ok := asSlice(v); ok {
+			for _, k := range keys {
+				m[fmt.Sprint(k)] = "sample"
+			}
+		}
+		pass, fail = []interface{}{m}, []interface{}{map[string]interface{}{}}
+	case "percent":
+		pass, fail = rolloutSamples(v)
+	}
+
+	if violate {
+		return fail
+	}
+
+	return pass
+}
+
+// setSample puts a candidate value for f into doc, spreading arrays
+// across wildcards and building arrays for aggregates
+func setSample(doc map[string]interface{}, f *Rule, c interface{}) {
+	parts := splitPath(f.Path)
+
+	if _, ok := c.(missingValue); ok {
+		deletePath(doc, parts)
+		return
+	}
+
+	switch f.Aggregate {
+	case "sum", "avg", "min", "max":
+		c = []interface{}{c}
+	case "count":
+		n, ok := toFloat(c)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return
+		}
+		items := make([]interface{}, int(n))
+		for i := range items {
+			items[i] = "sample"
+		}
+		c = items
+	}
+
+	placeValue(doc, parts, c)
+}
+
+// placeValue sets v at parts under cur, creating objects (and arrays,
+// for wildcards) as needed, and returns the updated cur
+func placeValue(cur interface{}, parts []string, v interface{}) interface{} {
+	if len(parts) == 0 {
+		return v
+	}
+
+	if parts[0] == "*" {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+
+		existing, _ := cur.([]interface{})
+		out := make([]interface{}, len(values))
+		for i, x := range values {
+			var prev interface{}
+			if i < len(existing) {
+				prev = existing[i]
+			}
+			out[i] = placeValue(prev, parts[1:], x)
+		}
+		return out
+	}
+
+	m, ok := cur.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	m[parts[0]] = placeValue(m[parts[0]], parts[1:], v)
+
+	return m
+}
+
+func deletePath(doc map[string]interface{}, parts []string) {
+	m := doc
+	for i, part := range parts {
+		if i == len(parts)-1 {
+			delete(m, part)
+			return
+		}
+
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+}
+
+// replaceDoc swaps the contents of doc with the contents of with
+func replaceDoc(doc, with map[string]interface{}) {
+	for k := range doc {
+		delete(doc, k)
+	}
+	for k, v := range with {
+		doc[k] = v
+	}
+}
+
+// others are values that aren't v, but are probably the same type
+func others(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case string:
+		return []interface{}{t + "-other", ""}
+	case bool:
+		return []interface{}{!t}
+	case nil:
+		return []interface{}{"sample"}
+	}
+
+	if n, ok := toFloat(v); ok {
+		return []interface{}{sameKind(v, n+1), sameKind(v, n-1)}
+	}
+
+	return []interface{}{"sample", map[string]interface{}{}}
+}
+
+// nearby are values around v, for inequalities
+func nearby(v interface{}) []interface{} {
+	if str, ok := v.(string); ok {
+		// strings sort byte by byte, so one just after and one just before
+		out := []interface{}{str, str + "a", ""}
+		if str != "" {
+			out = append(out, str[:len(str)-1])
+		}
+		// with WithNumericStrings they're compared as numbers instead
+		if n, err := strconv.ParseFloat(str, 64); err == nil {
+			for _, d := range []float64{1, -1, 0.5, -0.5} {
+				out = append(out, strconv.FormatFloat(n+d, 'f', -1, 64))
+			}
+		}
+		return out
+	}
+
+	n, ok := toFloat(v)
+	if !ok {
+		return []interface{}{v}
+	}
+
+	var out []interface{}
+	for _, d := range []float64{0, 1, -1, 0.5, -0.5} {
+		out = append(out, sameKind(v, n+d))
+	}
+
+	return out
+}
+
+// sameKind converts n to the same numeric type as like,
+// since inequalities only compare values of the same type
+func sameKind(like interface{}, n float64) interface{} {
+	t := reflect.TypeOf(like)
+	if t == nil {
+		return n
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(math.Round(n)).Convert(t).Interface()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(math.Max(0, math.Round(n))).Convert(t).Interface()
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(n).Convert(t).Interface()
+	}
+
+	return n
+}
+
+// regexSamples returns strings that match and don't match the pattern in v
+func regexSamples(v interface{}) (matching, nonMatching []interface{}) {
+	streg, ok := v.(string)
+	if !ok {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(streg)
+	if err != nil {
+		return nil, nil
+	}
+
+	if parsed, err := syntax.Parse(streg, syntax.Perl); err == nil {
+		if str, ok := regexString(parsed.Simplify()); ok && re.MatchString(str) {
+			matching = append(matching, str)
+		}
+	}
+
+	for _, str := range []string{"", "x", "0", "zzzz", "sample-" + strings.Repeat("q", 8)} {
+		if !re.MatchString(str) {
+			nonMatching = append(nonMatching, str)
+		}
+	}
+
+	return matching, nonMatching
+}
+
+// regexString builds about the simplest string a regex can match
+func regexString(re *syntax.Regexp) (string, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune), true
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return "", false
+		}
+		return string(re.Rune[0]), true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return "a", true
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary,
+		syntax.OpStar, syntax.OpQuest:
+		return "", true
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexString(re.Sub[0])
+	case syntax.OpRepeat:
+		sub, ok := regexString(re.Sub[0])
+		return strings.Repeat(sub, re.Min), ok
+	case syntax.OpConcat:
+		var b strings.Builder
+		for _, sub := range re.Sub {
+			str, ok := regexString(sub)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(str)
+		}
+		return b.String(), true
+	case syntax.OpAlternate:
+		return regexString(re.Sub[0])
+	}
+
+	return "", false
+}
+
+// rolloutSamples finds a key inside and a key outside a percent rollout
+func rolloutSamples(v interface{}) (in, out []interface{}) {
+	for i := 0; i < 10000 && (in == nil || out == nil); i++ {
+		key := float64(i)
+		ok, err := inRollout(key, v)
+		if err != nil {
+			return nil, nil
+		}
+		if ok && in == nil {
+			in = []interface{}{key}
+		} else if !ok && out == nil {
+			out = []interface{}{key}
+		}
+	}
+
+	return in, out
+}
@@ -0,0 +1,87 @@
+package ruler
+
+import "context"
+
+// Breakdown explains a score from Ruler's Score function
+type Breakdown struct {
+	// Total is what the score would be if every rule passed
+	Total float64
+	// Passed are the rules that added their weight to the score
+	Passed []*Rule
+	// Failed are the rules that didn't
+	Failed []*Rule
+}
+
+// Score tests the document against every top-level rule, without stopping
+// at the first one that fails, and adds up the weights of the rules that pass
+func (r *Ruler) Score(o map[string]interface{}) (float64, *Breakdown, error) {
+	c, err := r.Compile()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return c.Score(o)
+}
+
+// TestScore reports whether the document's Score reaches threshold
+func (r *Ruler) TestScore(o map[string]interface{}, threshold float64) (bool, error) {
+	score, _, err := r.Score(o)
+	if err != nil {
+		return false, err
+	}
+
+	return score >= threshold, nil
+}
+
+// Score is the compiled version of Ruler's Score
+func (c *CompiledRuler) Score(o map[string]interface{}) (float64, *Breakdown, error) {
+	e := c.ruler.newEvaluation(context.Background(), o, nil)
+	defer e.release()
+
+	var score float64
+	b := &Breakdown{}
+	for _, f := range c.rules {
+		if err := e.ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		if !f.active(e) {
+			continue
+		}
+
+		w := weight(f.Rule)
+		b.Total += w
+
+		ok, err := c.ruler.testRule(e, f)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if ok {
+			score += w
+			b.Passed = append(b.Passed, f.Rule)
+		} else {
+			b.Failed = append(b.Failed, f.Rule)
+		}
+	}
+
+	return score, b, nil
+}
+
+// TestScore is the compiled version of Ruler's TestScore
+func (c *CompiledRuler) TestScore(o map[string]interface{}, threshold float64) (bool, error) {
+	score, _, err := c.Score(o)
+	if err != nil {
+		return false, err
+	}
+
+	return score >= threshold, nil
+}
+
+// weight is what a rule adds to a score, 1 unless it says otherwise
+func weight(f *Rule) float64 {
+	if f.Weight == 0 {
+		return 1
+	}
+
+	return f.Weight
+}
@@ -0,0 +1,104 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// A SecretResolver looks up the value behind a `{"$secret": "name"}` rule value,
+// from the environment, Vault, a KMS or wherever secrets are kept,
+// so sensitive values never have to be written into rule JSON
+type SecretResolver interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretFunc lets a plain function be a SecretResolver
+type SecretFunc func(ctx context.Context, name string) (string, error)
+
+// Secret calls fn
+func (fn SecretFunc) Secret(ctx context.Context, name string) (string, error) {
+	return fn(ctx, name)
+}
+
+// EnvSecrets resolves secrets from environment variables of the same name
+var EnvSecrets SecretResolver = SecretFunc(func(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("no environment variable %s", name)
+	}
+
+	return v, nil
+})
+
+// WithSecrets resolves secrets in rule values with res
+// they're looked up every time a rule uses one, which keeps up with rotation;
+// see ResolveSecrets to look them all up once instead
+func WithSecrets(res SecretResolver) Option {
+	return func(r *Ruler) {
+		r.secrets = res
+	}
+}
+
+// ResolveSecrets returns a copy of the Ruler with every secret
+// in its rules swapped for its value, looked up now with the Ruler's SecretResolver
+// the values only live in memory, in the copy
+func (r *Ruler) ResolveSecrets(ctx context.Context) (*Ruler, error) {
+	c := r.Clone()
+	if err := r.resolveSecrets(ctx, c.rules); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *Ruler) resolveSecrets(ctx context.Context, rules []*Rule) error {
+	for _, f := range rules {
+		if err := r.resolveSecrets(ctx, f.All); err != nil {
+			return err
+		}
+		if err := r.resolveSecrets(ctx, f.Any); err != nil {
+			return err
+		}
+		if f.Not != nil {
+			if err := r.resolveSecrets(ctx, []*Rule{f.Not}); err != nil {
+				return err
+			}
+		}
+
+		if name, ok := secretName(f.Value); ok {
+			v, err := r.secret(ctx, name)
+			if err != nil {
+				return err
+			}
+			f.Value = v
+		}
+	}
+
+	return nil
+}
+
+// secret looks up one secret with the Ruler's SecretResolver
+func (r *Ruler) secret(ctx context.Context, name string) (string, error) {
+	if r.secrets == nil {
+		return "", fmt.Errorf("no secret resolver for secret (%s)", name)
+	}
+
+	v, err := r.secrets.Secret(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("resolving secret (%s): %w", name, err)
+	}
+
+	return v, nil
+}
+
+// secretName returns the secret's name if v is a `{"$secret": "name"}` placeholder
+func secretName(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+
+	name, ok := m["$secret"].(string)
+	return name, ok
+}
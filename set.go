@@ -0,0 +1,95 @@
+package ruler
+
+import "sort"
+
+// RulerSet holds a bunch of named Rulers, each with a priority,
+// so you can ask which of them a document matches
+type RulerSet struct {
+	entries []*rulerSetEntry
+}
+
+type rulerSetEntry struct {
+	name     string
+	priority int
+	ruler    *Ruler
+}
+
+// NewRulerSet creates a new, empty RulerSet
+func NewRulerSet() *RulerSet {
+	return &RulerSet{}
+}
+
+// Add adds a named Ruler to the set, replacing any Ruler already using that name
+// Rulers with a higher priority are tested first; Rulers with the same
+// priority are tested in the order they were added
+func (s *RulerSet) Add(name string, priority int, r *Ruler) {
+	s.Remove(name)
+
+	s.entries = append(s.entries, &rulerSetEntry{name, priority, r})
+	sort.SliceStable(s.entries, func(i, j int) bool {
+		return s.entries[i].priority > s.entries[j].priority
+	})
+}
+
+// Remove takes the named Ruler out of the set, if it's there
+func (s *RulerSet) Remove(name string) {
+	for i, e := range s.entries {
+		if e.name == name {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get returns the named Ruler, or nil if there isn't one
+func (s *RulerSet) Get(name string) *Ruler {
+	for _, e := range s.entries {
+		if e.name == name {
+			return e.ruler
+		}
+	}
+
+	return nil
+}
+
+// Names returns the names of every Ruler in the set, in priority order
+func (s *RulerSet) Names() []string {
+	names := make([]string, len(s.entries))
+	for i, e := range s.entries {
+		names[i] = e.name
+	}
+
+	return names
+}
+
+// Match returns the name of the highest priority Ruler that the document matches
+// matched is false if none of them do
+func (s *RulerSet) Match(o map[string]interface{}) (name string, matched bool, err error) {
+	for _, e := range s.entries {
+		ok, err := e.ruler.Test(o)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			return e.name, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// MatchAll returns the names of every Ruler the document matches, in priority order
+func (s *RulerSet) MatchAll(o map[string]interface{}) ([]string, error) {
+	var names []string
+	for _, e := range s.entries {
+		ok, err := e.ruler.Test(o)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			names = append(names, e.name)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,126 @@
+package ruler
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Divergence is a document the active and candidate rules disagreed on
+type Divergence struct {
+	Document map[string]interface{}
+
+	ActiveMatched bool
+	ActiveFailed  *Rule
+	ActiveErr     error
+	// ActiveVersion and CandidateVersion are the versions of the two rulesets, if they have them
+	ActiveVersion string
+
+	CandidateMatched bool
+	CandidateFailed  *Rule
+	CandidateErr     error
+	CandidateVersion string
+}
+
+// ShadowRuler tests documents against its active rules, and a candidate
+// set of rules on the side, so new rules can be checked against real traffic
+// before they're switched on; only the active rules decide anything
+type ShadowRuler struct {
+	active    *CompiledRuler
+	candidate *CompiledRuler
+	diverged  func(Divergence)
+
+	evaluations atomic.Int64
+	divergences atomic.Int64
+}
+
+var _ Tester = (*ShadowRuler)(nil)
+
+// ShadowCounts is how often a ShadowRuler's rules have disagreed
+type ShadowCounts struct {
+	Evaluations int64
+	Divergences int64
+}
+
+// Shadow tests candidate alongside r, calling diverged (if it isn't nil) for every
+// document they don't agree on, either on whether it matched or on whether it errored
+// the candidate is tested after r, on the same goroutine, so diverged should be quick
+// only the active rules have side effects: the candidate isn't audited, counted
+// in coverage or instrumentation, and its threshold rules count in memory
+// rather than in the StateStore, which would count every document twice
+func (r *Ruler) Shadow(candidate *Ruler, diverged func(Divergence)) (*ShadowRuler, error) {
+	active, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+	shadow, err := candidate.withoutSideEffects().Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShadowRuler{active: active, candidate: shadow, diverged: diverged}, nil
+}
+
+// Test tests a document against the active rules, and the candidate ones on the side
+func (s *ShadowRuler) Test(o map[string]interface{}) (bool, error) {
+	return s.TestContext(context.Background(), o)
+}
+
+// TestContext is Test with a context, which both sets of rules are tested under
+func (s *ShadowRuler) TestContext(ctx context.Context, o map[string]interface{}) (bool, error) {
+	res := s.EvaluateContext(ctx, o)
+	defer res.Release()
+
+	return res.Matched, res.Err
+}
+
+// Evaluate tests a document like Test, returning the active rules' Result
+func (s *ShadowRuler) Evaluate(o map[string]interface{}) *Result {
+	return s.EvaluateContext(context.Background(), o)
+}
+
+// EvaluateContext is Evaluate with a context
+func (s *ShadowRuler) EvaluateContext(ctx context.Context, o map[string]interface{}) *Result {
+	res := s.active.EvaluateContext(ctx, o)
+	cand := s.candidate.EvaluateContext(ctx, o)
+	defer cand.Release()
+
+	s.evaluations.Add(1)
+	if res.Matched == cand.Matched && (res.Err == nil) == (cand.Err == nil) {
+		return res
+	}
+
+	s.divergences.Add(1)
+	if s.diverged != nil {
+		s.diverged(Divergence{
+			Document:         o,
+			ActiveMatched:    res.Matched,
+			ActiveFailed:     res.Failed,
+			ActiveErr:        res.Err,
+			ActiveVersion:    res.Version,
+			CandidateMatched: cand.Matched,
+			CandidateFailed:  cand.Failed,
+			CandidateErr:     cand.Err,
+			CandidateVersion: cand.Version,
+		})
+	}
+
+	return res
+}
+
+// Counts returns how many documents have been tested, and how many the rules disagreed on
+func (s *ShadowRuler) Counts() ShadowCounts {
+	return ShadowCounts{
+		Evaluations: s.evaluations.Load(),
+		Divergences: s.divergences.Load(),
+	}
+}
+
+// Active is the compiled active rules
+func (s *ShadowRuler) Active() *CompiledRuler {
+	return s.active
+}
+
+// Candidate is the compiled candidate rules, to promote once they've proven themselves
+func (s *ShadowRuler) Candidate() *CompiledRuler {
+	return s.candidate
+}
@@ -0,0 +1,204 @@
+package ruler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SQLDialect is the flavour of SQL ToSQL renders
+type SQLDialect int
+
+const (
+	// Postgres uses $1 placeholders, "quoted" identifiers and ~ for regexes
+	Postgres SQLDialect = iota
+	// MySQL uses ? placeholders, `quoted` identifiers and REGEXP for regexes
+	MySQL
+	// SQLite uses ? placeholders and "quoted" identifiers,
+	// it has no regex operator so regex comparators can't be rendered
+	SQLite
+)
+
+// the SQL operator for each comparator that has a straight equivalent
+var sqlOps = map[string]string{
+	"eq": "=", "neq": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+}
+
+// ToSQL renders the Ruler's rules as a parameterized WHERE clause
+// (without the WHERE) and the arguments for its placeholders
+// a path becomes a column, with dots separating the table, e.g. "users"."age"
+// comparators without a SQL version, aggregates, wildcards and parameters
+// are errors
+// SQL's null handling and regex syntax aren't quite the same as ours, so treat
+// the clause as a pre-filter and test the rows it returns with the Ruler as well
+func (r *Ruler) ToSQL(dialect SQLDialect) (string, []interface{}, error) {
+	s := &sqlWriter{dialect: dialect}
+	clause, err := s.group(r.rules, " AND ", "1 = 1")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return clause, s.args, nil
+}
+
+// sqlWriter keeps track of the arguments as the clause is rendered,
+// since Postgres numbers its placeholders
+type sqlWriter struct {
+	dialect SQLDialect
+	args    []interface{}
+}
+
+func (s *sqlWriter) group(rules []*Rule, sep, empty string) (string, error) {
+	if len(rules) == 0 {
+		return empty, nil
+	}
+
+	parts := make([]string, len(rules))
+	for i, f := range rules {
+		part, err := s.rule(f)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+func (s *sqlWriter) rule(f *Rule) (string, error) {
+	if f.Scheduled() {
+		return "", fmt.Errorf("sql: can't convert the activation window on (%s)", f.Path)
+	}
+	if f.Ruleset != "" {
+		return "", fmt.Errorf("sql: can't convert the reference to ruleset %s", f.Ruleset)
+	}
+
+	switch {
+	case f.All != nil:
+		return s.group(f.All, " AND ", "1 = 1")
+	case f.Any != nil:
+		return s.group(f.Any, " OR ", "1 = 0")
+	case f.Not != nil:
+		clause, err := s.rule(f.Not)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + clause + ")", nil
+	}
+
+	if f.Aggregate != "" {
+		return "", fmt.Errorf("sql: can't convert the %s aggregate on (%s)", f.Aggregate, f.Path)
+	}
+	if _, ok := paramName(f.Value); ok {
+		return "", fmt.Errorf("sql: can't convert the parameter on (%s)", f.Path)
+	}
+	if _, ok := secretName(f.Value); ok {
+		return "", fmt.Errorf("sql: can't convert the secret on (%s)", f.Path)
+	}
+	if len(f.Transforms) > 0 {
+		return "", fmt.Errorf("sql: can't convert the transforms on (%s)", f.Path)
+	}
+	if f.Type != "" {
+		return "", fmt.Errorf("sql: can't convert the %s type on (%s)", f.Type, f.Path)
+	}
+	if hasPathFunctions(f) {
+		return "", fmt.Errorf("sql: can't convert the functions in the path (%s)", f.Path)
+	}
+	if readsContext(f) {
+		return "", fmt.Errorf("sql: can't convert the context variable on (%s)", f.Path)
+	}
+
+	column, err := s.column(f.Path)
+	if err != nil {
+		return "", err
+	}
+
+	switch f.Comparator {
+	case "exists":
+		return column + " IS NOT NULL", nil
+	case "nexists":
+		return column + " IS NULL", nil
+	}
+
+	var value string
+	if f.ValuePath != "" {
+		if value, err = s.column(f.ValuePath); err != nil {
+			return "", err
+		}
+	} else if f.Value == nil {
+		switch f.Comparator {
+		case "eq":
+			return column + " IS NULL", nil
+		case "neq":
+			return column + " IS NOT NULL", nil
+		}
+	}
+
+	var op string
+	switch f.Comparator {
+	case "regex", "matches", "contains", "ncontains":
+		if op, err = s.regexOp(f.Comparator == "ncontains"); err != nil {
+			return "", fmt.Errorf("%w on (%s)", err, f.Path)
+		}
+	default:
+		var ok bool
+		if op, ok = sqlOps[f.Comparator]; !ok {
+			return "", fmt.Errorf("sql: can't convert comparator %s on (%s)", f.Comparator, f.Path)
+		}
+	}
+
+	if value == "" {
+		value = s.arg(f.Value)
+	}
+
+	return column + " " + op + " " + value, nil
+}
+
+func (s *sqlWriter) regexOp(negate bool) (string, error) {
+	switch s.dialect {
+	case Postgres:
+		if negate {
+			return "!~", nil
+		}
+		return "~", nil
+	case MySQL:
+		if negate {
+			return "NOT REGEXP", nil
+		}
+		return "REGEXP", nil
+	}
+
+	return "", errors.New("sql: dialect has no regex operator")
+}
+
+// column quotes each part of a path as an identifier
+func (s *sqlWriter) column(path string) (string, error) {
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		if p == "" || p == "*" || strings.Contains(p, "[*]") {
+			return "", fmt.Errorf("sql: can't convert path (%s) to a column", path)
+		}
+
+		if s.dialect == MySQL {
+			parts[i] = "`" + strings.ReplaceAll(p, "`", "``") + "`"
+		} else {
+			parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+		}
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// arg adds an argument and returns its placeholder
+func (s *sqlWriter) arg(v interface{}) string {
+	s.args = append(s.args, v)
+	if s.dialect == Postgres {
+		return fmt.Sprintf("$%d", len(s.args))
+	}
+
+	return "?"
+}
@@ -0,0 +1,222 @@
+package ruler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StateStore keeps what stateful rules remember between documents,
+// like how many failed logins an IP has had lately, or which sessions a Correlation is waiting on
+// NewMemoryState keeps it in memory, rulerredis has one that shares it between machines
+type StateStore interface {
+	// Count records an event for key at at, and returns how many events key
+	// has had in the window that ends at at, this one included
+	Count(ctx context.Context, key string, at time.Time, window time.Duration) (int64, error)
+	// Arm remembers key from at until ttl later, starting over if it's already armed
+	Arm(ctx context.Context, key string, at time.Time, ttl time.Duration) error
+	// Disarm forgets key, and reports whether it was still armed at at
+	Disarm(ctx context.Context, key string, at time.Time) (bool, error)
+}
+
+// WithState sets where threshold rules keep their counts
+func WithState(store StateStore) Option {
+	return func(r *Ruler) {
+		r.state = store
+	}
+}
+
+// threshold is a threshold rule's value worked out, see Ruler.threshold
+type threshold struct {
+	counter string
+	count   int64
+	within  time.Duration
+}
+
+// parseThreshold reads a threshold rule's value, an object like
+//
+//	{"counter": "failed_logins", "count": 5, "within": "10m"}
+func parseThreshold(v interface{}) (*threshold, error) {
+	m, ok := asMap(v)
+	if !ok {
+		return nil, errors.New("threshold not actually an object, bailing")
+	}
+
+	t := &threshold{}
+	if t.counter, ok = m["counter"].(string); !ok || t.counter == "" {
+		return nil, errors.New("threshold counter not actually a string, bailing")
+	}
+	if t.count, ok = toInt(m["count"]); !ok || t.count < 0 {
+		return nil, errors.New("threshold count not actually a whole number, bailing")
+	}
+	within, ok := m["within"].(string)
+	if !ok {
+		return nil, errors.New("threshold window not actually a string, bailing")
+	}
+	var err error
+	if t.within, err = time.ParseDuration(within); err != nil {
+		return nil, err
+	}
+	if t.within <= 0 {
+		return nil, errors.New("threshold window has to be longer than 0, bailing")
+	}
+
+	return t, nil
+}
+
+// compileThreshold checks a threshold rule's value, and that there's somewhere to count
+func (r *Ruler) compileThreshold(f *Rule) (*threshold, error) {
+	if r.state == nil {
+		return nil, fmt.Errorf("threshold rule on (%s) needs a StateStore, see WithState", f.Path)
+	}
+	if _, ok := paramName(f.Value); ok {
+		// read when it's used
+		return nil, nil
+	}
+
+	t, err := parseThreshold(f.Value)
+	if err != nil {
+		return nil, fmt.Errorf("threshold on (%s): %w", f.Path, err)
+	}
+
+	return t, nil
+}
+
+// threshold runs a threshold rule: every document it's tested against counts
+// as an event for the property's value, and it passes once that value's had
+// more than count events within the window, so
+//
+//	{"comparator": "threshold", "path": "ip", "value": {"counter": "failed_logins", "count": 5, "within": "10m"}}
+//
+// passes from the sixth failed login from the same IP in 10 minutes
+// the time comes from the Ruler's clock, see WithClock
+func (r *Ruler) threshold(e *evaluation, f *compiledRule, actual, expected interface{}) (bool, error) {
+	if r.state == nil {
+		return false, errors.New("no StateStore for threshold rules, bailing")
+	}
+
+	t := f.thresh
+	if t == nil {
+		var err error
+		if t, err = parseThreshold(expected); err != nil {
+			return false, err
+		}
+	}
+
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	n, err := r.state.Count(ctx, stateKey(t.counter, actual), e.now(), t.within)
+	if err != nil {
+		return false, fmt.Errorf("threshold %s on (%s): %w", t.counter, f.Path, err)
+	}
+
+	return n > t.count, nil
+}
+
+// stateKey is what a stateful rule keeps its state about a value under
+func stateKey(name string, v interface{}) string {
+	return name + ":" + fmt.Sprint(v)
+}
+
+// MemoryState is a StateStore for a single process
+type MemoryState struct {
+	mu     sync.Mutex
+	events map[string]*stateEvents
+	// armed is when each armed key expires
+	armed map[string]time.Time
+	// calls since the last sweep for keys that have gone quiet
+	calls int
+}
+
+type stateEvents struct {
+	times  []time.Time
+	window time.Duration
+}
+
+var _ StateStore = (*MemoryState)(nil)
+
+// how many calls MemoryState takes between sweeps
+const stateSweepEvery = 1024
+
+// NewMemoryState makes an empty MemoryState
+func NewMemoryState() *MemoryState {
+	return &MemoryState{events: make(map[string]*stateEvents), armed: make(map[string]time.Time)}
+}
+
+// Count records an event for key at at, see StateStore
+func (s *MemoryState) Count(ctx context.Context, key string, at time.Time, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calls++; s.calls >= stateSweepEvery {
+		s.sweep(at)
+		s.calls = 0
+	}
+
+	ev := s.events[key]
+	if ev == nil {
+		ev = &stateEvents{}
+		s.events[key] = ev
+	}
+	ev.window = window
+	ev.times = append(ev.times, at)
+	ev.prune(at)
+
+	return int64(len(ev.times)), nil
+}
+
+// prune drops the events that are older than the window ending at at
+func (ev *stateEvents) prune(at time.Time) {
+	start := at.Add(-ev.window)
+	kept := ev.times[:0]
+	for _, t := range ev.times {
+		if t.After(start) {
+			kept = append(kept, t)
+		}
+	}
+	ev.times = kept
+}
+
+// Arm remembers key until ttl after at, see StateStore
+func (s *MemoryState) Arm(ctx context.Context, key string, at time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calls++; s.calls >= stateSweepEvery {
+		s.sweep(at)
+		s.calls = 0
+	}
+	s.armed[key] = at.Add(ttl)
+
+	return nil
+}
+
+// Disarm forgets key, see StateStore
+func (s *MemoryState) Disarm(ctx context.Context, key string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.armed[key]
+	delete(s.armed, key)
+
+	return ok && at.Before(expires), nil
+}
+
+// sweep forgets keys that haven't had an event within their window,
+// and armed keys that have expired
+func (s *MemoryState) sweep(at time.Time) {
+	for key, ev := range s.events {
+		if ev.prune(at); len(ev.times) == 0 {
+			delete(s.events, key)
+		}
+	}
+	for key, expires := range s.armed {
+		if !at.Before(expires) {
+			delete(s.armed, key)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a RuleStore that has nothing saved under a name
+var ErrNotFound = errors.New("ruler: no rules with that name")
+
+// A RuleStore keeps rule JSON by name, wherever it's kept:
+// in memory, on disk, or in a database with a store of its own
+// LoadRuler and SaveRuler go between a store and a Ruler
+type RuleStore interface {
+	// Load returns the rules saved as name, or ErrNotFound
+	Load(ctx context.Context, name string) ([]byte, error)
+	// Save stores rules as name, replacing whatever was there
+	Save(ctx context.Context, name string, rules []byte) error
+	// List returns the names of all the saved rules, sorted
+	List(ctx context.Context) ([]string, error)
+	// Watch sends name's rules every time they change, until ctx is done,
+	// when the channel is closed
+	Watch(ctx context.Context, name string) (<-chan []byte, error)
+}
+
+// LoadRuler loads the rules saved as name into a new Ruler, as NewRulerWithJSON would
+func LoadRuler(ctx context.Context, store RuleStore, name string, opts ...Option) (*Ruler, error) {
+	data, err := store.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewRulerWithJSON(data, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading rules (%s): %w", name, err)
+	}
+
+	return r, nil
+}
+
+// SaveRuler saves the Ruler's rules as name
+func SaveRuler(ctx context.Context, store RuleStore, name string, r *Ruler) error {
+	data, err := r.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	return store.Save(ctx, name, data)
+}
+
+// MarshalJSON encodes the Ruler's rules the way NewRulerWithJSON reads them,
+// as a versioned ruleset if they have a Version
+func (r *Ruler) MarshalJSON() ([]byte, error) {
+	rules := r.rules
+	if rules == nil {
+		rules = []*Rule{}
+	}
+
+	if r.version == "" {
+		return json.Marshal(rules)
+	}
+
+	return json.Marshal(ruleset{Version: r.version, Rules: rules})
+}
+
+// MemoryStore is a RuleStore held in memory, good for tests
+// and for rules that don't need to outlive the process
+type MemoryStore struct {
+	mu       sync.Mutex
+	rules    map[string][]byte
+	watchers map[string][]chan []byte
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rules:    make(map[string][]byte),
+		watchers: make(map[string][]chan []byte),
+	}
+}
+
+// Load returns the rules saved as name
+func (s *MemoryStore) Load(ctx context.Context, name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.rules[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+// Save stores rules as name, and sends them to anyone watching it
+func (s *MemoryStore) Save(ctx context.Context, name string, rules []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules[name] = append([]byte(nil), rules...)
+	for _, ch := range s.watchers[name] {
+		// a watcher that's behind only needs the latest rules
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- append([]byte(nil), rules...)
+	}
+
+	return nil
+}
+
+// List returns the names of all the saved rules
+func (s *MemoryStore) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.rules))
+	for name := range s.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Watch sends name's rules every time they're saved
+func (s *MemoryStore) Watch(ctx context.Context, name string) (<-chan []byte, error) {
+	ch := make(chan []byte, 1)
+
+	s.mu.Lock()
+	s.watchers[name] = append(s.watchers[name], ch)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		watchers := s.watchers[name]
+		for i, w := range watchers {
+			if w == ch {
+				s.watchers[name] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// FileStore is a RuleStore keeping each set of rules in a JSON file,
+// named after them, in one directory
+type FileStore struct {
+	dir string
+	// poll is how often Watch checks a file for changes
+	poll time.Duration
+}
+
+// NewFileStore stores rules in dir, which has to exist already
+// Watch checks files for changes every poll, or every second if poll is zero
+func NewFileStore(dir string, poll time.Duration) *FileStore {
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	return &FileStore{dir: dir, poll: poll}
+}
+
+// path is where the rules saved as name live
+func (s *FileStore) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("ruler: %q can't be used as the name of a file", name)
+	}
+
+	return filepath.Join(s.dir, name+".json"), nil
+}
+
+// Load reads the rules saved as name
+func (s *FileStore) Load(ctx context.Context, name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+// Save writes rules as name, through a temporary file so that
+// nothing ever reads half of them
+func (s *FileStore) Save(ctx context.Context, name string, rules []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(rules); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the names of the rules in the directory
+func (s *FileStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Watch checks name's file for changes and sends its rules when they do
+func (s *FileStore) Watch(ctx context.Context, name string) (<-chan []byte, error) {
+	if _, err := s.path(name); err != nil {
+		return nil, err
+	}
+
+	// what's there now isn't a change
+	last, err := s.Load(ctx, name)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(s.poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := s.Load(ctx, name)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+
+			select {
+			case ch <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
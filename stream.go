@@ -0,0 +1,102 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// A Codec turns a message's payload into a document
+type Codec interface {
+	Decode(payload []byte) (map[string]interface{}, error)
+}
+
+// JSONCodec decodes payloads that are JSON objects
+type JSONCodec struct{}
+
+// Decode unmarshals payload
+func (JSONCodec) Decode(payload []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// MessageHandler handles one message from a queue or stream,
+// which is the shape most Kafka (or NATS, or SQS) consumers call
+type MessageHandler func(ctx context.Context, payload []byte) error
+
+// MessageFilter sits in front of a MessageHandler and only passes it the
+// messages that match the rules, counting what it lets through and what it doesn't
+type MessageFilter struct {
+	c     *CompiledRuler
+	next  MessageHandler
+	codec Codec
+
+	matched  atomic.Int64
+	filtered atomic.Int64
+	failed   atomic.Int64
+}
+
+// A FilterOption configures a MessageFilter
+type FilterOption func(*MessageFilter)
+
+// WithCodec decodes payloads with codec instead of as JSON
+func WithCodec(codec Codec) FilterOption {
+	return func(f *MessageFilter) {
+		f.codec = codec
+	}
+}
+
+// NewMessageFilter filters messages for next with c's rules
+func NewMessageFilter(c *CompiledRuler, next MessageHandler, opts ...FilterOption) *MessageFilter {
+	f := &MessageFilter{c: c, next: next, codec: JSONCodec{}}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Handle tests one message and hands it on if it matches
+// a message that can't be decoded or tested is an error, so the consumer
+// can retry it or send it somewhere else, rather than it quietly disappearing
+func (f *MessageFilter) Handle(ctx context.Context, payload []byte) error {
+	doc, err := f.codec.Decode(payload)
+	if err != nil {
+		f.failed.Add(1)
+		return fmt.Errorf("decoding message: %w", err)
+	}
+
+	ok, err := f.c.TestContext(ctx, doc)
+	if err != nil {
+		f.failed.Add(1)
+		return fmt.Errorf("testing message: %w", err)
+	}
+	if !ok {
+		f.filtered.Add(1)
+		return nil
+	}
+
+	f.matched.Add(1)
+	return f.next(ctx, payload)
+}
+
+// FilterCounts are how many messages a MessageFilter has seen, by what happened to them
+type FilterCounts struct {
+	Matched  int64
+	Filtered int64
+	Failed   int64
+}
+
+// Counts returns the counts so far
+func (f *MessageFilter) Counts() FilterCounts {
+	return FilterCounts{
+		Matched:  f.matched.Load(),
+		Filtered: f.filtered.Load(),
+		Failed:   f.failed.Load(),
+	}
+}
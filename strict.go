@@ -0,0 +1,423 @@
+package ruler
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// JSONSchema returns the JSON Schema (draft 2020-12) for the rules
+// NewRulerWithJSON loads, for checking rules in editors and CI
+func JSONSchema() []byte {
+	return append([]byte(nil), schema...)
+}
+
+// WithStrict makes NewRulerWithJSON reject unknown fields, unknown comparators and
+// aggregates, values of the wrong type for their comparator, bad regexes and
+// malformed groups as the rules are loaded, instead of when they're tested
+// errors are a *StrictError saying where in the JSON the problem is
+func WithStrict() Option {
+	return func(r *Ruler) {
+		r.strict = true
+	}
+}
+
+// StrictError is a problem WithStrict found in some rules
+type StrictError struct {
+	// Offset is the byte offset into the JSON, Line and Column start at 1
+	Offset int64
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("ruler: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// the kinds of value each comparator expects, anything not listed takes any value
+var strictValueKinds = map[string]string{
+	"gt": "number or string", "gte": "number or string",
+	"lt": "number or string", "lte": "number or string",
+	"regex": "string", "matches": "string", "contains": "string", "ncontains": "string",
+	"subset": "array", "superset": "array", "intersects": "array",
+	"supermap": "object", "haskey": "string or array", "percent": "number or object",
+	"approx_eq": "number or object",
+	"mime_type": "string or array", "extension": "string or array",
+	"bitand_any": "number or string", "bitand_all": "number or string",
+	"mod": "number or object", "lookup": "string",
+	"business_hours": "string or object", "threshold": "object",
+	"regex_extract": "string", "soundex": "string", "metaphone": "string",
+}
+
+var knownAggregates = map[string]bool{
+	"sum": true, "avg": true, "min": true, "max": true, "count": true,
+}
+
+// strictParser reads rules a token at a time, so it knows where each one is
+type strictParser struct {
+	ruler *Ruler
+	data  []byte
+	dec   *json.Decoder
+}
+
+func (r *Ruler) parseStrict(data []byte) error {
+	p := &strictParser{ruler: r, data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+
+	var err error
+	var defs map[string]*Rule
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		r.version, r.rules, defs, err = p.ruleset()
+	} else {
+		r.rules, err = p.rules()
+	}
+	if err != nil {
+		return err
+	}
+	if r.rules, err = expandRefs(r.rules, defs); err != nil {
+		return err
+	}
+
+	if _, err := p.dec.Token(); err != io.EOF {
+		return p.errorAt(p.dec.InputOffset(), "unexpected data after the rules")
+	}
+
+	return nil
+}
+
+// ruleset reads the versioned form of rule JSON, see NewRulerWithJSON
+func (p *strictParser) ruleset() (string, []*Rule, map[string]*Rule, error) {
+	start := p.dec.InputOffset()
+	if _, err := p.delim('{', "a ruleset object"); err != nil {
+		return "", nil, nil, err
+	}
+
+	var version string
+	var rules []*Rule
+	var defs map[string]*Rule
+	for p.dec.More() {
+		keyOffset := p.dec.InputOffset()
+		tok, err := p.dec.Token()
+		if err != nil {
+			return "", nil, nil, p.wrap(keyOffset, err)
+		}
+
+		offset := p.dec.InputOffset()
+		switch key := tok.(string); key {
+		case "version":
+			err = p.dec.Decode(&version)
+		case "rules":
+			rules, err = p.rules()
+		case "definitions":
+			defs, err = p.definitions()
+		default:
+			return "", nil, nil, p.errorAt(keyOffset, "unknown field %q", key)
+		}
+		if err != nil {
+			if _, ok := err.(*StrictError); ok {
+				return "", nil, nil, err
+			}
+			return "", nil, nil, p.wrap(offset, err)
+		}
+	}
+
+	// the closing }
+	if _, err := p.dec.Token(); err != nil {
+		return "", nil, nil, p.wrap(p.dec.InputOffset(), err)
+	}
+	if rules == nil {
+		return "", nil, nil, p.errorAt(start, "ruleset has no rules")
+	}
+
+	return version, rules, defs, nil
+}
+
+// definitions reads a ruleset's named rules, see expandRefs
+func (p *strictParser) definitions() (map[string]*Rule, error) {
+	ok, err := p.delim('{', "an object of definitions")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	defs := make(map[string]*Rule)
+	for p.dec.More() {
+		keyOffset := p.dec.InputOffset()
+		tok, err := p.dec.Token()
+		if err != nil {
+			return nil, p.wrap(keyOffset, err)
+		}
+		f, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, p.errorAt(keyOffset, "definitions can't be null")
+		}
+		defs[tok.(string)] = f
+	}
+
+	// the closing }
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.wrap(p.dec.InputOffset(), err)
+	}
+
+	return defs, nil
+}
+
+// errorAt turns an offset into a StrictError,
+// skipping the whitespace and punctuation before the next token
+func (p *strictParser) errorAt(offset int64, format string, args ...interface{}) error {
+	for offset < int64(len(p.data)) && bytes.IndexByte([]byte(" \t\r\n,:"), p.data[offset]) >= 0 {
+		offset++
+	}
+
+	line, col := 1, 1
+	for _, c := range p.data[:offset] {
+		if c == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+
+	return &StrictError{Offset: offset, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// wrap points json's own errors at where they happened
+func (p *strictParser) wrap(offset int64, err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return p.errorAt(syntax.Offset, "%s", syntax.Error())
+	}
+	var typ *json.UnmarshalTypeError
+	if errors.As(err, &typ) {
+		return p.errorAt(offset, "expected a %s, got a %s", typ.Type, typ.Value)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return p.errorAt(int64(len(p.data)), "unexpected end of JSON")
+	}
+
+	return p.errorAt(offset, "%s", err.Error())
+}
+
+// delim reads the next token, which must be the opening delimiter want
+// a null is allowed too, and reported with ok false
+func (p *strictParser) delim(want json.Delim, what string) (ok bool, err error) {
+	offset := p.dec.InputOffset()
+	tok, err := p.dec.Token()
+	if err != nil {
+		return false, p.wrap(offset, err)
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if tok != want {
+		return false, p.errorAt(offset, "expected %s", what)
+	}
+
+	return true, nil
+}
+
+func (p *strictParser) rules() ([]*Rule, error) {
+	ok, err := p.delim('[', "an array of rules")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	rules := []*Rule{}
+	for p.dec.More() {
+		f, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, p.errorAt(p.dec.InputOffset(), "rules can't be null")
+		}
+		rules = append(rules, f)
+	}
+
+	// the closing ]
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.wrap(p.dec.InputOffset(), err)
+	}
+
+	return rules, nil
+}
+
+func (p *strictParser) rule() (*Rule, error) {
+	start := p.dec.InputOffset()
+	ok, err := p.delim('{', "a rule object")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	f := &Rule{}
+	var hasValue bool
+	for p.dec.More() {
+		keyOffset := p.dec.InputOffset()
+		tok, err := p.dec.Token()
+		if err != nil {
+			return nil, p.wrap(keyOffset, err)
+		}
+		key := tok.(string)
+
+		offset := p.dec.InputOffset()
+		switch key {
+		case "id":
+			err = p.dec.Decode(&f.ID)
+		case "comparator":
+			err = p.dec.Decode(&f.Comparator)
+			if name, ok := comparatorAliases[f.Comparator]; ok {
+				f.Comparator = name
+			}
+			if err == nil && !knownComparators[f.Comparator] {
+				return nil, p.errorAt(offset, "unknown comparator %q", f.Comparator)
+			}
+		case "path":
+			err = p.dec.Decode(&f.Path)
+		case "value":
+			hasValue = true
+			err = p.dec.Decode(&f.Value)
+		case "aggregate":
+			err = p.dec.Decode(&f.Aggregate)
+			if err == nil && f.Aggregate != "" && !knownAggregates[f.Aggregate] {
+				return nil, p.errorAt(offset, "unknown aggregate %q", f.Aggregate)
+			}
+		case "value_path":
+			err = p.dec.Decode(&f.ValuePath)
+		case "type":
+			err = p.dec.Decode(&f.Type)
+			if err == nil && f.Type != "" && !knownTypes[f.Type] {
+				return nil, p.errorAt(offset, "unknown type %q", f.Type)
+			}
+		case "transforms":
+			err = p.dec.Decode(&f.Transforms)
+			for _, name := range f.Transforms {
+				if _, ok := p.ruler.transform(name); err == nil && !ok {
+					return nil, p.errorAt(offset, "unknown transform %q", name)
+				}
+			}
+		case "all":
+			f.All, err = p.rules()
+		case "any":
+			f.Any, err = p.rules()
+		case "not":
+			f.Not, err = p.rule()
+		case "message":
+			err = p.dec.Decode(&f.Message)
+		case "outcome":
+			err = p.dec.Decode(&f.Outcome)
+		case "weight":
+			err = p.dec.Decode(&f.Weight)
+		case "active_from":
+			err = p.dec.Decode(&f.ActiveFrom)
+		case "active_until":
+			err = p.dec.Decode(&f.ActiveUntil)
+		case "ruleset":
+			err = p.dec.Decode(&f.Ruleset)
+		case "extract_to":
+			err = p.dec.Decode(&f.ExtractTo)
+		case "$ref":
+			err = p.dec.Decode(&f.Ref)
+		case "set":
+			err = p.dec.Decode(&f.Set)
+		case "schedule":
+			err = p.dec.Decode(&f.Schedule)
+			if err == nil && f.Schedule != "" {
+				if _, err := parseSchedule(f.Schedule); err != nil {
+					return nil, p.errorAt(offset, "%s", err.Error())
+				}
+			}
+		default:
+			return nil, p.errorAt(keyOffset, "unknown field %q", key)
+		}
+		if err != nil {
+			var strict *StrictError
+			if errors.As(err, &strict) {
+				return nil, err
+			}
+			return nil, p.wrap(offset, err)
+		}
+	}
+
+	// the closing }
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.wrap(p.dec.InputOffset(), err)
+	}
+
+	if err := p.check(f, hasValue); err != nil {
+		return nil, p.errorAt(start, "%s", err.Error())
+	}
+
+	return f, nil
+}
+
+// check makes sure a rule makes sense as a whole
+func (p *strictParser) check(f *Rule, hasValue bool) error {
+	if f.Ref != "" {
+		// checked once it's replaced with its definition
+		return nil
+	}
+	if f.Ruleset != "" || hasRef(f) {
+		// the ruleset may not be in the RulerSet until the rules are compiled,
+		// and refs aren't expanded until every rule's been read
+		return checkGroup(f)
+	}
+	if f.IsGroup() {
+		_, err := p.ruler.compileGroup(f)
+		return err
+	}
+
+	switch {
+	case f.Path == "":
+		return errors.New("rule has no path")
+	case f.Comparator == "":
+		return fmt.Errorf("rule on (%s) has no comparator", f.Path)
+	}
+
+	if _, ok := paramName(f.Value); ok || f.ValuePath != "" {
+		return nil
+	}
+	if _, ok := secretName(f.Value); ok {
+		return nil
+	}
+
+	kind, ok := strictValueKinds[f.Comparator]
+	if !ok {
+		return nil
+	}
+	if !hasValue {
+		return fmt.Errorf("%s on (%s) needs a value", f.Comparator, f.Path)
+	}
+
+	var good bool
+	switch f.Value.(type) {
+	case float64:
+		good = kind == "number or string" || kind == "number or object"
+	case string:
+		good = kind == "string" || kind == "number or string" || kind == "string or array" || kind == "string or object"
+	case []interface{}:
+		good = kind == "array" || kind == "string or array"
+	case map[string]interface{}:
+		good = kind == "object" || kind == "number or object" || kind == "string or object"
+	}
+	if !good {
+		return fmt.Errorf("%s on (%s) needs a %s value, got %v", f.Comparator, f.Path, kind, f.Value)
+	}
+
+	switch f.Comparator {
+	case "regex", "matches", "contains", "ncontains", "regex_extract":
+		if _, err := p.ruler.compileRegexp(f.Value.(string)); err != nil {
+			return fmt.Errorf("bad regex on (%s): %v", f.Path, err)
+		}
+	}
+
+	return nil
+}
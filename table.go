@@ -0,0 +1,190 @@
+package ruler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+A DecisionTable is a set of rules laid out as a table: every column is a condition
+on a path, and every row fills in the values for those conditions and gives the
+outcome when they all pass. A nil value (an empty cell, or "-" in CSV) means
+the row doesn't care about that column.
+Here's a sample in JSON format:
+
+	{
+		"columns": [
+			{"path": "user.country"},
+			{"path": "order.total", "comparator": "gte"}
+		],
+		"rows": [
+			{"values": ["US", 100], "outcome": "free-shipping"},
+			{"values": ["US", null], "outcome": "flat-rate"},
+			{"values": [null, null], "outcome": "international"}
+		]
+	}
+
+Rows are compiled into rule groups with outcomes in the same order,
+so they're checked top to bottom by Ruler's Decide function.
+*/
+type DecisionTable struct {
+	Columns []*DecisionColumn `json:"columns"`
+	Rows    []*DecisionRow    `json:"rows"`
+}
+
+// DecisionColumn is one column of a DecisionTable
+// the comparator defaults to eq
+type DecisionColumn struct {
+	Path       string `json:"path"`
+	Comparator string `json:"comparator,omitempty"`
+}
+
+// DecisionRow is one row of a DecisionTable, with a value for each column
+type DecisionRow struct {
+	ID      string        `json:"id,omitempty"`
+	Values  []interface{} `json:"values"`
+	Outcome interface{}   `json:"outcome"`
+}
+
+// NewRulerWithDecisionTable returns a new ruler with a rule group for every row in the table
+func NewRulerWithDecisionTable(t *DecisionTable, opts ...Option) (*Ruler, error) {
+	rules, err := t.Rules()
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRuler(rules, opts...)
+	if err := r.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ParseDecisionTableJSON parses a decision table from JSON data
+func ParseDecisionTableJSON(jsonstr []byte) (*DecisionTable, error) {
+	var t DecisionTable
+	if err := json.Unmarshal(jsonstr, &t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// ParseDecisionTableCSV parses a decision table from CSV
+// the first row is the header: each column is a path, optionally followed
+// by a space and a comparator ("order.total gte"), except for a column
+// named "outcome", which is required, and an optional column named "id"
+// cells holding JSON (numbers, true, "quoted strings") are decoded as JSON,
+// anything else is used as a plain string
+func ParseDecisionTableCSV(in io.Reader) (*DecisionTable, error) {
+	records, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("decision table CSV has no header row")
+	}
+
+	t := &DecisionTable{}
+	outcomeCol, idCol := -1, -1
+	var condCols []int
+
+	for i, h := range records[0] {
+		h = strings.TrimSpace(h)
+		switch h {
+		case "outcome":
+			outcomeCol = i
+		case "id":
+			idCol = i
+		default:
+			fields := strings.Fields(h)
+			if len(fields) == 0 || len(fields) > 2 {
+				return nil, fmt.Errorf("bad decision table column header (%s)", h)
+			}
+
+			col := &DecisionColumn{Path: fields[0]}
+			if len(fields) == 2 {
+				col.Comparator = fields[1]
+			}
+			t.Columns = append(t.Columns, col)
+			condCols = append(condCols, i)
+		}
+	}
+
+	if outcomeCol < 0 {
+		return nil, errors.New("decision table CSV has no outcome column")
+	}
+
+	for _, record := range records[1:] {
+		row := &DecisionRow{
+			Outcome: csvValue(record[outcomeCol]),
+		}
+		if idCol >= 0 {
+			row.ID = record[idCol]
+		}
+
+		for _, i := range condCols {
+			row.Values = append(row.Values, csvValue(record[i]))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return t, nil
+}
+
+// csvValue turns a cell into a value, nil being "don't care"
+func csvValue(cell string) interface{} {
+	cell = strings.TrimSpace(cell)
+	if cell == "" || cell == "-" {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(cell), &v); err == nil {
+		return v
+	}
+
+	return cell
+}
+
+// Rules turns the table's rows into rule groups with outcomes
+func (t *DecisionTable) Rules() ([]*Rule, error) {
+	rules := make([]*Rule, len(t.Rows))
+	for i, row := range t.Rows {
+		if len(row.Values) != len(t.Columns) {
+			return nil, fmt.Errorf("decision table row %d has %d values for %d columns", i, len(row.Values), len(t.Columns))
+		}
+
+		group := &Rule{
+			ID:      row.ID,
+			All:     []*Rule{},
+			Outcome: row.Outcome,
+		}
+
+		for j, v := range row.Values {
+			if v == nil {
+				continue
+			}
+
+			col := t.Columns[j]
+			comparator := col.Comparator
+			if comparator == "" {
+				comparator = "eq"
+			}
+
+			group.All = append(group.All, &Rule{
+				Comparator: comparator,
+				Path:       col.Path,
+				Value:      v,
+			})
+		}
+		rules[i] = group
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,209 @@
+package ruler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Transform changes a value plucked from a document before a rule compares it,
+// rules list them by name in Transforms and they run in order
+// a value that isn't there at all (nil) never reaches a transform
+type Transform func(v interface{}) (interface{}, error)
+
+// the transforms every Ruler has, WithTransform can add more or replace these
+var builtinTransforms = map[string]Transform{
+	"trim":             stringTransform("trim", strings.TrimSpace),
+	"lower":            stringTransform("lower", strings.ToLower),
+	"upper":            stringTransform("upper", strings.ToUpper),
+	"collapse_spaces":  stringTransform("collapse_spaces", collapseSpaces),
+	"strip_diacritics": stringTransform("strip_diacritics", stripDiacritics),
+	"to_number":        toNumber,
+	"to_string":        toString,
+	"len":              length,
+	"abs":              abs,
+}
+
+// WithTransform adds a transform rules can use by name
+func WithTransform(name string, fn Transform) Option {
+	return func(r *Ruler) {
+		// copied, so Rulers built from the same Clone don't share additions
+		transforms := make(map[string]Transform, len(r.transforms)+1)
+		for k, v := range r.transforms {
+			transforms[k] = v
+		}
+		transforms[name] = fn
+		r.transforms = transforms
+	}
+}
+
+// transform finds a transform by name, the Ruler's own first
+func (r *Ruler) transform(name string) (Transform, bool) {
+	if fn, ok := r.transforms[name]; ok {
+		return fn, true
+	}
+
+	fn, ok := builtinTransforms[name]
+	return fn, ok
+}
+
+// compileTransforms looks up a rule's transforms ahead of time
+func (r *Ruler) compileTransforms(f *Rule) ([]Transform, error) {
+	if len(f.Transforms) == 0 {
+		return nil, nil
+	}
+
+	fns := make([]Transform, len(f.Transforms))
+	for i, name := range f.Transforms {
+		fn, ok := r.transform(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %s on (%s)", name, f.Path)
+		}
+		fns[i] = fn
+	}
+
+	return fns, nil
+}
+
+// applyTransforms runs a rule's transforms over a plucked value
+func applyTransforms(f *compiledRule, val interface{}) (interface{}, error) {
+	var err error
+	for i, fn := range f.transforms {
+		if val == nil {
+			break
+		}
+		if val, err = fn(val); err != nil {
+			return nil, fmt.Errorf("transform %s on (%s): %w", f.Transforms[i], f.Path, err)
+		}
+	}
+
+	return val, nil
+}
+
+// stringTransform makes a Transform from a string function,
+// which works on every string in an array too (for wildcard paths)
+func stringTransform(name string, fn func(string) string) Transform {
+	var t Transform
+	t = func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case string:
+			return fn(v), nil
+		case []interface{}:
+			out := make([]interface{}, len(v))
+			for i, item := range v {
+				var err error
+				if out[i], err = t(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}
+
+		return nil, fmt.Errorf("can't %s a %T", name, v)
+	}
+
+	return t
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// stripDiacritics turns "Crème Brûlée" into "Creme Brulee"
+func stripDiacritics(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+
+	return out
+}
+
+// toNumber parses strings into float64s, other numbers are converted to float64
+func toNumber(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", v)
+		}
+		return n, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = toNumber(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	n, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("can't make a number from a %T", v)
+	}
+
+	return n, nil
+}
+
+// toString formats numbers and booleans as strings
+func toString(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = toString(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	n, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("can't make a string from a %T", v)
+	}
+
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+// length is the number of characters in a string, or items in an array or object
+func length(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+
+	items, ok := asSlice(v)
+	if !ok {
+		return nil, fmt.Errorf("can't take the len of a %T", v)
+	}
+
+	return float64(len(items)), nil
+}
+
+// abs is the absolute value of a number
+func abs(v interface{}) (interface{}, error) {
+	n, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("can't take the abs of a %T", v)
+	}
+
+	return math.Abs(n), nil
+}
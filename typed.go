@@ -0,0 +1,170 @@
+package ruler
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestTyped tests a Go value (usually a struct, or a pointer to one) against the rules
+// without converting it to a map first: paths walk struct fields by their
+// json tag, or their name if they don't have one, as well as maps and slices
+// numbers, strings and bools come out the way JSON would have them, so rules
+// work the same on the struct as on its JSON
+func TestTyped[T any](r *Ruler, v T) (bool, error) {
+	ev, err := NewEvaluator[T](r)
+	if err != nil {
+		return false, err
+	}
+
+	return ev.Test(v)
+}
+
+// Evaluator tests values of one Go type against compiled rules,
+// see TestTyped
+type Evaluator[T any] struct {
+	c *CompiledRuler
+}
+
+// NewEvaluator compiles the Ruler's rules for testing values of type T
+func NewEvaluator[T any](r *Ruler) (*Evaluator[T], error) {
+	c, err := r.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator[T]{c: c}, nil
+}
+
+// Test tests a value against the rules
+func (ev *Evaluator[T]) Test(v T) (bool, error) {
+	res := ev.Evaluate(v)
+	defer res.Release()
+
+	return res.Matched, res.Err
+}
+
+// Evaluate tests a value against the rules and says which one it failed, if any
+func (ev *Evaluator[T]) Evaluate(v T) *Result {
+	e := ev.c.ruler.newEvaluation(context.Background(), nil, nil)
+	defer e.release()
+	e.root = v
+
+	return ev.c.evaluate(e)
+}
+
+// Walkable lets your own types be walked by paths, for documents that
+// aren't maps or structs, like a protobuf message
+// Field returns the value of one path segment, or nil if it's missing,
+// and should return values the way JSON would have them (see TestTyped)
+type Walkable interface {
+	Field(name string) interface{}
+}
+
+// pluckField walks one path segment into a Go value that isn't
+// a map[string]interface{}: a Walkable, a struct, a pointer to one, or a map with string keys
+func pluckField(v interface{}, part string) interface{} {
+	if w, ok := v.(Walkable); ok {
+		return w.Field(part)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		i, ok := structFields(rv.Type())[part]
+		if !ok {
+			return nil
+		}
+		return jsonish(rv.Field(i))
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		fv := rv.MapIndex(reflect.ValueOf(part).Convert(rv.Type().Key()))
+		if !fv.IsValid() {
+			return nil
+		}
+		return jsonish(fv)
+	}
+
+	return nil
+}
+
+// field indexes by name for each struct type, worked out once
+var fieldCache sync.Map
+
+// structFields maps the names a path can use to a struct's exported fields
+func structFields(t reflect.Type) map[string]int {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(map[string]int)
+	}
+
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = i
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// jsonish turns a plucked Go value into what JSON would have given us:
+// a float64, string or bool, leaving the types compare handles itself alone
+// nil pointers, maps, slices and interfaces are missing, like a JSON null
+func jsonish(rv reflect.Value) interface{} {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	if !rv.CanInterface() {
+		return nil
+	}
+
+	v := rv.Interface()
+	switch v.(type) {
+	case Comparable, time.Time, time.Duration, net.IP:
+		return v
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+
+	return v
+}
@@ -0,0 +1,95 @@
+package ruler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ruleset is the object form of rule JSON, for rules that carry a version
+// or definitions, see expandRefs
+//
+//	{"version": "2024-06-01.2", "rules": [...]}
+//
+// a plain array of rules works too, it just has no version
+type ruleset struct {
+	Version     string           `json:"version"`
+	Rules       []*Rule          `json:"rules"`
+	Definitions map[string]*Rule `json:"definitions,omitempty"`
+}
+
+// WithVersion sets the version of the rules, for Rulers not built from versioned JSON
+func WithVersion(version string) Option {
+	return func(r *Ruler) {
+		r.version = version
+	}
+}
+
+// Version is the version the rules were given, in their JSON or with WithVersion
+// it's copied into every Result and AuditRecord, so decisions can be traced
+// back to the rules that made them
+func (r *Ruler) Version() string {
+	return r.version
+}
+
+// Fingerprint is a sha256 digest of the rules themselves, which changes
+// whenever they do, even if nobody remembered to bump the Version
+func (r *Ruler) Fingerprint() string {
+	return fingerprint(r.rules)
+}
+
+// Version is the compiled version of Ruler's Version
+func (c *CompiledRuler) Version() string {
+	return c.ruler.version
+}
+
+// Fingerprint is the compiled version of Ruler's Fingerprint
+func (c *CompiledRuler) Fingerprint() string {
+	if c.fingerprint != "" {
+		return c.fingerprint
+	}
+
+	rules := make([]*Rule, len(c.rules))
+	for i, f := range c.rules {
+		rules[i] = f.Rule
+	}
+
+	return fingerprint(rules)
+}
+
+func fingerprint(rules []*Rule) string {
+	// encoding/json sorts map keys, so the same rules always encode the same way
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// parseRules reads rule JSON, either an array of rules or a versioned ruleset
+func (r *Ruler) parseRules(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		var rules []*Rule
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return err
+		}
+		var err error
+		r.rules, err = expandRefs(rules, nil)
+		return err
+	}
+
+	var set ruleset
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	rules, err := expandRefs(set.Rules, set.Definitions)
+	if err != nil {
+		return err
+	}
+	r.version, r.rules = set.Version, rules
+	return nil
+}